@@ -9,6 +9,7 @@ import (
 	"CiFa/service"
 	"fmt"
 	"sync"
+	"time"
 )
 
 type App struct {
@@ -34,8 +35,9 @@ func GetInstance() *App {
 /* Conf */
 
 type appConf struct {
-	StaticDir     string `json:"static_dir"`      // 静态服务的文件目录
-	TempDirPrefix string `json:"temp_dir_prefix"` // 临时文件目录的前缀
+	StaticDir     string        `json:"static_dir"`      // 静态服务的文件目录
+	TempDirPrefix string        `json:"temp_dir_prefix"` // 临时文件目录的前缀
+	TaskTimeout   time.Duration `json:"task_timeout"`    // wordfa 任务的最长运行时间，为 0 时不限
 }
 
 /* Runtime */
@@ -56,4 +58,5 @@ func (a *App) Test() error {
 
 func (a *App) Run() {
 	a.Runtime.Service = service.NewService(a.Conf.StaticDir, a.Conf.TempDirPrefix)
+	a.Runtime.Service.TaskTimeout = a.Conf.TaskTimeout
 }
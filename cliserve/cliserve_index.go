@@ -0,0 +1,46 @@
+// Copyright (c) 2020 CDFMLR. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at  http://www.apache.org/licenses/LICENSE-2.0
+
+package cliserve
+
+import (
+	"CiFa/util/index"
+	"fmt"
+	"log"
+	"time"
+)
+
+type CliIndexServer struct {
+	SourceFilePath string
+	OutputFilePath string
+}
+
+// Build 为 SourceFilePath 中的文件建立索引，保存到 OutputFilePath
+func (c *CliIndexServer) Build() {
+	srcFiles := getSrcFiles(c.SourceFilePath)
+
+	start := time.Now()
+	idx, err := index.Build(srcFiles)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err := idx.Save(c.OutputFilePath); err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Printf("Indexed %v files (%v bytes) in %v\n", len(idx.Files()), idx.Len(), time.Since(start))
+	fmt.Println("Index in", c.OutputFilePath)
+}
+
+// loadIndex 载入 wordfa 使用的索引，源文件在建立索引后被修改过时给出警告
+func loadIndex(indexFilePath string) *index.Index {
+	idx, err := index.Load(indexFilePath)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err := idx.Verify(); err != nil {
+		log.Println("Warning: the index may be out of date:", err)
+	}
+	return idx
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2020 CDFMLR. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at  http://www.apache.org/licenses/LICENSE-2.0
+
+package cliserve
+
+import (
+	"CiFa/util/strsearch"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"time"
+)
+
+type CliStrsearchServer struct {
+	SourceFilePath string
+	Pattern        string
+
+	StrsearchAlgo string
+	IndexUnit     string // byte|rune|line
+}
+
+func (c *CliStrsearchServer) Run() {
+	algorithm := strsearch.LibRe
+	if c.StrsearchAlgo != "" {
+		a, ok := strsearch.StrsearchAlgorithmsMap[c.StrsearchAlgo]
+		if !ok {
+			log.Fatalln("unknown string match algorithm:", c.StrsearchAlgo)
+		}
+		algorithm = a
+	}
+	if err := strsearch.ValidatePattern(algorithm, c.Pattern); err != nil {
+		log.Fatalf("bad pattern %q: %v\n", c.Pattern, err)
+	}
+
+	indexUnit := strsearch.ByteIndex
+	if c.IndexUnit != "" {
+		u, ok := strsearch.IndexUnitsMap[c.IndexUnit]
+		if !ok {
+			log.Fatalln("unknown index unit:", c.IndexUnit)
+		}
+		indexUnit = u
+	}
+
+	data, err := ioutil.ReadFile(c.SourceFilePath)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	text := string(data)
+
+	start := time.Now()
+	indices := strsearch.By(algorithm).FindAll(text, c.Pattern)
+	elapsed := time.Since(start)
+
+	fmt.Printf("Found %v matches in %v:\n", len(indices), elapsed)
+	switch indexUnit {
+	case strsearch.ByteIndex:
+		for _, i := range indices {
+			fmt.Println(i)
+		}
+	case strsearch.RuneIndex:
+		for _, i := range strsearch.ConvertIndices(text, indices, indexUnit) {
+			fmt.Println(i)
+		}
+	case strsearch.LineIndex:
+		for _, p := range strsearch.Positions(text, indices) {
+			fmt.Printf("%v:%v\n", p.Line, p.Column)
+		}
+	}
+}
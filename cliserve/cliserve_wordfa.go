@@ -7,84 +7,278 @@ package cliserve
 
 import (
 	"CiFa/util"
+	"CiFa/util/segment"
 	"CiFa/util/sortalgo"
+	"CiFa/util/strsearch"
 	"CiFa/wordfa"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
 	"time"
 )
 
 type CliWordfaServer struct {
-	KeywordFilePath string
+	KeywordFilePath string // 为空时是词汇发现模式，统计所有词
 	SourceFilePath  string
 
 	SortAlgo      string
 	StrsearchAlgo string
 
 	OutputFilePath string
+	MatrixFilePath string // 文档-词矩阵的输出文件，以 .json 结尾时输出 JSON，否则输出 CSV
+
+	MaxEditDistance int
+	MatchOptions    strsearch.MatchOptions
+	Concordance     wordfa.ConcordanceOptions
+	Discover        wordfa.DiscoverOptions
+	NGram           wordfa.NGramOptions
+
+	ScoreMethod       string // 评分方法名，见 wordfa.ScoringMethodsMap
+	ReferenceFilePath string // 关键性评分的参照语料文件/目录
+
+	CooccurScope         string // 关键词共现的范围名，见 wordfa.CooccurrenceScopesMap，为空时不统计
+	CooccurWindow        int    // 共现范围为 Window 时的窗口字数
+	CooccurrenceFilePath string // 共现网络的输出文件，以 .graphml、.gexf 结尾时输出该格式，否则输出 JSON
+
+	TokenMatch   bool
+	Segmenter    string // 词汇发现、分词匹配使用的分词算法名，见 segment.SegmentersMap
+	DictFilePath string // 分词使用的词典文件，为空时使用内置词典
+
+	IndexFilePath string // 使用 cifa index build 建立的索引，此时 SourceFilePath 可以省略
+
+	Workers int           // 同时处理的文件数，<= 0 时使用 CPU 核数
+	Timeout time.Duration // 任务的最长运行时间，超时后停止且不输出结果。为 0 时不限
 }
 
 func (c *CliWordfaServer) Run() {
-	patterns := getPatterns(c.KeywordFilePath)
-	srcFiles := getSrcFiles(c.SourceFilePath)
+	var patterns []string
+	if c.KeywordFilePath != "" {
+		patterns = getPatterns(c.KeywordFilePath)
+	}
+	var srcFiles []string
+	if c.SourceFilePath != "" {
+		srcFiles = getSrcFiles(c.SourceFilePath)
+	}
 
 	task := wordfa.NewTask(srcFiles, patterns)
+	c.configure(task, patterns)
+
+	//logging.Debug("patterns: ", task.Patterns)
+	//logging.Debug("srcFiles: ", task.SrcFiles)
+
+	if err := runTask(c.Timeout, task.RunContext, task.GetStatus); err != nil {
+		fmt.Println("Stopped:", err)
+		return
+	}
+
+	if r, ok := task.GetResult(sortalgo.Heap); ok {
+		if c.MatrixFilePath != "" {
+			m, _ := task.GetMatrix(sortalgo.Heap)
+			if err := writeMatrixToFile(c.MatrixFilePath, m); err == nil {
+				fmt.Println("Matrix in", c.MatrixFilePath)
+			} else {
+				fmt.Println("Failed to write matrix: ", err)
+			}
+		}
+		if c.CooccurrenceFilePath != "" {
+			co, _ := task.GetCooccurrence(sortalgo.Heap)
+			if err := writeCooccurrenceToFile(c.CooccurrenceFilePath, co); err == nil {
+				fmt.Println("Co-occurrence in", c.CooccurrenceFilePath)
+			} else {
+				fmt.Println("Failed to write co-occurrence: ", err)
+			}
+		}
+		if c.OutputFilePath != "" {
+			if err := writeResultToFile(c.OutputFilePath, r); err == nil {
+				fmt.Println("Result in", c.OutputFilePath)
+				printErrors(task.GetErrors())
+				return
+			} else {
+				fmt.Println("Failed to write result: ", err)
+			}
+		}
+		fmt.Println("Result: ")
+		printResult(r)
+		printErrors(task.GetErrors())
+		if co, _ := task.GetCooccurrence(sortalgo.Heap); co != nil && c.CooccurrenceFilePath == "" {
+			fmt.Println("Co-occurrence: ")
+			printCooccurrence(co)
+		}
+	}
+}
+
+// printErrors 打印处理出错的文件，此时结果只包含其余文件
+func printErrors(errors []wordfa.FileError) {
+	if len(errors) == 0 {
+		return
+	}
+	fmt.Printf("Failed: %v file(s) skipped, the result above is partial:\n", len(errors))
+	for _, e := range errors {
+		fmt.Printf("\t%v: %v\n", e.File, e.Error)
+	}
+}
+
+// printCooccurrence 打印共现过的关键词对，每行 "词1 - 词2: 共现次数 (pmi: 点互信息)"
+func printCooccurrence(co *wordfa.Cooccurrence) {
+	for _, p := range co.Pairs {
+		fmt.Printf("%v - %v: %v (pmi: %.4f)\n", co.Keywords[p.I], co.Keywords[p.J], p.Count, p.PMI)
+	}
+}
+
+// configure 把命令行指定的选项设置到 task
+func (c *CliWordfaServer) configure(task *wordfa.Task, patterns []string) {
+	if c.IndexFilePath != "" {
+		task.Index = loadIndex(c.IndexFilePath)
+	}
 	if c.SortAlgo != "" {
 		task.SortFuncName = c.SortAlgo
 	}
+	task.Workers = c.Workers
+	task.MaxEditDistance = c.MaxEditDistance
+	task.MatchOptions = c.MatchOptions
+	task.Concordance = c.Concordance
+	task.Discover = c.Discover
+	task.NGram = c.NGram
+	if task.NGram.TopK <= 0 {
+		task.NGram.TopK = c.Discover.TopN // 命令行的 --top 同时作用于词汇发现与 n-gram
+	}
+	task.TokenMatch = c.TokenMatch
+	if c.ScoreMethod != "" {
+		method, ok := wordfa.ScoringMethodsMap[c.ScoreMethod]
+		if !ok {
+			log.Fatalf("unknown scoring method %q\n", c.ScoreMethod)
+		}
+		task.Score.Method = method
+	}
+	if c.ReferenceFilePath != "" {
+		task.Score.Reference = getSrcFiles(c.ReferenceFilePath)
+	}
+	if err := task.Score.Validate(); err != nil {
+		log.Fatalln(err)
+	}
+	if c.CooccurScope != "" {
+		scope, ok := wordfa.CooccurrenceScopesMap[c.CooccurScope]
+		if !ok {
+			log.Fatalf("unknown co-occurrence scope %q\n", c.CooccurScope)
+		}
+		task.Cooccurrence = wordfa.CooccurrenceOptions{Scope: scope, Window: c.CooccurWindow}
+	}
+	if c.Segmenter != "" || c.DictFilePath != "" {
+		task.Segmenter = getSegmenter(c.Segmenter, c.DictFilePath)
+	}
 	if c.StrsearchAlgo != "" {
 		task.StrSearchFuncName = c.StrsearchAlgo
+		algorithm := strsearch.StrsearchAlgorithmsMap[c.StrsearchAlgo]
+		for _, p := range patterns {
+			if err := strsearch.ValidatePattern(algorithm, p); err != nil {
+				log.Fatalf("bad keyword %q: %v\n", p, err)
+			}
+		}
+		if err := strsearch.ValidateOptions(algorithm, c.MatchOptions); err != nil {
+			log.Fatalln(err)
+		}
 	}
+}
 
-	//logging.Debug("patterns: ", task.Patterns)
-	//logging.Debug("srcFiles: ", task.SrcFiles)
-
-	go task.Run()
+// runTask 运行任务 run，每 200ms 打印一次 getStatus 返回的进度，直到任务完成。
+// 超过 timeout (> 0 时) 或收到中断信号 (Ctrl-C) 时停止任务，返回停止的原因
+func runTask(timeout time.Duration, run func(ctx context.Context) error, getStatus func() wordfa.Progress) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
 
-	finished := make(chan bool)
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
 	go func() {
-		for {
-			p := task.GetProgress()
-			if p < 0 {
-				p = 0
-			} else if p > 1 {
-				p = 1
-			}
-			fmt.Printf("> progress: %.2f%%\n", p*100)
-			if p >= 1 {
-				finished <- true
-				return
-			}
-			time.Sleep(200 * time.Millisecond)
+		select {
+		case <-interrupt:
+			cancel()
+		case <-ctx.Done():
 		}
 	}()
 
-	if <-finished {
-		if r, ok := task.GetResult(sortalgo.Heap); ok {
-			if c.OutputFilePath != "" {
-				if err := writeResultToFile(c.OutputFilePath, r); err == nil {
-					fmt.Println("Result in", c.OutputFilePath)
-					return
-				} else {
-					fmt.Println("Failed to write result: ", err)
-				}
-			}
-			fmt.Println("Result: ")
-			printResult(r)
-			return
+	done := make(chan error, 1)
+	go func() {
+		done <- run(ctx)
+	}()
+
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		fmt.Println(formatProgress(getStatus()))
+		select {
+		case err := <-done:
+			return err
+		case <-ticker.C:
 		}
 	}
 }
 
+// formatProgress 格式化进度，如
+//	> progress: 45.00% [running] 3/10 files, 12.0 MiB/26.7 MiB, 5.1 MiB/s, ETA 3s, current: a.txt
+func formatProgress(p wordfa.Progress) string {
+	s := fmt.Sprintf("> progress: %.2f%% [%v] %v/%v files, %v/%v",
+		p.Fraction()*100, p.State, p.FilesDone, p.Files, formatBytes(float64(p.BytesDone)), formatBytes(float64(p.Bytes)))
+	if p.Throughput > 0 {
+		s += fmt.Sprintf(", %v/s", formatBytes(p.Throughput))
+	}
+	if p.ETA > 0 {
+		s += fmt.Sprintf(", ETA %v", time.Duration(p.ETA*float64(time.Second)).Round(time.Second))
+	}
+	if len(p.CurrentFiles) > 0 {
+		s += ", current: " + strings.Join(p.CurrentFiles, ", ")
+	}
+	return s
+}
+
+// formatBytes 以 B、KiB、MiB、GiB 为单位格式化字节数 n
+func formatBytes(n float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB"}
+	i := 0
+	for ; n >= 1024 && i < len(units)-1; i++ {
+		n /= 1024
+	}
+	if i == 0 {
+		return fmt.Sprintf("%.0f %v", n, units[i])
+	}
+	return fmt.Sprintf("%.1f %v", n, units[i])
+}
+
 func printResult(result wordfa.Result) {
 	for _, v := range result {
-		fmt.Printf("%v: %v\n", v.Keyword, v.Frequency)
+		fmt.Print(formatResultItem(v))
 	}
 }
 
+// formatResultItem 格式化一条结果，有近似匹配时附上近似匹配的次数，n-gram 的频数不精确时附上误差，
+// 评分时附上得分(与参照语料中的频数)，有 KWIC 片段时每个片段另起一行
+func formatResultItem(item wordfa.ResultItem) string {
+	var sb strings.Builder
+	if item.Score != 0 || item.ReferenceFrequency != 0 {
+		sb.WriteString(fmt.Sprintf("%v: %v (reference: %v, score: %.6g)\n", item.Keyword, item.Frequency, item.ReferenceFrequency, item.Score))
+	} else if item.FuzzyFrequency > 0 {
+		sb.WriteString(fmt.Sprintf("%v: %v (fuzzy: %v)\n", item.Keyword, item.Frequency, item.FuzzyFrequency))
+	} else if item.FrequencyError > 0 {
+		sb.WriteString(fmt.Sprintf("%v: %v (error: %v)\n", item.Keyword, item.Frequency, item.FrequencyError))
+	} else {
+		sb.WriteString(fmt.Sprintf("%v: %v\n", item.Keyword, item.Frequency))
+	}
+	for _, s := range item.Snippets {
+		sb.WriteString(fmt.Sprintf("\t%v:%v:%v: %v [%v] %v\n", s.File, s.Line, s.Column, s.Left, s.Keyword, s.Right))
+	}
+	return sb.String()
+}
+
 func writeResultToFile(outFilePath string, result wordfa.Result) error {
 	f, err := os.OpenFile(
 		outFilePath,
@@ -96,11 +290,56 @@ func writeResultToFile(outFilePath string, result wordfa.Result) error {
 	}
 	defer f.Close()
 	for _, v := range result {
-		f.Write([]byte(fmt.Sprintf("%v: %v\n", v.Keyword, v.Frequency)))
+		f.Write([]byte(formatResultItem(v)))
 	}
 	return nil
 }
 
+// writeMatrixToFile 把文档-词矩阵写入文件 outFilePath，文件名以 .json 结尾时写入 JSON，否则写入 CSV
+func writeMatrixToFile(outFilePath string, m *wordfa.Matrix) error {
+	isJson := strings.HasSuffix(strings.ToLower(outFilePath), ".json")
+	if !isJson && m.Counts == nil {
+		return wordfa.ErrNoCounts
+	}
+	f, err := os.OpenFile(
+		outFilePath,
+		os.O_WRONLY|os.O_CREATE|os.O_TRUNC,
+		0600,
+	)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if isJson {
+		return json.NewEncoder(f).Encode(m)
+	}
+	return m.WriteCSV(f)
+}
+
+// writeCooccurrenceToFile 把关键词共现网络写入文件 outFilePath，
+// 文件名以 .graphml、.gexf 结尾时写入该格式，否则写入 JSON
+func writeCooccurrenceToFile(outFilePath string, co *wordfa.Cooccurrence) error {
+	if co == nil {
+		return fmt.Errorf("co-occurrence not counted, use --cooccur with keywords")
+	}
+	f, err := os.OpenFile(
+		outFilePath,
+		os.O_WRONLY|os.O_CREATE|os.O_TRUNC,
+		0600,
+	)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	switch ext := strings.ToLower(filepath.Ext(outFilePath)); ext {
+	case ".graphml":
+		return co.WriteGraphML(f)
+	case ".gexf":
+		return co.WriteGEXF(f)
+	}
+	return json.NewEncoder(f).Encode(co)
+}
+
 // getPatterns 从文件 keywordFilePath 里获取要匹配的子串
 func getPatterns(keywordFilePath string) []string {
 	patterns := make([]string, 0)
@@ -120,6 +359,26 @@ func getPatterns(keywordFilePath string) []string {
 	return patterns
 }
 
+// getSegmenter 返回名为 name (为空时使用正向最大匹配) 的分词器，
+// 使用词典文件 dictFilePath (为空时使用内置词典)
+func getSegmenter(name string, dictFilePath string) segment.Segmenter {
+	segmenter := segment.ForwardMM
+	if name != "" {
+		var ok bool
+		if segmenter, ok = segment.SegmentersMap[name]; !ok {
+			log.Fatalf("unknown segmenter %q\n", name)
+		}
+	}
+	var dict *segment.Dictionary
+	if dictFilePath != "" {
+		var err error
+		if dict, err = segment.LoadDictionary(dictFilePath); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	return segment.By(segmenter, dict)
+}
+
 // getSrcFiles 从文件/目录 sourceFilePath 里获取要匹配的文件
 // 若 sourceFilePath 是目录则递归寻找其中所有类型为 text/plain 的文件
 // 若 sourceFilePath 单个文件则返回[]string{sourceFilePath}
@@ -0,0 +1,101 @@
+// Copyright (c) 2020 CDFMLR. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at  http://www.apache.org/licenses/LICENSE-2.0
+
+package cliserve
+
+import (
+	"CiFa/util"
+	"CiFa/util/sortalgo"
+	"CiFa/wordfa"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+)
+
+// CliWordfaDiffServer 比较关键词在两组语料中的频数，见 wordfa.DiffTask
+type CliWordfaDiffServer struct {
+	CliWordfaServer // 关键词、匹配与分词选项，SourceFilePath 是语料 A
+
+	SourceFilePathB string // 语料 B
+}
+
+func (c *CliWordfaDiffServer) Run() {
+	tmp, err := ioutil.TempDir("", "cifa-diff")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	patterns := getPatterns(c.KeywordFilePath)
+	task := wordfa.NewDiffTask(getCorpusFiles(c.SourceFilePath, tmp), getCorpusFiles(c.SourceFilePathB, tmp), patterns)
+	c.configure(&task.Task, patterns)
+
+	if err := runTask(c.Timeout, task.RunContext, task.GetStatus); err != nil {
+		fmt.Println("Stopped:", err)
+		return
+	}
+
+	if r, ok := task.GetResult(sortalgo.Heap); ok {
+		if c.OutputFilePath != "" {
+			if err := writeDiffResultToFile(c.OutputFilePath, r); err == nil {
+				fmt.Println("Result in", c.OutputFilePath)
+				printErrors(task.GetErrors())
+				return
+			} else {
+				fmt.Println("Failed to write result: ", err)
+			}
+		}
+		fmt.Println("Result: ")
+		for _, v := range r {
+			fmt.Print(formatDiffItem(v))
+		}
+		printErrors(task.GetErrors())
+	}
+}
+
+// formatDiffItem 格式化一条比较结果: 频数 A -> B (变化量, 相对变化), 每万词频率 A -> B
+func formatDiffItem(item wordfa.DiffItem) string {
+	relative := "new"
+	if item.FrequencyA > 0 {
+		relative = fmt.Sprintf("%+.2f%%", item.RelativeChange*100)
+	} else if item.FrequencyB == 0 {
+		relative = "+0.00%"
+	}
+	return fmt.Sprintf("%v: %v -> %v (%+d, %v), per %v tokens: %.2f -> %.2f\n",
+		item.Keyword, item.FrequencyA, item.FrequencyB, item.Change, relative, wordfa.RatePer, item.RateA, item.RateB)
+}
+
+func writeDiffResultToFile(outFilePath string, result wordfa.DiffResult) error {
+	f, err := os.OpenFile(
+		outFilePath,
+		os.O_WRONLY|os.O_CREATE|os.O_TRUNC,
+		0600,
+	)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	for _, v := range result {
+		f.Write([]byte(formatDiffItem(v)))
+	}
+	return nil
+}
+
+// getCorpusFiles 同 getSrcFiles，但 sourceFilePath 也可以是 zip 文件，此时解压到 tmp 下的新目录中
+func getCorpusFiles(sourceFilePath string, tmp string) []string {
+	if !strings.HasSuffix(strings.ToLower(sourceFilePath), ".zip") {
+		return getSrcFiles(sourceFilePath)
+	}
+	dir, err := ioutil.TempDir(tmp, "corpus")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err := util.UnzipFile(dir, sourceFilePath); err != nil {
+		log.Fatalln(err)
+	}
+	return getSrcFiles(dir)
+}
@@ -0,0 +1,62 @@
+/*
+Copyright © 2020 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"CiFa/cliserve"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var indexCliServe = cliserve.CliIndexServer{}
+
+// indexCmd represents the index command
+var indexCmd = &cobra.Command{
+	Use:   "index",
+	Short: "Manage suffix array indexes of corpora",
+	Long: `Manage suffix array indexes of corpora.
+An index built once can answer many "cifa wordfa --index" queries without rescanning the corpus.`,
+}
+
+// indexBuildCmd represents the index build command
+var indexBuildCmd = &cobra.Command{
+	Use:   "build",
+	Short: "Build a suffix array index of a corpus and save it to a file",
+	Long:  `Build a suffix array index of a corpus (a text file, or all text files in a dir) and save it to a file.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if indexCliServe.SourceFilePath == "" || indexCliServe.OutputFilePath == "" {
+			fmt.Println("Cannot run without SourceFilePath & OutputFilePath given.")
+			os.Exit(1)
+		}
+		indexCliServe.Build()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(indexCmd)
+	indexCmd.AddCommand(indexBuildCmd)
+
+	indexBuildCmd.Flags().StringVarP(
+		&indexCliServe.SourceFilePath,
+		"file", "f", "", "source file/dir `path`",
+	)
+	indexBuildCmd.Flags().StringVarP(
+		&indexCliServe.OutputFilePath,
+		"output", "o", "", "save the index to `file`",
+	)
+}
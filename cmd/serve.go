@@ -22,11 +22,13 @@ import (
 	"github.com/spf13/cobra"
 	"net/http"
 	"os"
+	"time"
 )
 
 var port int
 var tempDirPrefix string
 var staticDir string
+var taskTimeout time.Duration
 
 // serveCmd represents the serve command
 var serveCmd = &cobra.Command{
@@ -44,6 +46,10 @@ var serveCmd = &cobra.Command{
 			cifa.Conf.TempDirPrefix = tempDirPrefix
 		}
 
+		if taskTimeout > 0 {
+			cifa.Conf.TaskTimeout = taskTimeout
+		}
+
 		// 检查 app 配置完备性
 		if err := cifa.Test(); err != nil {
 			_, _ = fmt.Fprintln(os.Stderr, "Config Error:", err)
@@ -69,4 +75,5 @@ func init() {
 	serveCmd.Flags().IntVarP(&port, "port", "p", 9001, "`port` for service")
 	serveCmd.Flags().StringVarP(&tempDirPrefix, "temp_dir_prefix", "t", "temp.cifa.", "name `prefix` for temp files' dir")
 	serveCmd.Flags().StringVarP(&staticDir, "static_dir", "s", "./static", "static (web ui) `dist` path")
+	serveCmd.Flags().DurationVar(&taskTimeout, "task_timeout", 0, "stop wordfa tasks running longer than `duration` (e.g. 10m), 0 for no limit")
 }
@@ -0,0 +1,71 @@
+/*
+Copyright © 2020 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"CiFa/cliserve"
+	"CiFa/util/strsearch"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var strsearchCliServe = cliserve.CliStrsearchServer{}
+
+// strsearchCmd represents the strsearch command
+var strsearchCmd = &cobra.Command{
+	Use:   "strsearch",
+	Short: "Find all occurrences of a pattern in a file",
+	Long: `Find all occurrences of a pattern in a file, and print their positions.
+Positions can be byte offsets, rune offsets, or line:column pairs (see --index_unit).`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if strsearchCliServe.Pattern == "" || strsearchCliServe.SourceFilePath == "" {
+			fmt.Println("Cannot run without Pattern & SourceFilePath given.")
+			os.Exit(1)
+		}
+		strsearchCliServe.Run()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(strsearchCmd)
+
+	strsearchCmd.Flags().StringVarP(
+		&strsearchCliServe.Pattern,
+		"pattern", "p", "", "`pattern` to search for",
+	)
+	strsearchCmd.Flags().StringVarP(
+		&strsearchCliServe.SourceFilePath,
+		"file", "f", "", "source file `path`",
+	)
+
+	matchAlgorithmsName := ""
+	for k, _ := range strsearch.StrsearchAlgorithmsMap {
+		matchAlgorithmsName += k + ", "
+	}
+	strsearchCmd.Flags().StringVarP(
+		&strsearchCliServe.StrsearchAlgo,
+		"match", "m", "",
+		"string match `algorithm`: one of "+strings.Trim(matchAlgorithmsName, ", "),
+	)
+
+	strsearchCmd.Flags().StringVarP(
+		&strsearchCliServe.IndexUnit,
+		"index_unit", "u", "byte", "`unit` of printed positions: one of byte, rune, line (line:column)",
+	)
+}
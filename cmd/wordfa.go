@@ -17,8 +17,10 @@ package cmd
 
 import (
 	"CiFa/cliserve"
+	"CiFa/util/segment"
 	"CiFa/util/sortalgo"
 	"CiFa/util/strsearch"
+	"CiFa/wordfa"
 	"fmt"
 	"os"
 	"strings"
@@ -34,8 +36,12 @@ var wordfaCmd = &cobra.Command{
 	Short: "Run a words frequency analyzing task in CLI",
 	Long:  `Run a words frequency analyzing task in CLI.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		if wordfaCliServe.KeywordFilePath == "" || wordfaCliServe.SourceFilePath == "" {
-			fmt.Println("Cannot run without KeywordFilePath & SourceFilePath given.")
+		if wordfaCliServe.SourceFilePath == "" && wordfaCliServe.IndexFilePath == "" {
+			fmt.Println("Cannot run without SourceFilePath (or IndexFilePath) given.")
+			os.Exit(1)
+		}
+		if n := wordfaCliServe.NGram.N; n < 0 || n > wordfa.MaxNGram {
+			fmt.Printf("--ngram should be 1~%v.\n", wordfa.MaxNGram)
 			os.Exit(1)
 		}
 		fmt.Println("wordfa calling...")
@@ -43,12 +49,32 @@ var wordfaCmd = &cobra.Command{
 	},
 }
 
+var wordfaDiffCliServe = cliserve.CliWordfaDiffServer{}
+
+// wordfaDiffCmd represents the wordfa diff command
+var wordfaDiffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Compare keyword frequencies between two corpora",
+	Long: `Compare keyword frequencies between two corpora (A and B, each a text file, a dir or a zip).
+For each keyword, show its frequency in A and B, the absolute and relative change,
+and the rate per 10k tokens in A and B.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if wordfaDiffCliServe.KeywordFilePath == "" || wordfaDiffCliServe.SourceFilePath == "" || wordfaDiffCliServe.SourceFilePathB == "" {
+			fmt.Println("Cannot run without KeywordFilePath & both corpora (-a, -b) given.")
+			os.Exit(1)
+		}
+		fmt.Println("wordfa diff calling...")
+		wordfaDiffCliServe.Run()
+	},
+}
+
 func init() {
 	rootCmd.AddCommand(wordfaCmd)
+	wordfaCmd.AddCommand(wordfaDiffCmd)
 
 	wordfaCmd.Flags().StringVarP(
 		&wordfaCliServe.KeywordFilePath,
-		"keyword", "k", "", "keywords file `path`",
+		"keyword", "k", "", "keywords file `path`; if omitted, segment the text and count every word",
 	)
 	wordfaCmd.Flags().StringVarP(
 		&wordfaCliServe.SourceFilePath,
@@ -70,7 +96,7 @@ func init() {
 		sortAlgorithmsName += k + ", "
 	}
 	wordfaCmd.Flags().StringVarP(
-		&wordfaCliServe.StrsearchAlgo,
+		&wordfaCliServe.SortAlgo,
 		"sort", "s", "",
 		"result sort `algorithm`: one of "+strings.Trim(sortAlgorithmsName, ", "),
 	)
@@ -79,4 +105,170 @@ func init() {
 		&wordfaCliServe.OutputFilePath,
 		"output", "o", "", "output result to `file`",
 	)
+	wordfaCmd.Flags().StringVar(
+		&wordfaCliServe.MatrixFilePath,
+		"matrix", "", "also write the per-file document-term matrix to `file` (CSV, or JSON if it ends with .json)",
+	)
+
+	wordfaCmd.Flags().IntVar(
+		&wordfaCliServe.Workers,
+		"workers", 0, "process at most `n` files at the same time (default: number of CPUs)",
+	)
+	wordfaCmd.Flags().DurationVar(
+		&wordfaCliServe.Timeout,
+		"timeout", 0, "stop the task if it runs longer than `duration` (e.g. 30s, 5m), 0 for no limit",
+	)
+	wordfaCmd.Flags().IntVarP(
+		&wordfaCliServe.MaxEditDistance,
+		"max_edit_distance", "d", 0, "also count fuzzy matches within `k` edits (in runes) of each keyword",
+	)
+
+	wordfaCmd.Flags().BoolVarP(
+		&wordfaCliServe.MatchOptions.IgnoreCase,
+		"ignore_case", "i", false, "match keywords case-insensitively (Unicode case folding)",
+	)
+	wordfaCmd.Flags().BoolVarP(
+		&wordfaCliServe.MatchOptions.WholeWord,
+		"whole_word", "w", false, "match whole words only (for space-delimited scripts such as Latin)",
+	)
+	wordfaCmd.Flags().BoolVar(
+		&wordfaCliServe.MatchOptions.NormalizeWidth,
+		"normalize_width", false, "treat full-width and half-width characters as the same",
+	)
+	wordfaCmd.Flags().StringVar(
+		&wordfaCliServe.IndexFilePath,
+		"index", "", "count exact matches with an index `file` built by \"cifa index build\" instead of scanning the source files",
+	)
+	wordfaCmd.Flags().IntVarP(
+		&wordfaCliServe.Concordance.Snippets,
+		"context", "c", 0, "show up to `n` keyword-in-context snippets (with file:line:column) for each keyword",
+	)
+	wordfaCmd.Flags().IntVar(
+		&wordfaCliServe.Concordance.Context,
+		"context_width", 20, "runes of left and right `context` in each snippet",
+	)
+	wordfaCmd.Flags().IntVarP(
+		&wordfaCliServe.Discover.TopN,
+		"top", "n", 0, "without -k or with -g: output only the `n` most frequent words or n-grams (n-grams default to 100)",
+	)
+	wordfaCmd.Flags().IntVar(
+		&wordfaCliServe.Discover.MinRunes,
+		"min_runes", 0, "without -k: count only words of at least `n` runes",
+	)
+	wordfaCmd.Flags().BoolVarP(
+		&wordfaCliServe.TokenMatch,
+		"token_match", "t", false, "count keywords only as whole segmented words (e.g. 是 is not counted in 不是)",
+	)
+
+	segmentersName := ""
+	for k, _ := range segment.SegmentersMap {
+		segmentersName += k + ", "
+	}
+	wordfaCmd.Flags().StringVar(
+		&wordfaCliServe.Segmenter,
+		"segmenter", "",
+		"word segmentation `algorithm` for -t or without -k: one of "+strings.Trim(segmentersName, ", "),
+	)
+	wordfaCmd.Flags().IntVarP(
+		&wordfaCliServe.NGram.N,
+		"ngram", "g", 0, "count the most frequent word `n`-grams (1~5) instead of keywords",
+	)
+	wordfaCmd.Flags().BoolVar(
+		&wordfaCliServe.NGram.Chars,
+		"ngram_chars", false, "with -g: count character n-grams instead of word n-grams",
+	)
+	scoringMethodsName := ""
+	for k, _ := range wordfa.ScoringMethodsMap {
+		scoringMethodsName += k + ", "
+	}
+	wordfaCmd.Flags().StringVar(
+		&wordfaCliServe.ScoreMethod,
+		"score", "",
+		"score and sort results by `method`: one of "+strings.Trim(scoringMethodsName, ", "),
+	)
+	wordfaCmd.Flags().StringVar(
+		&wordfaCliServe.ReferenceFilePath,
+		"reference", "", "reference corpus file/dir `path` for the LogLikelihood and ChiSquared keyness scores",
+	)
+	cooccurScopesName := ""
+	for k, _ := range wordfa.CooccurrenceScopesMap {
+		cooccurScopesName += k + ", "
+	}
+	wordfaCmd.Flags().StringVar(
+		&wordfaCliServe.CooccurScope,
+		"cooccur", "",
+		"count keyword co-occurrences and PMI within the same `scope`: one of "+strings.Trim(cooccurScopesName, ", "),
+	)
+	wordfaCmd.Flags().IntVar(
+		&wordfaCliServe.CooccurWindow,
+		"cooccur_window", wordfa.DefaultCooccurWindow, "with --cooccur Window: keywords within `n` runes co-occur",
+	)
+	wordfaCmd.Flags().StringVar(
+		&wordfaCliServe.CooccurrenceFilePath,
+		"cooccur_output", "", "write the co-occurrence network to `file` (GraphML/GEXF if it ends with .graphml/.gexf, JSON otherwise)",
+	)
+	wordfaCmd.Flags().StringVar(
+		&wordfaCliServe.DictFilePath,
+		"dict", "", "dictionary `file` for word segmentation, one \"word [frequency [tag]]\" per line",
+	)
+
+	wordfaDiffCmd.Flags().StringVarP(
+		&wordfaDiffCliServe.KeywordFilePath,
+		"keyword", "k", "", "keywords file `path`",
+	)
+	wordfaDiffCmd.Flags().StringVarP(
+		&wordfaDiffCliServe.SourceFilePath,
+		"corpus_a", "a", "", "corpus A: a text file, dir or zip `path`",
+	)
+	wordfaDiffCmd.Flags().StringVarP(
+		&wordfaDiffCliServe.SourceFilePathB,
+		"corpus_b", "b", "", "corpus B: a text file, dir or zip `path`",
+	)
+	wordfaDiffCmd.Flags().StringVarP(
+		&wordfaDiffCliServe.StrsearchAlgo,
+		"match", "m", "",
+		"string match `algorithm`: one of "+strings.Trim(matchAlgorithmsName, ", "),
+	)
+	wordfaDiffCmd.Flags().StringVarP(
+		&wordfaDiffCliServe.SortAlgo,
+		"sort", "s", "",
+		"result sort `algorithm`: one of "+strings.Trim(sortAlgorithmsName, ", "),
+	)
+	wordfaDiffCmd.Flags().StringVarP(
+		&wordfaDiffCliServe.OutputFilePath,
+		"output", "o", "", "output result to `file`",
+	)
+	wordfaDiffCmd.Flags().IntVar(
+		&wordfaDiffCliServe.Workers,
+		"workers", 0, "process at most `n` files of each corpus at the same time (default: number of CPUs)",
+	)
+	wordfaDiffCmd.Flags().DurationVar(
+		&wordfaDiffCliServe.Timeout,
+		"timeout", 0, "stop the task if it runs longer than `duration` (e.g. 30s, 5m), 0 for no limit",
+	)
+	wordfaDiffCmd.Flags().BoolVarP(
+		&wordfaDiffCliServe.MatchOptions.IgnoreCase,
+		"ignore_case", "i", false, "match keywords case-insensitively (Unicode case folding)",
+	)
+	wordfaDiffCmd.Flags().BoolVarP(
+		&wordfaDiffCliServe.MatchOptions.WholeWord,
+		"whole_word", "w", false, "match whole words only (for space-delimited scripts such as Latin)",
+	)
+	wordfaDiffCmd.Flags().BoolVar(
+		&wordfaDiffCliServe.MatchOptions.NormalizeWidth,
+		"normalize_width", false, "treat full-width and half-width characters as the same",
+	)
+	wordfaDiffCmd.Flags().BoolVarP(
+		&wordfaDiffCliServe.TokenMatch,
+		"token_match", "t", false, "count keywords only as whole segmented words (e.g. 是 is not counted in 不是)",
+	)
+	wordfaDiffCmd.Flags().StringVar(
+		&wordfaDiffCliServe.Segmenter,
+		"segmenter", "",
+		"word segmentation `algorithm` for -t and token counting: one of "+strings.Trim(segmentersName, ", "),
+	)
+	wordfaDiffCmd.Flags().StringVar(
+		&wordfaDiffCliServe.DictFilePath,
+		"dict", "", "dictionary `file` for word segmentation, one \"word [frequency [tag]]\" per line",
+	)
 }
@@ -6,8 +6,11 @@
 package service
 
 import (
+	"CiFa/util/sortalgo"
+	"CiFa/util/strsearch"
 	"CiFa/wordfa"
 	"encoding/json"
+	"fmt"
 	"net/http"
 )
 
@@ -23,8 +26,17 @@ type PostApiWordfaResponse struct {
 
 // GET api/wordfa 成功的返回
 type GetApiWordfaResponse struct {
-	Progress float32       `json:"progress"`
-	Result   wordfa.Result `json:"result"`
+	Progress float32         `json:"progress"` // 完成的比例 [0, 1]，只有完成时为 1
+	Status   wordfa.Progress `json:"status"`   // 详细进度: 状态、文件数、字节数、速度、剩余时间
+	Result   wordfa.Result   `json:"result"`
+	Matrix   *wordfa.Matrix  `json:"matrix,omitempty"` // 请求了 matrix 时，各词在各文件中的频数
+
+	Cooccurrence *wordfa.Cooccurrence `json:"cooccurrence,omitempty"` // 请求了 cooccurrence 时，关键词共现的稀疏矩阵
+
+	Failed bool               `json:"failed,omitempty"` // 有文件处理出错，此时 result 是其余文件的部分结果
+	Errors []wordfa.FileError `json:"errors,omitempty"` // 处理出错的文件(zip 包中的路径)及错误信息
+
+	Stopped string `json:"stopped,omitempty"` // 任务被停止(如超时)的原因，此时 progress 停留在停止时
 }
 
 // POST /api/sort/float 成功的返回
@@ -35,8 +47,59 @@ type PostApiSortFloatResponse struct {
 
 // POST /api/strsearch
 type PostApiStrsearchResponse struct {
-	Index    []int  `json:"index"`
-	TimeCost string `json:"time_cost"`
+	Index     []int                `json:"index"`
+	Positions []strsearch.Position `json:"positions,omitempty"`
+	TimeCost  string               `json:"time_cost"`
+}
+
+// /api/ws 的回复，见 ApiWs
+type WsResponse struct {
+	ID   int    `json:"id"`
+	Type string `json:"type"` // matches, steps, done 或 error
+
+	Index     []int                `json:"index,omitempty"`
+	Positions []strsearch.Position `json:"positions,omitempty"`
+	Steps     []sortalgo.Step      `json:"steps,omitempty"`
+	Result    []float64            `json:"result,omitempty"`
+
+	Count     int    `json:"count"` // matches、steps 中为本条的个数，done 中为总数
+	Truncated bool   `json:"truncated,omitempty"`
+	TimeCost  string `json:"time_cost,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// responseCsv 把文档-词矩阵 m 以 CSV 文件 filename 的形式写到 w
+func responseCsv(w *http.ResponseWriter, m *wordfa.Matrix, filename string) {
+	(*w).Header().Set("Access-Control-Allow-Origin", "*")
+	(*w).Header().Set("Content-Type", "text/csv; charset=utf-8")
+	(*w).Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	if err := m.WriteCSV(*w); err != nil {
+		http.Error(*w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// responseGraph 把关键词共现网络 c 以 format ("graphml" 或 "gexf") 格式的文件写到 w
+func responseGraph(w *http.ResponseWriter, c *wordfa.Cooccurrence, format string) {
+	(*w).Header().Set("Access-Control-Allow-Origin", "*")
+	(*w).Header().Set("Content-Type", "application/xml; charset=utf-8")
+	(*w).Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "wordfa."+format))
+	write := c.WriteGraphML
+	if format == "gexf" {
+		write = c.WriteGEXF
+	}
+	if err := write(*w); err != nil {
+		http.Error(*w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// responseEvent 将 data Marshal 成 Json，作为名为 event 的 Server-Sent Event 写到 w
+func responseEvent(w http.ResponseWriter, event string, data interface{}) error {
+	js, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, js)
+	return err
 }
 
 // responseJson 将传过来的 resp Marshal 成 Json，写到 w
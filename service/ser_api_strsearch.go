@@ -18,14 +18,20 @@ import (
 // Request:
 //		POST /api/strsearch
 // 		Body: JSON:
-//			{"algorithm": 0, "text": "abcbab", "pattern": "ab"}
+//			{"algorithm": 0, "text": "abcbab", "pattern": "ab", "index_unit": "byte"}
 //				text: 	 : string: 父字符串，在此字符串中搜索子串 pattern
 //				pattern	 : string: 子字符串，在 text 中搜索此字符串
-//				algorithm: int:    字符串搜索算法，0~3, 分别是:
-//											regexp.FindAllIndex (go lib)，KMP 算法，Rabin-Karp 算法，暴力法
+//				index_unit: string: 可选，返回的 index 的单位，byte|rune|line, 分别是:
+//											字节偏移 (默认)，字符偏移 ("第几个字")，行号
+//				algorithm: int:    字符串搜索算法，0~11, 分别是:
+//											regexp.FindAllIndex (go lib)，KMP 算法，Rabin-Karp 算法，暴力法，Aho-Corasick 自动机，
+//											Boyer-Moore 算法，Boyer-Moore-Horspool 算法，Sunday 算法，Rabin-Karp 算法 (md5)，
+//											正则表达式 (go lib)，Z 算法，Two-Way 算法。除正则表达式外，pattern 都按字面量匹配
 // Response:
-//		Success: JSON: {"index": [0, 4], "time_cost": "time cost"}	// index 是 pattern 在 text 中出现位置的索引，注意中文字符不是"第几个字"！
-//		Error:   JSON: {"error": "error description"}
+//		Success: JSON: {"index": [0, 4], "time_cost": "time cost"}	// index 是 pattern 在 text 中出现位置的索引，单位由 index_unit 指定
+//			index_unit 为 rune 或 line 时，还会返回每个匹配的完整位置:
+//				{"index": [1], "positions": [{"byte": 3, "rune": 1, "line": 1, "column": 2}], "time_cost": "time cost"}
+//		Error:   JSON: {"error": "error description"}	// 包括 algorithm 为正则表达式时 pattern 的编译错误
 func (s *Service) ApiStrsearch(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		responseJson(&w, ErrorResponse{ErrorDescription: "Request should be POST"})
@@ -43,21 +49,43 @@ func (s *Service) ApiStrsearch(w http.ResponseWriter, r *http.Request) {
 		responseJson(&w, ErrorResponse{ErrorDescription: err.Error()})
 		return
 	}
-	if body.Algorithm < 0 || body.Algorithm > 3 {
+	if !strsearch.Valid(body.Algorithm) {
 		body.Algorithm = strsearch.LibRe
 	}
+	if err := strsearch.ValidatePattern(body.Algorithm, body.Pattern); err != nil {
+		logging.Warning(fmt.Sprintf("ApiStrsearch failed: bad pattern %#v: %v", body.Pattern, err))
+		responseJson(&w, ErrorResponse{ErrorDescription: err.Error()})
+		return
+	}
+	indexUnit := strsearch.ByteIndex
+	if body.IndexUnit != "" {
+		unit, ok := strsearch.IndexUnitsMap[body.IndexUnit]
+		if !ok {
+			logging.Warning(fmt.Sprintf("ApiStrsearch failed: bad index_unit %#v", body.IndexUnit))
+			responseJson(&w, ErrorResponse{ErrorDescription: "index_unit should be one of byte, rune, line"})
+			return
+		}
+		indexUnit = unit
+	}
 	start := time.Now()
 	index := strsearch.By(body.Algorithm).FindAll(body.Text, body.Pattern)
 	elapsed := time.Since(start)
-	logging.Info(fmt.Sprintf("ApiSortFloat success: %#v", body))
-	responseJson(&w, PostApiStrsearchResponse{
+
+	resp := PostApiStrsearchResponse{
 		Index:    index,
 		TimeCost: fmt.Sprintf("%v", elapsed),
-	})
+	}
+	if indexUnit != strsearch.ByteIndex {
+		resp.Index = strsearch.ConvertIndices(body.Text, index, indexUnit)
+		resp.Positions = strsearch.Positions(body.Text, index)
+	}
+	logging.Info(fmt.Sprintf("ApiStrsearch success: %#v", body))
+	responseJson(&w, resp)
 }
 
 type apiStrsearchRequestBody struct {
 	Text      string `json:"text"`
 	Pattern   string `json:"pattern"`
 	Algorithm int    `json:"algorithm"`
+	IndexUnit string `json:"index_unit"`
 }
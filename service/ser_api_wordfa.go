@@ -8,9 +8,11 @@ package service
 import (
 	"CiFa/util"
 	"CiFa/util/logging"
+	"CiFa/util/segment"
 	"CiFa/util/sortalgo"
 	"CiFa/util/strsearch"
 	"CiFa/wordfa"
+	"context"
 	"encoding/base64"
 	"fmt"
 	"io"
@@ -54,10 +56,25 @@ func (s *Service) ApiWordfa(w http.ResponseWriter, r *http.Request) {
 // Request:
 //		GET /api/wordfa
 // 		Form:
-//			token :FormValue string: 识别客户端身份的 token
+//			token	:FormValue string: 识别客户端身份的 token
+//			matrix	:FormValue bool:   可选，任务完成时在结果中附上文档-词矩阵(各词在各文件中的频数)
+//			cooccurrence	:FormValue bool:   可选，任务完成时在结果中附上关键词共现的稀疏矩阵(需在 POST 时指定 cooccur_by)
+//			format	:FormValue string: 可选，为 "csv" 时以 CSV 文件返回文档-词矩阵，
+//									为 "graphml"、"gexf" 时以该格式的文件返回关键词共现网络，而不是 JSON
 // Response:
-//		Task Running:  JSON: {"progress": 0.7}
-//		Task Finished: JSON: {"progress": 1.0, "result": [{"keyword": 26}, {...}, ...]}
+//		Task Running:  JSON: {"progress": 0.7, "status": {"state": "running", "files": 10, "files_done": 6, "bytes": 52428800, "bytes_done": 36700160,
+//							"current_files": ["c.txt"], "elapsed": 3.5, "throughput": 10485760, "eta": 1.5}}
+//							progress 是按字节计的完成比例，status 是详细进度，state 为 pending, running, done, failed(有文件出错) 或 cancelled
+//		Task Finished: JSON: {"progress": 1.0, "result": [{"keyword": "k", "frequency": 26, "fuzzy_frequency": 3, "frequency_error": 0, "score": 1.5, "reference_frequency": 2,
+//							"snippets": [{"file": "f", "line": 1, "column": 5, "left": "..", "keyword": "k", "right": ".."}, ...]}, {...}, ...]}
+//		With matrix:   JSON: {"progress": 1.0, "result": [...], "matrix": {"files": ["a.txt", ...], "keywords": ["k", ...], "counts": [[26, ...], ...]}}
+//		With cooccurrence: JSON: {"progress": 1.0, "result": [...], "cooccurrence": {"scope": "Sentence", "units": 120,
+//							"keywords": ["k", "j", ...], "frequencies": [20, 8, ...], "pairs": [{"i": 0, "j": 1, "count": 5, "pmi": 1.9}, ...]}}
+//		With errors:   JSON: {"progress": 1.0, "result": [...], "failed": true, "errors": [{"file": "b.txt", "error": "..."}, ...]}
+//							有文件处理出错时，result 是其余文件的部分结果
+//		Task Stopped:  JSON: {"progress": 0.4, "stopped": "context deadline exceeded"}	// 任务超过服务的 task_timeout 被停止
+//		format=csv:    CSV:  file,k,...\na.txt,26,...
+//		format=graphml/gexf: XML: 关键词是节点，共现的关键词对是边
 //		Error:         JSON: {"error": "error description"}
 func (s *Service) apiWordfaGet(w http.ResponseWriter, r *http.Request) {
 	token := r.FormValue("token")
@@ -69,26 +86,84 @@ func (s *Service) apiWordfaGet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	progress := session.Task.GetProgress()
+	status := session.Task.GetStatus()
+	s.relativeStatus(token, &status)
+	progress := float32(status.Fraction())
 	var result wordfa.Result
-	if progress >= 1 {
+	var matrix *wordfa.Matrix
+	var cooccurrence *wordfa.Cooccurrence
+	format := r.FormValue("format")
+	errors := session.Task.GetErrors()
+	stopped := ""
+	if err := session.Task.Err(); err != nil {
+		stopped = err.Error()
+	}
+	for i := range errors {
+		errors[i].File = s.relativePath(token, errors[i].File)
+	}
+	if status.State.Finished() {
 		result, _ = session.Task.GetResult(session.SortAlgorithm)
+		if formBool(r, "matrix") || r.FormValue("format") == "csv" {
+			matrix, _ = session.Task.GetMatrix(session.SortAlgorithm)
+			s.relativeFiles(token, matrix)
+		}
+		if formBool(r, "cooccurrence") || format == "graphml" || format == "gexf" {
+			cooccurrence, _ = session.Task.GetCooccurrence(session.SortAlgorithm)
+		}
 	}
 
 	// 用户刚提交了新任务，还在加载中，不返回旧的结果了
 	if session.Resetting {
 		progress = 0
+		status = wordfa.Progress{}
 		result = nil
+		matrix = nil
+		cooccurrence = nil
+		errors = nil
+		stopped = ""
+	}
+
+	switch format {
+	case "graphml", "gexf":
+		if cooccurrence == nil {
+			responseJson(&w, ErrorResponse{ErrorDescription: "task not finished or co-occurrence not counted"})
+			return
+		}
+		logging.Info(fmt.Sprintf("apiWordfaGet success: token=%#v\n\t--> cooccurrence in %v", token, format))
+		responseGraph(&w, cooccurrence, format)
+		return
+	}
+	if format == "csv" {
+		if matrix == nil {
+			responseJson(&w, ErrorResponse{ErrorDescription: "task not finished"})
+			return
+		}
+		if matrix.Counts == nil {
+			responseJson(&w, ErrorResponse{ErrorDescription: wordfa.ErrNoCounts.Error()})
+			return
+		}
+		logging.Info(fmt.Sprintf("apiWordfaGet success: token=%#v\n\t--> matrix in csv", token))
+		responseCsv(&w, matrix, "wordfa.csv")
+		return
 	}
 
 	logging.Info(fmt.Sprintf(
-		"apiWordfaGet success: token=%#v\n\t--> progress: %v\n\t--> result: %v",
-		token, progress, result,
+		"apiWordfaGet success: token=%#v\n\t--> progress: %v\n\t--> result: %v\n\t--> errors: %v\n\t--> stopped: %v",
+		token, progress, result, errors, stopped,
 	))
 
 	responseJson(&w, GetApiWordfaResponse{
 		Progress: progress,
+		Status:   status,
 		Result:   result,
+		Matrix:   matrix,
+
+		Cooccurrence: cooccurrence,
+
+		Failed: len(errors) > 0,
+		Errors: errors,
+
+		Stopped: stopped,
 	})
 
 }
@@ -98,28 +173,51 @@ func (s *Service) apiWordfaGet(w http.ResponseWriter, r *http.Request) {
 //		POST /api/wordfa
 // 		Form:
 //			token		:FormValue string: 识别客户端身份的 token
-//			keywords	:FormValue string: 要检测的关键词，多个词间用逗号(',' 或 '，')隔开
+//			keywords	:FormValue string: 要检测的关键词，多个词间用逗号(',' 或 '，')隔开。
+//											可选，为空时进入词汇发现模式：对文本分词，统计所有词的频数
 //			file		:FormFile  file:   要检测的文件，单个文本文件(text/plain)，或多个文件的 zip 打包(application/zip)
 //			sort_by		:FormValue int:    结果的排序算法，0~8, 分别是:
 //											sort.Sort (go lib)，sort.Stable (go lib)，快速排序，堆排序，
 //											归并排序，希尔排序，希尔排序(并发), 插入排序，选择排序
-//			search_by	:FormValue int:    字符串搜索算法，0~3, 分别是:
-//											regexp.FindAllIndex (go lib)，KMP 算法，Rabin-Karp 算法，暴力法
+//			search_by	:FormValue int:    字符串搜索算法，0~11, 分别是:
+//											regexp.FindAllIndex (go lib)，KMP 算法，Rabin-Karp 算法，暴力法，Aho-Corasick 自动机，
+//											Boyer-Moore 算法，Boyer-Moore-Horspool 算法，Sunday 算法，Rabin-Karp 算法 (md5)，
+//											正则表达式 (go lib)，Z 算法，Two-Way 算法。除正则表达式外，关键词都按字面量匹配
+//			max_edit_distance	:FormValue int: 可选，> 0 时额外统计与关键词编辑距离不超过此值的近似匹配(按字计)，
+//											结果中的 fuzzy_frequency 即近似匹配的次数
+//			ignore_case		:FormValue bool: 可选，忽略大小写(Unicode 大小写折叠)，如 "max" 匹配 "Max"、"MAX"
+//			whole_word		:FormValue bool: 可选，只匹配完整的单词，如 "max" 不匹配 "maximum"，对中文不生效
+//			normalize_width	:FormValue bool: 可选，全角、半角字符视为相同，如 "Q" 匹配 "Ｑ"
+//											以上三个选项不能与正则表达式同时使用，请在表达式中使用 (?i)、\b 等语法
+//			context			:FormValue int:  可选，> 0 时为每个关键词收集至多这么多个 KWIC 片段(关键词及其上下文)，
+//											在 GET 的结果中以 snippets 返回
+//			context_width	:FormValue int:  可选，片段中关键词左、右各保留的字数，默认为 20
+//			top_n			:FormValue int:  可选，词汇发现、n-gram 模式中只返回频数最高的这么多个词，
+//											默认分别返回全部、100 个
+//			min_runes		:FormValue int:  可选，词汇发现模式中只统计至少有这么多个字的词
+//			token_match		:FormValue bool: 可选，分词匹配：只统计分词后完整的词，如 "是" 不计入 "不是" 中的 "是"
+//			segment_by		:FormValue int:  可选，词汇发现、分词匹配使用的分词算法，0~2, 分别是:
+//											正向最大匹配，逆向最大匹配，词图 + 动态规划
+//			dict			:FormFile  file: 可选，分词使用的词典文件，每行 "词 [词频 [词性]]"，缺省使用内置词典
+//			ngram			:FormValue int:  可选，1~5，统计最频繁的 n-gram (此时忽略 keywords)，
+//											结果的 frequency 是频数的上界，frequency - frequency_error 是下界
+//			ngram_chars		:FormValue bool: 可选，统计字 n-gram (连续的 n 个字)，否则统计分词后的词 n-gram
+//			score_by		:FormValue int:  可选，结果的评分方法，0~3, 分别是:
+//											不评分(按频数排序)，TF-IDF，对数似然比关键性，卡方关键性。评分时结果按 score 排序
+//			reference		:FormFile  file: 可选，关键性评分的参照语料，文件类型同 file，score_by 为 2、3 时必须给出
+//			cooccur_by		:FormValue int:  可选，统计关键词共现的范围，0~3, 分别是:
+//											不统计，同一句子，同一段落，相距不超过 cooccur_window 个字
+//			cooccur_window	:FormValue int:  可选，cooccur_by 为 3 时的窗口大小(字数)，默认为 20
 // Response:
 //		Success: JSON: {"success", "token"}
-//		Failed:  JSON: {"error": "error description"}
+//		Failed:  JSON: {"error": "error description"}	// 包括 search_by 为正则表达式时关键词的编译错误
 func (s *Service) apiWordfaPost(w http.ResponseWriter, r *http.Request) {
 	// 获取 token, keywords, file, algorithm
 	token := r.FormValue("token")
 
-	keywords := r.FormValue("keywords")
-	if keywords == "" {
-		logging.Warning("apiWordfaPost failed: empty keywords")
-		responseJson(&w, ErrorResponse{ErrorDescription: "Unexpected empty keywords"})
-		return
-	}
+	keywords := r.FormValue("keywords") // 为空时是词汇发现模式
 
-	// 停止该用户之前的任务
+	// 停止该用户之前的任务 (Put 新会话时也会停止旧任务)
 	s.WordFaSessionHolder.Reset(token)
 	if s, ok := s.WordFaSessionHolder.Get(token); ok {
 		s.Task.Stop()
@@ -139,28 +237,133 @@ func (s *Service) apiWordfaPost(w http.ResponseWriter, r *http.Request) {
 	}
 
 	searchAlgorithm, err := strconv.Atoi(r.FormValue("search_by"))
-	if err != nil || searchAlgorithm < 0 || searchAlgorithm > 3 {
+	if err != nil || !strsearch.Valid(searchAlgorithm) {
 		searchAlgorithm = strsearch.LibRe
 	}
 
+	maxEditDistance, err := strconv.Atoi(r.FormValue("max_edit_distance"))
+	if err != nil || maxEditDistance < 0 {
+		maxEditDistance = 0
+	}
+
+	concordance := wordfa.ConcordanceOptions{}
+	if n, err := strconv.Atoi(r.FormValue("context")); err == nil && n > 0 {
+		concordance.Snippets = n
+	}
+	if k, err := strconv.Atoi(r.FormValue("context_width")); err == nil && k > 0 {
+		concordance.Context = k
+	}
+
+	discover := wordfa.DiscoverOptions{}
+	ngram := wordfa.NGramOptions{Chars: formBool(r, "ngram_chars")}
+	if n, err := strconv.Atoi(r.FormValue("top_n")); err == nil && n > 0 {
+		discover.TopN = n
+		ngram.TopK = n
+	}
+	if v := r.FormValue("ngram"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > wordfa.MaxNGram {
+			logging.Warning("apiWordfaPost failed: bad ngram:", v)
+			responseJson(&w, ErrorResponse{ErrorDescription: fmt.Sprintf("ngram should be 1~%v", wordfa.MaxNGram)})
+			return
+		}
+		ngram.N = n
+	}
+	if n, err := strconv.Atoi(r.FormValue("min_runes")); err == nil && n > 0 {
+		discover.MinRunes = n
+	}
+
+	segmenter, err := strconv.Atoi(r.FormValue("segment_by"))
+	if err != nil || !segment.Valid(segmenter) {
+		segmenter = segment.ForwardMM
+	}
+	var dict *segment.Dictionary
+	if dictFile, _, err := r.FormFile("dict"); err == nil {
+		dict, err = segment.ReadDictionary(dictFile)
+		dictFile.Close()
+		if err != nil {
+			logging.Warning("apiWordfaPost failed: ReadDictionary Error:", err)
+			responseJson(&w, ErrorResponse{ErrorDescription: err.Error()})
+			return
+		}
+	}
+
+	scoreMethod, err := strconv.Atoi(r.FormValue("score_by"))
+	if err != nil || !wordfa.ValidScoringMethod(scoreMethod) {
+		scoreMethod = wordfa.ScoreFrequency
+	}
+
+	cooccurrence := wordfa.CooccurrenceOptions{}
+	if scope, err := strconv.Atoi(r.FormValue("cooccur_by")); err == nil && wordfa.ValidCooccurrenceScope(scope) {
+		cooccurrence.Scope = scope
+	}
+	if n, err := strconv.Atoi(r.FormValue("cooccur_window")); err == nil && n > 0 {
+		cooccurrence.Window = n
+	}
+
+	matchOptions := strsearch.MatchOptions{
+		IgnoreCase:     formBool(r, "ignore_case"),
+		WholeWord:      formBool(r, "whole_word"),
+		NormalizeWidth: formBool(r, "normalize_width"),
+	}
+	if err := strsearch.ValidateOptions(searchAlgorithm, matchOptions); err != nil {
+		logging.Warning("apiWordfaPost failed: bad match options:", err)
+		responseJson(&w, ErrorResponse{ErrorDescription: err.Error()})
+		return
+	}
+
 	// 创建新任务
 	task, err := s.buildTask(token, keywords, file, handler, searchAlgorithm)
 	if err != nil {
 		logging.Warning("apiWordfaPost failed: buildTask Error:", err)
-		responseJson(&w, ErrorResponse{ErrorDescription: "Bad keywords or file given"})
+		if _, ok := err.(*badKeywordError); ok {
+			responseJson(&w, ErrorResponse{ErrorDescription: err.Error()})
+		} else {
+			responseJson(&w, ErrorResponse{ErrorDescription: "Bad keywords or file given"})
+		}
+		return
+	}
+	task.MaxEditDistance = maxEditDistance
+	task.MatchOptions = matchOptions
+	task.Concordance = concordance
+	task.Discover = discover
+	task.NGram = ngram
+	task.Score.Method = scoreMethod
+	task.Cooccurrence = cooccurrence
+	if refFile, refHandler, err := r.FormFile("reference"); err == nil {
+		defer refFile.Close()
+		if task.Score.Reference, err = s.sourceFiles(referenceToken(token), refFile, refHandler); err != nil {
+			logging.Warning("apiWordfaPost failed: reference file Error:", err)
+			responseJson(&w, ErrorResponse{ErrorDescription: "Bad reference file given"})
+			return
+		}
+	}
+	if err := task.Score.Validate(); err != nil {
+		logging.Warning("apiWordfaPost failed: bad score options:", err)
+		responseJson(&w, ErrorResponse{ErrorDescription: err.Error()})
 		return
 	}
+	task.TokenMatch = formBool(r, "token_match")
+	task.Segmenter = segment.By(segmenter, dict)
 	// 提交任务
 	s.WordFaSessionHolder.Put(token, NewWordfaSession(task, sortAlgorithm))
 	logging.Info(
-		fmt.Sprintf("apiWordfaPost success: token=%#v\n\t--> file=%v\n\t--> keyword=%v\n\t--> sort by %v, search by %v",
-			token, task.SrcFiles, task.Patterns, sortAlgorithm, searchAlgorithm,
+		fmt.Sprintf("apiWordfaPost success: token=%#v\n\t--> file=%v\n\t--> keyword=%v\n\t--> sort by %v, search by %v, options %+v",
+			token, task.SrcFiles, task.Patterns, sortAlgorithm, searchAlgorithm, matchOptions,
 		))
 	responseJson(&w, PostApiWordfaResponse{Success: token})
 	// time.Sleep(10 * time.Second)
 	go func() {
 		// time.Sleep(1 * time.Second)
-		task.Run()
+		ctx := context.Background()
+		if s.TaskTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, s.TaskTimeout)
+			defer cancel()
+		}
+		if err := task.RunContext(ctx); err != nil {
+			logging.Warning(fmt.Sprintf("apiWordfaPost: task stopped: token=%#v: %v", token, err))
+		}
 	}()
 	// defer task.Run()
 	return
@@ -184,27 +387,92 @@ func (s *Service) buildTask(token string, keywords string, file multipart.File,
 
 	// Algorithm
 	task.StrSearchAlgorithm = searchAlgorithm
+	for _, p := range task.Patterns {
+		if err := strsearch.ValidatePattern(searchAlgorithm, p); err != nil {
+			return &task, &badKeywordError{keyword: p, err: err}
+		}
+	}
 
 	// SrcFiles
+	var err error
+	task.SrcFiles, err = s.sourceFiles(token, file, handler)
+	return &task, err
+}
+
+// sourceFiles 把请求的文件保存到 token 对应的临时目录，返回其中要统计的文本文件(zip 文件会被解压)
+func (s *Service) sourceFiles(token string, file multipart.File, handler *multipart.FileHeader) ([]string, error) {
 	dir, fp, err := s.saveFile(token, file, handler)
 	if err != nil {
-		return &task, fmt.Errorf("system error: cannot create temp file: %s", err)
+		return nil, fmt.Errorf("system error: cannot create temp file: %s", err)
 	}
 	switch handler.Header.Get("Content-Type") {
 	case "application/zip":
 		if err = util.UnzipFile(dir, fp); err != nil {
-			return &task, fmt.Errorf("system error: cannot unzip file: %s", err)
+			return nil, fmt.Errorf("system error: cannot unzip file: %s", err)
 		}
-		if task.SrcFiles, err = util.GetAllFiles(dir, "text/plain"); err != nil {
-			return &task, fmt.Errorf("system error: cannot get all files file: %s", err)
+		files, err := util.GetAllFiles(dir, "text/plain")
+		if err != nil {
+			return nil, fmt.Errorf("system error: cannot get all files file: %s", err)
 		}
+		return files, nil
 	case "text/plain":
-		task.SrcFiles = []string{fp}
-	default:
-		return &task, fmt.Errorf("unsupported file type")
+		return []string{fp}, nil
 	}
+	return nil, fmt.Errorf("unsupported file type")
+}
+
+// referenceToken 返回保存 token 的参照语料所用的 token，使参照语料与语料保存在不同的临时目录
+func referenceToken(token string) string {
+	return token + "#reference"
+}
+
+// relativeFiles 把矩阵中的文件路径转换为相对于该用户临时目录的路径，即 zip 包中的路径
+func (s *Service) relativeFiles(token string, matrix *wordfa.Matrix) {
+	if matrix == nil {
+		return
+	}
+	for i, f := range matrix.Files {
+		matrix.Files[i] = s.relativePath(token, f)
+	}
+}
+
+// relativePath 把文件路径 f 转换为相对于该用户临时目录的路径，不在该目录中时原样返回
+func (s *Service) relativePath(token string, f string) string {
+	dir, _ := s.tempFilePath(token, "")
+	if rel, err := filepath.Rel(dir, f); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return f
+}
+
+// relativeStatus 把进度 p 中正在处理的文件转换为相对于该用户临时目录的路径。
+// 同一事件的进度会发给多个订阅者，所以替换为新的切片而不是原地修改
+func (s *Service) relativeStatus(token string, p *wordfa.Progress) {
+	if len(p.CurrentFiles) == 0 {
+		return
+	}
+	files := make([]string, len(p.CurrentFiles))
+	for i, f := range p.CurrentFiles {
+		files[i] = s.relativePath(token, f)
+	}
+	p.CurrentFiles = files
+}
+
+// formBool 解析 bool 类型的 FormValue ("1", "true" 等，见 strconv.ParseBool)，缺省或不合法时为 false
+func formBool(r *http.Request, key string) bool {
+	b, err := strconv.ParseBool(r.FormValue(key))
+	return err == nil && b
+}
+
+// badKeywordError 是 buildTask 中关键词不能被所选算法搜索(如不合法的正则表达式)的错误，
+// 与系统错误不同，它的内容会返回给客户端
+type badKeywordError struct {
+	keyword string
+	err     error
+}
 
-	return &task, nil
+func (e *badKeywordError) Error() string {
+	return fmt.Sprintf("bad keyword %q: %v", e.keyword, e.err)
 }
 
 // saveFile 在临时目录里保存请求的文件
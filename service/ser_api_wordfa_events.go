@@ -0,0 +1,138 @@
+// Copyright (c) 2020 CDFMLR. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at  http://www.apache.org/licenses/LICENSE-2.0
+
+package service
+
+import (
+	"CiFa/util/logging"
+	"CiFa/wordfa"
+	"fmt"
+	"net/http"
+)
+
+// eventBuffer 是每个 SSE 连接缓存的事件数，客户端读得太慢、缓存满时丢弃新的 progress、file 事件，不阻塞任务
+const eventBuffer = 256
+
+// ApiWordfaEvents 处理 GET /api/wordfa/events, 以 Server-Sent Events 推送 wordfa 会话任务的进度与结果，代替轮询 GET /api/wordfa
+// Request:
+//		GET /api/wordfa/events
+// 		Form:
+//			token	:FormValue string: 识别客户端身份的 token
+// Response:
+//		Success: text/event-stream，每个事件的 data 是一行 JSON:
+//			event: progress		// 连接时及运行中每 200ms 一次
+//			data: {"type": "progress", "status": {"state": "running", "files": 10, "files_done": 6, ...}}
+//
+//			event: file			// 一个文件处理完成，出错时带有 error
+//			data: {"type": "file", "file": "b.txt", "error": "...", "status": {...}}
+//
+//			event: result		// 任务结束(完成、有文件出错或被停止)，内容同 GET /api/wordfa 的返回，随后关闭连接
+//			data: {"progress": 1.0, "status": {"state": "done", ...}, "result": [...], "errors": [...], "stopped": "..."}
+//		Failed:  JSON: {"error": "error description"}
+func (s *Service) ApiWordfaEvents(w http.ResponseWriter, r *http.Request) {
+	token := r.FormValue("token")
+	if token == "" {
+		logging.Warning("ApiWordfaEvents failed: bad token")
+		responseJson(&w, ErrorResponse{ErrorDescription: "Bad Token!"})
+		return
+	}
+	session, ok := s.WordFaSessionHolder.Get(token)
+	if !ok {
+		logging.Warning("ApiWordfaEvents failed: session not exist")
+		responseJson(&w, ErrorResponse{ErrorDescription: "session not exist"})
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		logging.Error("ApiWordfaEvents failed: streaming unsupported")
+		responseJson(&w, ErrorResponse{ErrorDescription: "streaming unsupported"})
+		return
+	}
+
+	events := make(chan wordfa.Event, eventBuffer)
+	end := make(chan struct{}, 1)
+	unsubscribe := session.Task.Subscribe(func(e wordfa.Event) {
+		if e.Type == wordfa.EventEnd {
+			select {
+			case end <- struct{}{}:
+			default:
+			}
+			return
+		}
+		select {
+		case events <- e:
+		default:
+		}
+	})
+	defer unsubscribe()
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	// 先推送当前进度，已经结束的任务(订阅前结束，不会再有 end 事件)直接推送结果
+	status := session.Task.GetStatus()
+	s.relativeStatus(token, &status)
+	if err := responseEvent(w, wordfa.EventProgress, wordfa.Event{Type: wordfa.EventProgress, Status: status}); err != nil {
+		return
+	}
+	flusher.Flush()
+	if status.State.Finished() || status.State == wordfa.StateCancelled {
+		s.sendWordfaResult(w, token, session)
+		return
+	}
+
+	logging.Info(fmt.Sprintf("ApiWordfaEvents: token=%#v subscribed", token))
+	for {
+		select {
+		case e := <-events:
+			if err := s.sendWordfaEvent(w, token, e); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-end:
+			// end 之前产生的事件都已在 events 中
+			for len(events) > 0 {
+				_ = s.sendWordfaEvent(w, token, <-events)
+			}
+			s.sendWordfaResult(w, token, session)
+			flusher.Flush()
+			logging.Info(fmt.Sprintf("ApiWordfaEvents: token=%#v task ended", token))
+			return
+		case <-r.Context().Done():
+			logging.Info(fmt.Sprintf("ApiWordfaEvents: token=%#v client gone", token))
+			return
+		}
+	}
+}
+
+// sendWordfaEvent 推送任务的事件 e，其中的文件转换为相对于该用户临时目录的路径
+func (s *Service) sendWordfaEvent(w http.ResponseWriter, token string, e wordfa.Event) error {
+	if e.File != "" {
+		e.File = s.relativePath(token, e.File)
+	}
+	s.relativeStatus(token, &e.Status)
+	return responseEvent(w, e.Type, e)
+}
+
+// sendWordfaResult 推送已结束的任务的结果事件，内容同 GET /api/wordfa
+func (s *Service) sendWordfaResult(w http.ResponseWriter, token string, session *WordfaSession) {
+	status := session.Task.GetStatus()
+	resp := GetApiWordfaResponse{
+		Progress: float32(status.Fraction()),
+		Status:   status,
+		Errors:   session.Task.GetErrors(),
+	}
+	for i := range resp.Errors {
+		resp.Errors[i].File = s.relativePath(token, resp.Errors[i].File)
+	}
+	resp.Failed = len(resp.Errors) > 0
+	if err := session.Task.Err(); err != nil {
+		resp.Stopped = err.Error()
+	}
+	resp.Result, _ = session.Task.GetResult(session.SortAlgorithm)
+	_ = responseEvent(w, "result", resp)
+}
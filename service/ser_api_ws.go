@@ -0,0 +1,235 @@
+// Copyright (c) 2020 CDFMLR. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at  http://www.apache.org/licenses/LICENSE-2.0
+
+package service
+
+import (
+	"CiFa/util/logging"
+	"CiFa/util/sortalgo"
+	"CiFa/util/strsearch"
+	"CiFa/util/websocket"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	wsDefaultBatch   = 1000    // 默认每条消息中的匹配位置或排序步骤数
+	wsMaxSortData    = 10000   // 排序请求的最大数据量: 排序过程不能中途取消，需限制其耗时
+	wsMaxTraceSteps  = 1 << 20 // 每个排序请求最多推送的步骤数，超出的不再推送
+	wsMaxMessageSize = 16 << 20
+)
+
+// ApiWs 处理 GET /api/ws 的 WebSocket 连接，在一个连接上交互地做字符串搜索、排序，结果分批推送
+// Request: 每个消息是一个 JSON 请求，id 由客户端指定，原样出现在对应的回复中
+//		{"id": 1, "type": "strsearch", "text": "abcbab", "pattern": "ab", "algorithm": 0, "index_unit": "byte", "batch": 1000}
+//			text, pattern, algorithm, index_unit: 同 POST /api/strsearch
+//		{"id": 2, "type": "sort", "algorithm": 2, "data": [2, 1, 3.0], "less": false, "batch": 1000}
+//			algorithm, data: 同 POST /api/sort/float，data 至多 10000 个
+//			less		 : bool: 可选，除交换外也推送比较的步骤
+//		{"id": 3, "type": "cancel"}	// 只取消正在进行的请求
+//		batch: int: 可选，每条回复中至多的匹配位置或排序步骤数，默认 1000
+//		新的请求会取消该连接上正在进行的请求，被取消的请求不再有回复，适合每次按键都发送一个请求。
+// Response: 每个消息是一个 JSON 回复
+//		strsearch: 若干 {"id": 1, "type": "matches", "index": [0, 4], "count": 2}
+//						index_unit 为 rune 或 line 时还带有 "positions"，见 POST /api/strsearch
+//				   最后 {"id": 1, "type": "done", "count": 2, "time_cost": "time cost"}	// count 是匹配总数
+//		sort:      若干 {"id": 2, "type": "steps", "steps": [{"op": "swap", "i": 0, "j": 1}, ...], "count": 1}
+//						按顺序在 data 上重放所有 swap 即得到排序的过程，见 sortalgo.Trace
+//				   最后 {"id": 2, "type": "done", "result": [1, 2, 3.0], "count": 1, "truncated": false, "time_cost": "time cost"}
+//						count 是步骤总数，步骤超过 1048576 个时 truncated 为 true，之后的步骤没有推送
+//		Error:     {"id": 1, "type": "error", "error": "error description"}
+func (s *Service) ApiWs(w http.ResponseWriter, r *http.Request) {
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		logging.Warning("ApiWs failed: Upgrade Error:", err)
+		return
+	}
+	conn.MaxMessageSize = wsMaxMessageSize
+	defer conn.Close()
+	logging.Info("ApiWs: connected", r.RemoteAddr)
+
+	cancel := context.CancelFunc(func() {})
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			logging.Info("ApiWs: disconnected", r.RemoteAddr, err)
+			return
+		}
+		var req wsRequest
+		if err := json.Unmarshal(msg, &req); err != nil {
+			logging.Warning("ApiWs: bad request:", err)
+			_ = conn.WriteJSON(WsResponse{Type: "error", Error: err.Error()})
+			continue
+		}
+
+		// 取消正在进行的请求
+		cancel()
+		wg.Wait()
+		ctx, c := context.WithCancel(context.Background())
+		cancel = c
+		if req.Type == "cancel" {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.serveWsRequest(ctx, conn, req); err != nil {
+				logging.Warning(fmt.Sprintf("ApiWs: request %v failed: %v", req.ID, err))
+				_ = conn.WriteJSON(WsResponse{ID: req.ID, Type: "error", Error: err.Error()})
+			}
+		}()
+	}
+}
+
+type wsRequest struct {
+	ID   int    `json:"id"`
+	Type string `json:"type"` // strsearch, sort 或 cancel
+
+	Text      string `json:"text"`
+	Pattern   string `json:"pattern"`
+	IndexUnit string `json:"index_unit"`
+
+	Data float64S `json:"data"`
+	Less bool     `json:"less"`
+
+	Algorithm int `json:"algorithm"`
+	Batch     int `json:"batch"`
+}
+
+// batch 返回每条回复中至多的匹配位置或排序步骤数
+func (req *wsRequest) batch() int {
+	if req.Batch <= 0 {
+		return wsDefaultBatch
+	}
+	return req.Batch
+}
+
+// serveWsRequest 处理一个请求，分批推送结果。ctx 取消后不再推送，返回 nil
+func (s *Service) serveWsRequest(ctx context.Context, conn *websocket.Conn, req wsRequest) error {
+	switch req.Type {
+	case "strsearch":
+		return s.wsStrsearch(ctx, conn, req)
+	case "sort":
+		return s.wsSort(ctx, conn, req)
+	}
+	return fmt.Errorf("unknown request type %q", req.Type)
+}
+
+// wsStrsearch 在 req.Text 中搜索 req.Pattern，按 req.batch() 分批推送匹配位置
+func (s *Service) wsStrsearch(ctx context.Context, conn *websocket.Conn, req wsRequest) error {
+	if !strsearch.Valid(req.Algorithm) {
+		req.Algorithm = strsearch.LibRe
+	}
+	if err := strsearch.ValidatePattern(req.Algorithm, req.Pattern); err != nil {
+		return err
+	}
+	indexUnit := strsearch.ByteIndex
+	if req.IndexUnit != "" {
+		unit, ok := strsearch.IndexUnitsMap[req.IndexUnit]
+		if !ok {
+			return fmt.Errorf("index_unit should be one of byte, rune, line")
+		}
+		indexUnit = unit
+	}
+
+	par := strsearch.ParallelOptions{Workers: 1}
+	if !strsearch.Chunkable(req.Algorithm) {
+		par.ChunkSize = len(req.Text)
+	}
+	start := time.Now()
+	found, err := strsearch.MultiByContext(ctx, req.Algorithm).FindAllBytesContext(ctx, []byte(req.Text), []string{req.Pattern},
+		strsearch.MatchOptions{}, par)
+	if err != nil {
+		return nil // 被新的请求取消
+	}
+	elapsed := time.Since(start)
+	index := found[req.Pattern]
+	var positions []strsearch.Position
+	if indexUnit != strsearch.ByteIndex {
+		positions = strsearch.Positions(req.Text, index)
+		index = strsearch.ConvertIndices(req.Text, index, indexUnit)
+	}
+
+	for i := 0; i < len(index); i += req.batch() {
+		if ctx.Err() != nil {
+			return nil
+		}
+		j := i + req.batch()
+		if j > len(index) {
+			j = len(index)
+		}
+		resp := WsResponse{ID: req.ID, Type: "matches", Index: index[i:j], Count: j - i}
+		if positions != nil {
+			resp.Positions = positions[i:j]
+		}
+		if err := conn.WriteJSON(resp); err != nil {
+			return nil // 连接已断开
+		}
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	logging.Info(fmt.Sprintf("ApiWs strsearch success: id=%v, pattern=%#v, %v matches", req.ID, req.Pattern, len(index)))
+	_ = conn.WriteJSON(WsResponse{ID: req.ID, Type: "done", Count: len(index), TimeCost: fmt.Sprintf("%v", elapsed)})
+	return nil
+}
+
+// wsSort 用 req.Algorithm 排序 req.Data，按 req.batch() 分批推送排序的步骤
+func (s *Service) wsSort(ctx context.Context, conn *websocket.Conn, req wsRequest) error {
+	if len(req.Data) > wsMaxSortData {
+		return fmt.Errorf("data should have at most %v numbers", wsMaxSortData)
+	}
+	if req.Algorithm < 0 || req.Algorithm > 8 {
+		req.Algorithm = sortalgo.StlSort
+	}
+
+	var batch []sortalgo.Step
+	count := 0
+	send := func() {
+		if len(batch) > 0 && ctx.Err() == nil {
+			_ = conn.WriteJSON(WsResponse{ID: req.ID, Type: "steps", Steps: batch, Count: len(batch)})
+		}
+		batch = batch[:0]
+	}
+	traced := sortalgo.Trace(req.Data, req.Less, func(step sortalgo.Step) {
+		count++
+		if count > wsMaxTraceSteps || ctx.Err() != nil {
+			return // 排序不能中途停止，只是不再推送
+		}
+		batch = append(batch, step)
+		if len(batch) >= req.batch() {
+			send()
+		}
+	})
+
+	start := time.Now()
+	if len(req.Data) > 1 {
+		sortalgo.By(req.Algorithm).Sort(traced)
+	}
+	elapsed := time.Since(start)
+	send()
+	if ctx.Err() != nil {
+		return nil
+	}
+	logging.Info(fmt.Sprintf("ApiWs sort success: id=%v, algorithm=%v, %v steps", req.ID, req.Algorithm, count))
+	_ = conn.WriteJSON(WsResponse{
+		ID:        req.ID,
+		Type:      "done",
+		Result:    req.Data,
+		Count:     count,
+		Truncated: count > wsMaxTraceSteps,
+		TimeCost:  fmt.Sprintf("%v", elapsed),
+	})
+	return nil
+}
@@ -8,12 +8,14 @@ package service
 import (
 	"CiFa/util/logging"
 	"net/http"
+	"time"
 )
 
 type Service struct {
 	WordFaSessionHolder *WordFaSessionHolder
 	StaticDir           string
 	TempDirPrefix       string
+	TaskTimeout         time.Duration // wordfa 任务的最长运行时间，超时的任务被停止。为 0 时不限
 
 	fileServer http.Handler
 }
@@ -35,10 +37,14 @@ func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	switch r.URL.Path {
 	case "/api/wordfa":
 		s.ApiWordfa(w, r)
+	case "/api/wordfa/events":
+		s.ApiWordfaEvents(w, r)
 	case "/api/sort/float":
 		s.ApiSortFloat(w, r)
 	case "/api/strsearch":
 		s.ApiStrsearch(w, r)
+	case "/api/ws":
+		s.ApiWs(w, r)
 	default:
 		// 对于其他 URL Path，使用 StaticDir 上的文件服务
 		// 例如: GET /index.html 返回文件 $StaticDir/index.html
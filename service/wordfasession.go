@@ -37,10 +37,14 @@ func NewWordFaSessionHolder() *WordFaSessionHolder {
 	}
 }
 
+// Put 保存 token 的会话 s，替换掉的旧会话的任务会被停止
 func (w *WordFaSessionHolder) Put(token string, s *WordfaSession) {
 	w.mux.Lock()
 	defer w.mux.Unlock()
 
+	if old, ok := w.sessionMap[token]; ok && old.Task != s.Task {
+		old.Task.Stop()
+	}
 	w.sessionMap[token] = s
 }
 
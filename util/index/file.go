@@ -0,0 +1,172 @@
+// Copyright (c) 2020 CDFMLR. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at  http://www.apache.org/licenses/LICENSE-2.0
+
+package index
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+)
+
+// magic 是索引文件的文件头，最后一个字节是格式版本
+var magic = []byte("CIFAIDX\x01")
+
+// ErrBadFormat 表示读取的不是(当前版本的)索引文件，或文件已损坏
+var ErrBadFormat = errors.New("index: bad index file format")
+
+// maxPathLen 是索引文件中路径长度的上限
+const maxPathLen = 1 << 16
+
+// Save 把索引保存到文件 path
+func (x *Index) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := x.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Load 从文件 path 载入索引
+func Load(path string) (*Index, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// Write 把索引写入 w。格式(整数都是小端序):
+//		magic
+//		文件数 uint32，每个文件: 路径长度 uint32，路径，Size int64，ModTime int64，Start int64
+//		语料长度 uint64，语料，后缀数组 [n]int32，LCP 数组 [n]int32
+func (x *Index) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	le := binary.LittleEndian
+	write := func(data interface{}) error {
+		return binary.Write(bw, le, data)
+	}
+
+	if _, err := bw.Write(magic); err != nil {
+		return err
+	}
+	if err := write(uint32(len(x.files))); err != nil {
+		return err
+	}
+	for _, f := range x.files {
+		if err := write(uint32(len(f.Path))); err != nil {
+			return err
+		}
+		if _, err := bw.WriteString(f.Path); err != nil {
+			return err
+		}
+		if err := write([]int64{f.Size, f.ModTime, int64(f.Start)}); err != nil {
+			return err
+		}
+	}
+	if err := write(uint64(len(x.text))); err != nil {
+		return err
+	}
+	if _, err := bw.Write(x.text); err != nil {
+		return err
+	}
+	if err := write(x.sa); err != nil {
+		return err
+	}
+	if err := write(x.lcp); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// Read 从 r 读取 Write 写入的索引。
+// 文件中的各长度都经过检查：损坏的文件返回 ErrBadFormat，不会按其中的长度分配过大的内存
+func Read(r io.Reader) (*Index, error) {
+	br := bufio.NewReader(r)
+	le := binary.LittleEndian
+	read := func(data interface{}) error {
+		err := binary.Read(br, le, data)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return ErrBadFormat
+		}
+		return err
+	}
+
+	head := make([]byte, len(magic))
+	if _, err := io.ReadFull(br, head); err != nil || string(head) != string(magic) {
+		return nil, ErrBadFormat
+	}
+
+	x := &Index{}
+	var nFiles uint32
+	if err := read(&nFiles); err != nil {
+		return nil, err
+	}
+	for i := uint32(0); i < nFiles; i++ {
+		var pathLen uint32
+		if err := read(&pathLen); err != nil {
+			return nil, err
+		}
+		if pathLen > maxPathLen {
+			return nil, ErrBadFormat
+		}
+		path := make([]byte, pathLen)
+		if _, err := io.ReadFull(br, path); err != nil {
+			return nil, ErrBadFormat
+		}
+		meta := make([]int64, 3)
+		if err := read(meta); err != nil {
+			return nil, err
+		}
+		x.files = append(x.files, File{Path: string(path), Size: meta[0], ModTime: meta[1], Start: int(meta[2])})
+	}
+
+	var n uint64
+	if err := read(&n); err != nil {
+		return nil, err
+	}
+	if n > math.MaxInt32 { // 后缀数组是 []int32
+		return nil, ErrBadFormat
+	}
+	start := 0
+	for _, f := range x.files {
+		if f.Start < start || f.Start > int(n) { // Locate 要求 Start 递增
+			return nil, ErrBadFormat
+		}
+		start = f.Start
+	}
+	// 语料逐步读入，长度 n 大于实际的数据时不会预先分配 n 字节。读完语料后 n 就是可信的
+	var text bytes.Buffer
+	if m, err := io.CopyN(&text, br, int64(n)); m != int64(n) {
+		if err == nil || err == io.EOF {
+			err = ErrBadFormat
+		}
+		return nil, err
+	}
+	x.text = text.Bytes()
+	x.sa = make([]int32, n)
+	if err := read(x.sa); err != nil {
+		return nil, err
+	}
+	for _, i := range x.sa {
+		if i < 0 || int64(i) >= int64(n) {
+			return nil, ErrBadFormat
+		}
+	}
+	x.lcp = make([]int32, n)
+	if err := read(x.lcp); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
@@ -0,0 +1,195 @@
+// Copyright (c) 2020 CDFMLR. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at  http://www.apache.org/licenses/LICENSE-2.0
+
+// Package index 为文本语料建立后缀数组索引，可以保存到磁盘，
+// 之后对任意关键词的频数查询只需在后缀数组上二分查找，时间 O(m log n)，不必重新扫描语料。
+//
+// Usage:
+//
+// 	建立、保存索引:
+// 		idx, err := index.Build(files)
+// 		err = idx.Save("corpus.idx")
+//
+// 	载入索引、查询:
+// 		idx, err := index.Load("corpus.idx")
+// 		idx.Count("关键词")		// 出现次数
+// 		idx.Lookup("关键词")		// 所有出现位置(在语料中的字节偏移)
+// 		idx.Locate(pos)			// 语料中的偏移 -> 文件、文件中的偏移
+//
+// 后缀数组由 SA-IS 算法构建，LCP 数组由 Kasai 算法构建，都是 O(n) 的。
+// 各文件的内容以 '\x00' 分隔后拼接成一个语料，不含 '\x00' 的关键词的匹配不会跨越文件。
+package index
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+)
+
+// Separator 是语料中分隔各文件内容的字节
+const Separator = '\x00'
+
+// File 是索引中的一个源文件
+type File struct {
+	Path    string // 文件路径
+	Size    int64  // 建立索引时的文件大小
+	ModTime int64  // 建立索引时的修改时间 (UnixNano)
+	Start   int    // 文件内容在语料中的起点
+}
+
+// Index 是语料的后缀数组索引
+type Index struct {
+	files []File
+	text  []byte  // 语料: 各文件内容以 Separator 分隔拼接
+	sa    []int32 // 后缀数组: text[sa[i]:] 是第 i 小的后缀
+	lcp   []int32 // LCP 数组: lcp[i] 是 text[sa[i-1]:] 与 text[sa[i]:] 的最长公共前缀长度
+}
+
+// Build 读取 paths 中的所有文件，为它们建立索引
+func Build(paths []string) (*Index, error) {
+	var files []File
+	var text []byte
+	for i, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			text = append(text, Separator)
+		}
+		if len(text)+len(data) > math.MaxInt32 {
+			return nil, errors.New("index: corpus too large (more than 2 GiB)")
+		}
+		files = append(files, File{Path: p, Size: info.Size(), ModTime: info.ModTime().UnixNano(), Start: len(text)})
+		text = append(text, data...)
+	}
+	idx := New(text)
+	idx.files = files
+	return idx, nil
+}
+
+// New 为 text 建立索引，索引中没有文件信息
+func New(text []byte) *Index {
+	sa := suffixArray(text)
+	return &Index{
+		text: text,
+		sa:   sa,
+		lcp:  lcpArray(text, sa),
+	}
+}
+
+// Files 返回建立索引的所有文件
+func (x *Index) Files() []File {
+	return x.files
+}
+
+// Paths 返回建立索引的所有文件的路径
+func (x *Index) Paths() []string {
+	paths := make([]string, 0, len(x.files))
+	for _, f := range x.files {
+		paths = append(paths, f.Path)
+	}
+	return paths
+}
+
+// Len 返回语料的长度(字节)
+func (x *Index) Len() int {
+	return len(x.text)
+}
+
+// SuffixArray 返回后缀数组，调用者不应修改它
+func (x *Index) SuffixArray() []int32 {
+	return x.sa
+}
+
+// LCP 返回 LCP 数组，调用者不应修改它
+func (x *Index) LCP() []int32 {
+	return x.lcp
+}
+
+// lookup 返回前缀为 pattern 的所有后缀在后缀数组中的范围 [lo, hi)
+func (x *Index) lookup(pattern string) (lo, hi int) {
+	if len(pattern) == 0 {
+		return 0, 0
+	}
+	p := []byte(pattern)
+	prefix := func(i int) []byte {
+		s := x.text[x.sa[i]:]
+		if len(s) > len(p) {
+			s = s[:len(p)]
+		}
+		return s
+	}
+	lo = sort.Search(len(x.sa), func(i int) bool {
+		return bytes.Compare(prefix(i), p) >= 0
+	})
+	hi = lo + sort.Search(len(x.sa)-lo, func(i int) bool {
+		return bytes.Compare(prefix(lo+i), p) > 0
+	})
+	return lo, hi
+}
+
+// Count 返回 pattern 在语料中的出现次数，时间 O(m log n)
+func (x *Index) Count(pattern string) int {
+	lo, hi := x.lookup(pattern)
+	return hi - lo
+}
+
+// CountByFile 返回 pattern 在各文件中的出现次数 {"文件": 次数}，不含没有出现的文件
+func (x *Index) CountByFile(pattern string) map[string]int {
+	lo, hi := x.lookup(pattern)
+	counts := map[string]int{}
+	for _, p := range x.sa[lo:hi] {
+		file, _ := x.Locate(int(p))
+		counts[file]++
+	}
+	return counts
+}
+
+// Lookup 返回 pattern 在语料中的所有出现位置(升序的字节偏移)
+func (x *Index) Lookup(pattern string) []int {
+	lo, hi := x.lookup(pattern)
+	if lo == hi {
+		return nil
+	}
+	indices := make([]int, 0, hi-lo)
+	for _, p := range x.sa[lo:hi] {
+		indices = append(indices, int(p))
+	}
+	sort.Ints(indices)
+	return indices
+}
+
+// Locate 把语料中的字节偏移 pos 转换为所在的文件、在该文件中的字节偏移，
+// 索引中没有文件信息时 file 为 ""
+func (x *Index) Locate(pos int) (file string, offset int) {
+	i := sort.Search(len(x.files), func(i int) bool { return x.files[i].Start > pos }) - 1
+	if i < 0 {
+		return "", pos
+	}
+	return x.files[i].Path, pos - x.files[i].Start
+}
+
+// Verify 检查建立索引的文件自建立索引以来是否被修改过(大小或修改时间变化)
+func (x *Index) Verify() error {
+	for _, f := range x.files {
+		info, err := os.Stat(f.Path)
+		if err != nil {
+			return err
+		}
+		if info.Size() != f.Size || info.ModTime().UnixNano() != f.ModTime {
+			return fmt.Errorf("index: %s has been modified since the index was built", f.Path)
+		}
+	}
+	return nil
+}
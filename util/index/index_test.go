@@ -0,0 +1,177 @@
+// Copyright (c) 2020 CDFMLR. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at  http://www.apache.org/licenses/LICENSE-2.0
+
+package index
+
+import (
+	"CiFa/util/strsearch"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// naiveSuffixArray 直接排序所有后缀，用于验证
+func naiveSuffixArray(text []byte) []int32 {
+	sa := make([]int32, len(text))
+	for i := range sa {
+		sa[i] = int32(i)
+	}
+	sort.Slice(sa, func(i, j int) bool {
+		return bytes.Compare(text[sa[i]:], text[sa[j]:]) < 0
+	})
+	return sa
+}
+
+func TestSuffixArray(t *testing.T) {
+	texts := []string{"", "a", "aaaaaaaa", "banana", "mississippi", "abracadabra", "阿Q正传，阿Q正传。", "\x00\xff\x00\xff"}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		b := make([]byte, r.Intn(300))
+		for j := range b {
+			b[j] = "abc"[r.Intn(3)]
+		}
+		texts = append(texts, string(b))
+	}
+	for _, text := range texts {
+		want := naiveSuffixArray([]byte(text))
+		sa := suffixArray([]byte(text))
+		if !reflect.DeepEqual(sa, want) {
+			t.Fatalf("suffixArray(%q) = %v, want %v", text, sa, want)
+		}
+		lcp := lcpArray([]byte(text), sa)
+		for i := 1; i < len(sa); i++ {
+			a, b := text[sa[i-1]:], text[sa[i]:]
+			h := 0
+			for h < len(a) && h < len(b) && a[h] == b[h] {
+				h++
+			}
+			if int(lcp[i]) != h {
+				t.Fatalf("lcpArray(%q)[%v] = %v, want %v", text, i, lcp[i], h)
+			}
+		}
+	}
+}
+
+func TestIndex(t *testing.T) {
+	data, err := ioutil.ReadFile("../strsearch/testing_text.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// 拆成两个文件，第二个文件以 "阿" 开头、第一个以 "阿" 结尾时，"阿阿" 不应跨文件匹配
+	half := len(data) / 2
+	files := []string{filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt")}
+	contents := [][]byte{append(data[:half:half], "阿"...), append([]byte("阿"), data[half:]...)}
+	for i, f := range files {
+		if err := ioutil.WriteFile(f, contents[i], 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	idx, err := Build(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "corpus.idx")
+	if err := idx.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, idx) {
+		t.Errorf("Load() differs from the saved index")
+	}
+	if err := loaded.Verify(); err != nil {
+		t.Errorf("Verify() = %v", err)
+	}
+
+	for _, p := range []string{"阿Ｑ", "阿", "一九一八年", "那么，", "他们", "阿阿", "没有的东西", ""} {
+		want := 0
+		for _, c := range contents {
+			want += len(strsearch.KmpSearch(string(c), p, -1))
+		}
+		if got := loaded.Count(p); got != want {
+			t.Errorf("Count(%q) = %v, want %v", p, got, want)
+		}
+		if got := loaded.Lookup(p); len(got) != want || !sort.IntsAreSorted(got) {
+			t.Errorf("Lookup(%q) got %v positions, want %v", p, len(got), want)
+		}
+		byFile := loaded.CountByFile(p)
+		for i, c := range contents {
+			if got, n := byFile[files[i]], len(strsearch.KmpSearch(string(c), p, -1)); got != n {
+				t.Errorf("CountByFile(%q)[%v] = %v, want %v", p, files[i], got, n)
+			}
+		}
+	}
+
+	pos := loaded.Lookup("一九一八年")
+	for _, p := range pos {
+		file, offset := loaded.Locate(p)
+		i := 0
+		if file == files[1] {
+			i = 1
+		}
+		if !bytes.HasPrefix(contents[i][offset:], []byte("一九一八年")) {
+			t.Errorf("Locate(%v) = %v, %v: not a match", p, file, offset)
+		}
+	}
+
+	if _, err := Read(bytes.NewReader([]byte("not an index"))); err != ErrBadFormat {
+		t.Errorf("Read(garbage) error = %v, want ErrBadFormat", err)
+	}
+	if err := ioutil.WriteFile(files[0], []byte("modified"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.Verify(); err == nil {
+		t.Errorf("Verify() = nil after the source file was modified")
+	}
+}
+
+func TestReadCorrupt(t *testing.T) {
+	x := New([]byte("banana"))
+	x.files = []File{{Path: "a.txt"}}
+	var buf bytes.Buffer
+	if err := x.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if got, err := Read(bytes.NewReader(data)); err != nil || !reflect.DeepEqual(got, x) {
+		t.Fatalf("Read() = %v, %v", got, err)
+	}
+
+	// 偏移: magic 8，文件数 4，路径长度 @12，路径 "a.txt" @16，Size/ModTime/Start @21，语料长度 @45，语料 @53，后缀数组 @59
+	corrupt := func(off int, b ...byte) []byte {
+		c := append([]byte(nil), data...)
+		copy(c[off:], b)
+		return c
+	}
+	tests := map[string][]byte{
+		"huge path":  corrupt(12, 0xff, 0xff, 0xff, 0x7f),
+		"huge text":  corrupt(45, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f),
+		"long text":  corrupt(45, 0xff, 0xff, 0xff, 0x0f),
+		"bad start":  corrupt(37, 7),
+		"bad suffix": corrupt(59, 6),
+	}
+	for n := 0; n < len(data); n++ {
+		tests[fmt.Sprintf("truncated %v", n)] = data[:n]
+	}
+	for name, c := range tests {
+		if _, err := Read(bytes.NewReader(c)); err != ErrBadFormat {
+			t.Errorf("%v: Read() error = %v, want ErrBadFormat", name, err)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright (c) 2020 CDFMLR. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at  http://www.apache.org/licenses/LICENSE-2.0
+
+package index
+
+// suffixArray 用 SA-IS 算法计算 text 的后缀数组，时间、空间 O(n)
+func suffixArray(text []byte) []int32 {
+	// 每个字节 +1，末尾加上唯一且最小的哨兵 0
+	t := make([]int32, len(text)+1)
+	for i, c := range text {
+		t[i] = int32(c) + 1
+	}
+	return sais(t, 256+1)[1:]
+}
+
+// sais 计算 t 的后缀数组 (SA-IS: Nong, Zhang & Chan, 2009)。
+// t 中的字符取值 [0, k)，且 t 以唯一的最小字符 0 结尾。
+func sais(t []int32, k int) []int32 {
+	n := len(t)
+	sa := make([]int32, n)
+	if n == 1 {
+		return sa
+	}
+
+	// 后缀类型: stype[i] 为 true 表示 S 型(t[i:] < t[i+1:])，否则为 L 型
+	stype := make([]bool, n)
+	stype[n-1] = true
+	for i := n - 2; i >= 0; i-- {
+		stype[i] = t[i] < t[i+1] || (t[i] == t[i+1] && stype[i+1])
+	}
+	isLMS := func(i int32) bool {
+		return i > 0 && stype[i] && !stype[i-1]
+	}
+
+	counts := make([]int32, k)
+	for _, c := range t {
+		counts[c]++
+	}
+	bucket := make([]int32, k)
+	bucketStarts := func() {
+		var sum int32
+		for c, cnt := range counts {
+			bucket[c] = sum
+			sum += cnt
+		}
+	}
+	bucketEnds := func() {
+		var sum int32
+		for c, cnt := range counts {
+			sum += cnt
+			bucket[c] = sum
+		}
+	}
+	// induce 由已放入桶尾的 LMS 后缀诱导排序 L 型、S 型后缀
+	induce := func() {
+		bucketStarts()
+		for i := 0; i < n; i++ {
+			if j := sa[i] - 1; sa[i] > 0 && !stype[j] {
+				sa[bucket[t[j]]] = j
+				bucket[t[j]]++
+			}
+		}
+		bucketEnds()
+		for i := n - 1; i >= 0; i-- {
+			if j := sa[i] - 1; sa[i] > 0 && stype[j] {
+				bucket[t[j]]--
+				sa[bucket[t[j]]] = j
+			}
+		}
+	}
+
+	// 1. 把 LMS 后缀放入各自的桶尾，诱导排序得到 LMS 子串的顺序
+	for i := range sa {
+		sa[i] = -1
+	}
+	bucketEnds()
+	for i := int32(1); i < int32(n); i++ {
+		if isLMS(i) {
+			bucket[t[i]]--
+			sa[bucket[t[i]]] = i
+		}
+	}
+	induce()
+
+	// 2. 按顺序给 LMS 子串命名，相同的子串同名
+	m := 0
+	for i := 0; i < n; i++ {
+		if isLMS(sa[i]) {
+			sa[m] = sa[i]
+			m++
+		}
+	}
+	for i := m; i < n; i++ {
+		sa[i] = -1
+	}
+	name := int32(0)
+	prev := int32(-1)
+	for i := 0; i < m; i++ {
+		pos := sa[i]
+		if prev < 0 || !lmsEqual(t, stype, isLMS, prev, pos) {
+			name++
+		}
+		prev = pos
+		sa[m+int(pos)/2] = name - 1 // 相邻 LMS 至少相隔 2，pos/2 互不相同
+	}
+	s1 := make([]int32, 0, m)
+	for i := m; i < n; i++ {
+		if sa[i] >= 0 {
+			s1 = append(s1, sa[i])
+		}
+	}
+
+	// 3. 名字不唯一时递归求解缩减后的问题，得到 LMS 后缀的顺序
+	var sa1 []int32
+	if int(name) < m {
+		sa1 = sais(s1, int(name))
+	} else {
+		sa1 = make([]int32, m)
+		for i, c := range s1 {
+			sa1[c] = int32(i)
+		}
+	}
+	lms := s1[:0] // s1 已经用完，复用其空间保存 LMS 的位置
+	for i := int32(1); i < int32(n); i++ {
+		if isLMS(i) {
+			lms = append(lms, i)
+		}
+	}
+	for i := range sa1 {
+		sa1[i] = lms[sa1[i]]
+	}
+
+	// 4. 按正确顺序把 LMS 后缀放入桶尾，再诱导排序一次得到最终结果
+	for i := range sa {
+		sa[i] = -1
+	}
+	bucketEnds()
+	for i := m - 1; i >= 0; i-- {
+		p := sa1[i]
+		bucket[t[p]]--
+		sa[bucket[t[p]]] = p
+	}
+	induce()
+	return sa
+}
+
+// lmsEqual 判断以 a、b 开头的两个 LMS 子串是否相同
+func lmsEqual(t []int32, stype []bool, isLMS func(int32) bool, a, b int32) bool {
+	for d := int32(0); ; d++ {
+		if t[a+d] != t[b+d] || stype[a+d] != stype[b+d] {
+			return false
+		}
+		if d > 0 && (isLMS(a+d) || isLMS(b+d)) {
+			return isLMS(a+d) && isLMS(b+d)
+		}
+	}
+}
+
+// lcpArray 用 Kasai 算法计算最长公共前缀数组，时间 O(n)：
+// lcp[i] 是后缀 text[sa[i-1]:] 与 text[sa[i]:] 的最长公共前缀长度，lcp[0] = 0
+func lcpArray(text []byte, sa []int32) []int32 {
+	n := len(text)
+	rank := make([]int32, n)
+	for i, p := range sa {
+		rank[p] = int32(i)
+	}
+	lcp := make([]int32, n)
+	h := 0
+	for i := 0; i < n; i++ {
+		if rank[i] == 0 {
+			h = 0
+			continue
+		}
+		j := int(sa[rank[i]-1])
+		for i+h < n && j+h < n && text[i+h] == text[j+h] {
+			h++
+		}
+		lcp[rank[i]] = int32(h)
+		if h > 0 {
+			h--
+		}
+	}
+	return lcp
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2020 CDFMLR. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at  http://www.apache.org/licenses/LICENSE-2.0
+
+package segment
+
+import "math"
+
+// MaxProbability 是基于词图 (DAG) 与动态规划的分词器：
+// 把文本中所有可能的词(词典中有的词，以及单个原子)连成有向无环图，
+// 用动态规划找出各词概率(词频 / 总词频)之积最大的切分路径。
+// 词典中没有的词按词频 1 计。它比最大匹配更依赖词典中的词频
+type MaxProbability struct {
+	Dict *Dictionary // 使用的词典，为 nil 时使用 BuiltinDictionary()
+}
+
+// Segment 用最大概率路径把 text 切分为词
+func (m *MaxProbability) Segment(text string) []string {
+	dict := orBuiltin(m.Dict)
+	logTotal := math.Log(float64(dict.total + 1))
+	return segmentRuns(text, func(run string, bounds []int) []string {
+		n := len(bounds) - 1
+		// route[i]: 从第 i 个原子到串尾的最大对数概率，next[i]: 该路径上第一个词的终点
+		route := make([]float64, n+1)
+		next := make([]int, n)
+		for i := n - 1; i >= 0; i-- {
+			route[i] = math.Inf(-1)
+			last := i + dict.maxRunes
+			if last > n {
+				last = n
+			} else if last <= i {
+				last = i + 1
+			}
+			for j := i + 1; j <= last; j++ {
+				freq, ok := dict.freq[run[bounds[i]:bounds[j]]]
+				if !ok {
+					if j > i+1 {
+						continue
+					}
+					freq = 1
+				}
+				// 概率相同时取较长的词
+				if p := math.Log(float64(freq)) - logTotal + route[j]; p >= route[i] {
+					route[i], next[i] = p, j
+				}
+			}
+		}
+		var words []string
+		for i := 0; i < n; i = next[i] {
+			words = append(words, run[bounds[i]:bounds[next[i]]])
+		}
+		return words
+	})
+}
@@ -0,0 +1,39 @@
+// Copyright (c) 2020 CDFMLR. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at  http://www.apache.org/licenses/LICENSE-2.0
+
+package segment
+
+// builtinWords 是内置词典的词表(以空白分隔)，收录常用的现代汉语多字词。
+// 单字不需要收录：词典中找不到的字会被单独切分为一个词。
+// 内置词典较小，只保证开箱可用；专业语料请使用自己的词典文件。
+const builtinWords = `
+我们 你们 他们 她们 它们 咱们 自己 大家 别人 人家 人们 这个 那个 这些 那些 这样 那样 这么 那么 这里 那里
+这儿 那儿 哪里 哪儿 什么 怎么 怎样 怎么样 为什么 多少 几个 每个 各个 各种 一切 所有 其他 其它 其中 有些 一些
+一点 一下 一样 一起 一定 一直 一般 一面 一边 一种 一个 一次 一天 一回 一件 一时 一向 一齐 一同 一半 一会儿
+没有 不是 就是 还是 只是 可是 但是 于是 或是 总是 要是 若是 倘若 如果 假如 即使 虽然 虽说 尽管 不过 然而
+而且 并且 而况 何况 况且 因为 所以 因此 于是 以便 以免 只要 只有 除非 无论 不论 不管 既然 至于 关于 对于
+由于 为了 通过 按照 根据 除了 以及 以后 以前 之后 之前 之间 之中 之下 之上 以上 以下 以外 以内 当时 同时
+时候 时间 时光 时代 时期 现在 过去 将来 未来 以来 后来 从来 向来 本来 原来 起来 出来 进来 回来 过来 上来
+下来 回去 出去 进去 过去 上去 下去 起去 已经 曾经 正在 马上 立刻 终于 究竟 到底 毕竟 居然 竟然 果然 忽然
+突然 仍然 依然 当然 自然 显然 固然 偶然 渐渐 慢慢 常常 往往 时时 处处 刚才 刚刚 方才 早已 早就 始终 永远
+非常 十分 特别 尤其 格外 更加 越发 稍微 几乎 差不多 大概 也许 或者 恐怕 似乎 好像 仿佛 简直 实在 的确 确实
+真是 还有 还要 也是 也有 都是 都有 只得 只好 不得 不能 不会 不要 不必 不用 不肯 不敢 不再 不但 不仅 不如
+可以 可能 能够 应该 应当 必须 需要 愿意 希望 知道 觉得 认为 以为 想到 看到 看见 听到 听见 看出 发现 感到
+感觉 记得 忘记 忘却 明白 懂得 相信 怀疑 担心 害怕 喜欢 讨厌 生气 高兴 快活 痛苦 寂寞 悲哀 可怜 可惜 奇怪
+说话 说道 告诉 回答 问题 事情 东西 地方 样子 意思 道理 办法 方法 原因 结果 关系 情形 情况 情理 状态 经验
+开始 结束 继续 进行 发生 出现 成为 变成 作为 成了 做了 有了 到了 去了 来了 走了 看了 说了 想了 知道了
+世界 社会 国家 人民 政府 革命 历史 文化 文学 文章 小说 作品 作者 读者 青年 少年 老人 孩子 儿子 女儿 父亲
+母亲 先生 太太 老爷 少爷 小姐 姑娘 女人 男人 朋友 同学 学生 教师 教员 医生 病人 主人 客人 邻居 本家 亲戚
+中国 日本 外国 北京 上海 城里 乡下 村里 街上 家里 屋里 门口 店里 酒店 茶馆 学堂 学校 衙门
+眼睛 脸上 头上 手里 身上 心里 嘴里 背后 面前 旁边 对面 中间 外面 里面 上面 下面 前面 后面 左右 周围 附近
+晚上 早上 上午 下午 中午 夜里 白天 今天 明天 昨天 今年 明年 去年 当初 从前 以往 平时 平常 那时 这时 此时
+第一 第二 第三 一月 四月 五月 十月 许多 很多 不少 大半 一部分 部分 全部 全体 整个 无数 少数 多数
+工作 生活 生命 精神 思想 感情 希望 理想 梦想 记忆 回忆 印象 声音 颜色 眼光 目光 光阴 风景 天气 月亮 太阳
+秀才 尼姑 自传 故乡 药店 柜台 辫子 革命党 造反 赌钱 喝酒 吃饭 睡觉 做工 打架
+笑话 笑声 哭声 大笑 冷笑 微笑 说笑 看客 闲人 众人 旁人 各人 个人 本人 他人 人物 人生 人间 人类 人心 人家
+觉悟 希望 绝望 失望 悲凉 凄凉 热闹 冷清 安静 平安 危险 困难 容易 简单 复杂 重要 必要 主要 一般 普通 特殊
+出身 姓名 名字 字号 名目 题目 文字 字句 言语 语言 议论 谈论 讨论 争论 辩论 主张 意见 看法 态度 资格 身份
+钱财 银子 铜钱 洋钱 生意 买卖 价钱 衣服 首饰 东西 物件 家具 房子 屋子 院子 庙里 门外 墙上 桌上 床上 地上
+`
@@ -0,0 +1,122 @@
+// Copyright (c) 2020 CDFMLR. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at  http://www.apache.org/licenses/LICENSE-2.0
+
+package segment
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Dictionary 是分词使用的词典，记录每个词及其词频
+type Dictionary struct {
+	freq     map[string]int // {"词": 词频}
+	total    int            // 所有词的词频之和
+	maxRunes int            // 最长的词有多少个字
+}
+
+// NewDictionary 返回一个空词典
+func NewDictionary() *Dictionary {
+	return &Dictionary{freq: map[string]int{}}
+}
+
+// Add 把词 word 加入词典，freq <= 0 时词频记为 1。重复加入时词频累加
+func (d *Dictionary) Add(word string, freq int) {
+	if word == "" {
+		return
+	}
+	if freq <= 0 {
+		freq = 1
+	}
+	d.freq[word] += freq
+	d.total += freq
+	if n := utf8.RuneCountInString(word); n > d.maxRunes {
+		d.maxRunes = n
+	}
+}
+
+// Contains 判断词典中是否有词 word
+func (d *Dictionary) Contains(word string) bool {
+	_, ok := d.freq[word]
+	return ok
+}
+
+// Freq 返回词 word 的词频，不在词典中时返回 0
+func (d *Dictionary) Freq(word string) int {
+	return d.freq[word]
+}
+
+// Len 返回词典中词的个数
+func (d *Dictionary) Len() int {
+	return len(d.freq)
+}
+
+var builtin struct {
+	once sync.Once
+	dict *Dictionary
+}
+
+// BuiltinDictionary 返回内置的汉语词典(见 builtinWords)。
+// 返回的词典是共享的，不要修改它
+func BuiltinDictionary() *Dictionary {
+	builtin.once.Do(func() {
+		builtin.dict = NewDictionary()
+		for _, w := range strings.Fields(builtinWords) {
+			builtin.dict.Add(w, 1)
+		}
+	})
+	return builtin.dict
+}
+
+// orBuiltin 返回 d，d 为 nil 时返回 BuiltinDictionary()
+func orBuiltin(d *Dictionary) *Dictionary {
+	if d == nil {
+		return BuiltinDictionary()
+	}
+	return d
+}
+
+// LoadDictionary 从词典文件 path 载入词典，文件格式见 ReadDictionary
+func LoadDictionary(path string) (*Dictionary, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadDictionary(f)
+}
+
+// ReadDictionary 从 r 读取词典。每行一个词，格式为(与 jieba 的词典兼容):
+//		词 [词频 [词性]]
+// 词频缺省为 1，词性被忽略。空行和以 '#' 开头的行被跳过
+func ReadDictionary(r io.Reader) (*Dictionary, error) {
+	d := NewDictionary()
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		freq := 1
+		if len(fields) > 1 {
+			f, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("segment: dictionary line %v: bad frequency %q", line, fields[1])
+			}
+			freq = f
+		}
+		d.Add(fields[0], freq)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
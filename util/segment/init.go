@@ -0,0 +1,39 @@
+// Copyright (c) 2020 CDFMLR. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at  http://www.apache.org/licenses/LICENSE-2.0
+
+package segment
+
+// Segmenters
+const (
+	ForwardMM  = iota // 正向最大匹配
+	BackwardMM        // 逆向最大匹配
+	DAG               // 词图 + 动态规划(最大概率路径)
+	_nothing
+)
+
+var SegmentersMap = map[string]int{
+	"ForwardMM":  ForwardMM,
+	"BackwardMM": BackwardMM,
+	"DAG":        DAG,
+}
+
+// Valid 判断 segmenter 是否是合法的分词器枚举值
+func Valid(segmenter int) bool {
+	return segmenter >= 0 && segmenter < _nothing
+}
+
+// By 返回使用词典 dict (为 nil 时使用 BuiltinDictionary()) 的分词器 segmenter
+func By(segmenter int, dict *Dictionary) Segmenter {
+	if !Valid(segmenter) {
+		panic("Unknown segmenter")
+	}
+	switch segmenter {
+	case BackwardMM:
+		return &BackwardMaxMatch{Dict: dict}
+	case DAG:
+		return &MaxProbability{Dict: dict}
+	}
+	return &ForwardMaxMatch{Dict: dict}
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2020 CDFMLR. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at  http://www.apache.org/licenses/LICENSE-2.0
+
+package segment
+
+// ForwardMaxMatch 是正向最大匹配分词器：从左到右，每次切出词典中最长的词，
+// 词典中没有的汉字单独成词
+type ForwardMaxMatch struct {
+	Dict *Dictionary // 使用的词典，为 nil 时使用 BuiltinDictionary()
+}
+
+// Segment 用正向最大匹配把 text 切分为词
+func (m *ForwardMaxMatch) Segment(text string) []string {
+	dict := orBuiltin(m.Dict)
+	return segmentRuns(text, func(run string, bounds []int) []string {
+		var words []string
+		for i := 0; i+1 < len(bounds); {
+			j := i + dict.maxRunes // 原子至少有一个字，所以词至多有 maxRunes 个原子
+			if j > len(bounds)-1 {
+				j = len(bounds) - 1
+			} else if j <= i {
+				j = i + 1
+			}
+			for ; j > i+1 && !dict.Contains(run[bounds[i]:bounds[j]]); j-- {
+			}
+			words = append(words, run[bounds[i]:bounds[j]])
+			i = j
+		}
+		return words
+	})
+}
+
+// BackwardMaxMatch 是逆向最大匹配分词器：从右到左，每次切出词典中最长的词，
+// 词典中没有的汉字单独成词。对汉语，逆向最大匹配的歧义通常比正向少
+type BackwardMaxMatch struct {
+	Dict *Dictionary // 使用的词典，为 nil 时使用 BuiltinDictionary()
+}
+
+// Segment 用逆向最大匹配把 text 切分为词
+func (m *BackwardMaxMatch) Segment(text string) []string {
+	dict := orBuiltin(m.Dict)
+	return segmentRuns(text, func(run string, bounds []int) []string {
+		var reversed []string
+		for j := len(bounds) - 1; j > 0; {
+			i := j - dict.maxRunes
+			if i < 0 {
+				i = 0
+			} else if i >= j {
+				i = j - 1
+			}
+			for ; i < j-1 && !dict.Contains(run[bounds[i]:bounds[j]]); i++ {
+			}
+			reversed = append(reversed, run[bounds[i]:bounds[j]])
+			j = i
+		}
+		words := make([]string, len(reversed))
+		for k, w := range reversed {
+			words[len(words)-1-k] = w
+		}
+		return words
+	})
+}
@@ -0,0 +1,173 @@
+// Copyright (c) 2020 CDFMLR. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at  http://www.apache.org/licenses/LICENSE-2.0
+
+// Package segment 实现分词：把文本切分为词。
+//
+// 文本先在空白、标点等非文字字符处断开。英文等以空格分词的文字，连续的字母、数字就是一个词；
+// 汉字、假名等不以空格分词的文字，则需要借助词典(见 Dictionary)切分。
+//
+// Usage:
+//		dict, err := segment.LoadDictionary(path)	// 或 segment.BuiltinDictionary()
+//		segment.By(segment.SEGMENTER, dict).Segment(text)
+//		segment.SegmentReader(reader, segmenter, func(word string) {...})
+// 	SEGMENTER may be:
+//		ForwardMM	// 正向最大匹配
+//		BackwardMM	// 逆向最大匹配
+//		DAG			// 词图 + 动态规划，取词频之积最大的切分
+package segment
+
+import (
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Segmenter 是分词器
+type Segmenter interface {
+	// Segment 把 text 切分为词，丢弃其中的空白、标点
+	Segment(text string) []string
+}
+
+// IsWordRune 判断 r 是否是可以组成词的字符(字母、数字)，其他字符(空白、标点等)都是词的分隔
+func IsWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)
+}
+
+// IsUnspaced 判断 r 是否属于不以空格分词的文字(汉字、假名、泰文)，这些文字的每个字都可以单独成词。
+// 谚文以空格分词，不在其中
+func IsUnspaced(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Thai)
+}
+
+// isSentenceBreak 判断 r 是否是句子的分隔: 标点等非 word rune，但不含空白
+func isSentenceBreak(r rune) bool {
+	return !IsWordRune(r) && !unicode.IsSpace(r)
+}
+
+// wordRuns 把 text 在非 word rune 处断开，返回其中连续的 word rune 串
+func wordRuns(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !IsWordRune(r)
+	})
+}
+
+// atomBounds 返回 run 中所有"原子"的边界(字节索引，含首尾)。
+// 不以空格分词的文字每个字是一个原子，其余连续的字母、数字(如一个英文单词)合为一个原子。
+// 分词只在原子的边界上进行，所以词典中的 "阿Q" 可以匹配，而 "Go" 不会从 "Gopher" 中切出来
+func atomBounds(run string) []int {
+	bounds := []int{0}
+	inSpaced := false // 上一个原子是否是以空格分词的文字，且还没结束
+	for i, r := range run {
+		if IsUnspaced(r) {
+			if inSpaced {
+				bounds = append(bounds, i)
+			}
+			inSpaced = false
+			bounds = append(bounds, i+utf8.RuneLen(r))
+			continue
+		}
+		inSpaced = true
+	}
+	if inSpaced {
+		bounds = append(bounds, len(run))
+	}
+	return bounds
+}
+
+// segmentRuns 把 text 断开为 word rune 串，用 cut 切分每个串(bounds 是串中原子的边界，见 atomBounds)，返回所有词
+func segmentRuns(text string, cut func(run string, bounds []int) []string) []string {
+	var words []string
+	for _, run := range wordRuns(text) {
+		words = append(words, cut(run, atomBounds(run))...)
+	}
+	return words
+}
+
+// readChunkSize 是 SegmentReader 每次读取的字节数
+const readChunkSize = 64 << 10
+
+// maxCarryBytes: 连续这么多字节都没有分隔符时，不再等待分隔符，直接切分
+const maxCarryBytes = 1 << 20
+
+// SegmentReader 流式地读取 r，用 s 分词，对每个词调用 fn。
+// 文本只在空白、标点处分块，所以与把全文读入内存后调用 s.Segment 的结果相同
+func SegmentReader(r io.Reader, s Segmenter, fn func(word string)) error {
+	return segmentReader(r, s, readChunkSize, fn)
+}
+
+func segmentReader(r io.Reader, s Segmenter, chunkSize int, fn func(word string)) error {
+	return readSplit(r, chunkSize, func(r rune) bool { return !IsWordRune(r) }, func(text string) {
+		for _, w := range s.Segment(text) {
+			fn(w)
+		}
+	})
+}
+
+// SegmentSentencesReader 与 SegmentReader 相同，但先在标点(不含空白)处把文本断为句子，
+// 对每个句子的所有词调用一次 fn，用于统计不跨越标点的词序列
+func SegmentSentencesReader(r io.Reader, s Segmenter, fn func(words []string)) error {
+	return readSplit(r, readChunkSize, isSentenceBreak, func(text string) {
+		for _, sentence := range strings.FieldsFunc(text, isSentenceBreak) {
+			if words := s.Segment(sentence); len(words) > 0 {
+				fn(words)
+			}
+		}
+	})
+}
+
+// readSplit 流式地读取 r，每次读取 chunkSize 字节，在最后一个满足 isBreak 的字符处分块，对每块调用 emit
+func readSplit(r io.Reader, chunkSize int, isBreak func(r rune) bool, emit func(text string)) error {
+	chunk := make([]byte, chunkSize)
+	var carry []byte // 上一块中最后一个分隔符之后的部分
+	for {
+		n, err := io.ReadFull(r, chunk)
+		data := append(carry, chunk[:n]...)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			emit(string(data))
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		cut := splitPoint(data, isBreak)
+		if cut < 0 {
+			if len(data) < maxCarryBytes {
+				carry = data
+				continue
+			}
+			cut = lastRuneBoundary(data)
+		}
+		emit(string(data[:cut]))
+		carry = append([]byte(nil), data[cut:]...)
+	}
+}
+
+// lastRuneBoundary 返回 data 末尾不完整的 rune 的起点，data 以完整的 rune 结尾时返回 len(data)，
+// 在此处切分不会把一个 rune 分到两块中
+func lastRuneBoundary(data []byte) int {
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(data)
+}
+
+// splitPoint 返回 data 中最后一个分隔符(满足 isBreak 的字符)的起点，没有时返回 -1。
+// data 末尾不完整的 rune 不会被当作分隔符
+func splitPoint(data []byte, isBreak func(r rune) bool) int {
+	for end := len(data); end > 0; {
+		r, size := utf8.DecodeLastRune(data[:end])
+		if r != utf8.RuneError && isBreak(r) {
+			return end - size
+		}
+		end -= size
+	}
+	return -1
+}
@@ -0,0 +1,156 @@
+// Copyright (c) 2020 CDFMLR. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at  http://www.apache.org/licenses/LICENSE-2.0
+
+package segment
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestForwardMaxMatch(t *testing.T) {
+	dict := NewDictionary()
+	for _, w := range []string{"研究", "研究生", "生命", "命", "起源", "阿Q", "Go语言", "사랑"} {
+		dict.Add(w, 0)
+	}
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"研究生命起源", []string{"研究生", "命", "起源"}},
+		{"阿Q正传", []string{"阿Q", "正", "传"}},
+		{"阿Quiz", []string{"阿", "Quiz"}},
+		{"Hello, world! Go语言，gopher's 3.14", []string{"Hello", "world", "Go语言", "gopher", "s", "3", "14"}},
+		{"ＧＯ！“中文”　　\n", []string{"ＧＯ", "中", "文"}},
+		{"사랑해요, 사랑", []string{"사랑해요", "사랑"}}, // 谚文以空格分词，不从词中切出 "사랑"
+		{"", nil},
+	}
+	m := &ForwardMaxMatch{Dict: dict}
+	for _, tt := range tests {
+		if got := m.Segment(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Segment(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+	if got := (&ForwardMaxMatch{Dict: NewDictionary()}).Segment("空词典"); !reflect.DeepEqual(got, []string{"空", "词", "典"}) {
+		t.Errorf("Segment with an empty dictionary = %q", got)
+	}
+	if got := (&ForwardMaxMatch{}).Segment("我们都是自己"); !reflect.DeepEqual(got, []string{"我们", "都是", "自己"}) {
+		t.Errorf("Segment with the builtin dictionary = %q", got)
+	}
+}
+
+func TestSegmenters(t *testing.T) {
+	dict, err := ReadDictionary(strings.NewReader(`
+# 词 词频 词性
+研究 100 v
+研究生 5 n
+生命 100
+命 10
+起源 80
+结合 90
+合成 20
+成分 60
+分子 90
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dict.Len() != 9 || dict.Freq("研究") != 100 || dict.Freq("没有") != 0 {
+		t.Errorf("ReadDictionary: got %v words, Freq(研究) = %v", dict.Len(), dict.Freq("研究"))
+	}
+	if _, err := ReadDictionary(strings.NewReader("词 abc")); err == nil {
+		t.Errorf("ReadDictionary with a bad frequency: got nil error")
+	}
+
+	tests := []struct {
+		text string
+		want map[int][]string
+	}{
+		{"研究生命起源", map[int][]string{
+			ForwardMM:  {"研究生", "命", "起源"},
+			BackwardMM: {"研究", "生命", "起源"},
+			DAG:        {"研究", "生命", "起源"},
+		}},
+		{"结合成分子", map[int][]string{
+			ForwardMM:  {"结合", "成分", "子"},
+			BackwardMM: {"结", "合成", "分子"},
+			DAG:        {"结合", "成", "分子"},
+		}},
+		{"Go, 研究!", map[int][]string{
+			ForwardMM:  {"Go", "研究"},
+			BackwardMM: {"Go", "研究"},
+			DAG:        {"Go", "研究"},
+		}},
+	}
+	for _, tt := range tests {
+		for name, segmenter := range SegmentersMap {
+			if got := By(segmenter, dict).Segment(tt.text); !reflect.DeepEqual(got, tt.want[segmenter]) {
+				t.Errorf("%v.Segment(%q) = %q, want %q", name, tt.text, got, tt.want[segmenter])
+			}
+		}
+	}
+}
+
+func TestSegmentReader(t *testing.T) {
+	data, err := ioutil.ReadFile("../strsearch/testing_text.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &ForwardMaxMatch{}
+	want := m.Segment(string(data))
+	for _, chunkSize := range []int{1, 7, 4096, readChunkSize} {
+		var got []string
+		err := segmentReader(bytes.NewReader(data), m, chunkSize, func(word string) {
+			got = append(got, word)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("chunkSize %v: got %v words, want %v", chunkSize, len(got), len(want))
+		}
+	}
+}
+
+func TestReadSplitLongRun(t *testing.T) {
+	// 没有分隔符的长文本在 maxCarryBytes 处强制切分，不应切断 rune
+	text := strings.Repeat("阿", maxCarryBytes/3+100)
+	for _, chunkSize := range []int{4096, readChunkSize} {
+		var got []string
+		err := readSplit(strings.NewReader(text), chunkSize, func(r rune) bool { return !IsWordRune(r) }, func(s string) {
+			got = append(got, s)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) < 2 || strings.Join(got, "") != text {
+			t.Fatalf("chunkSize %v: got %v chunks, want the text split into at least 2", chunkSize, len(got))
+		}
+		for i, s := range got {
+			if !utf8.ValidString(s) {
+				t.Errorf("chunkSize %v: chunk %v is not valid UTF-8", chunkSize, i)
+			}
+		}
+	}
+}
+
+func TestSegmentSentencesReader(t *testing.T) {
+	text := "我们都是自己人。Hello world, hello!\n\n他们"
+	var got [][]string
+	err := SegmentSentencesReader(strings.NewReader(text), &ForwardMaxMatch{}, func(words []string) {
+		got = append(got, words)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"我们", "都是", "自己", "人"}, {"Hello", "world"}, {"hello"}, {"他们"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -306,3 +306,39 @@ func TestInterface(t *testing.T) {
 		})
 	}
 }
+
+func TestTrace(t *testing.T) {
+	for algorithm := 0; algorithm <= 8; algorithm++ {
+		t.Run(fmt.Sprint(algorithm), func(t *testing.T) {
+			data := make(dataIntS, 200)
+			for i := range data {
+				data[i] = rand.Intn(100)
+			}
+			replay := append(dataIntS{}, data...)
+
+			var steps []Step
+			By(algorithm).Sort(Trace(data, true, func(s Step) {
+				steps = append(steps, s)
+			}))
+			if algorithm != ShellSync && !sort.IsSorted(data) { // ShellSync 并发插入，不保证有序
+				t.Fatal("not sorted:", data)
+			}
+			// 在原数据上重放所有 swap 得到排序的结果
+			less := 0
+			for _, s := range steps {
+				switch s.Op {
+				case StepSwap:
+					replay.Swap(s.I, s.J)
+				case StepLess:
+					less++
+				}
+			}
+			if !reflect.DeepEqual(replay, data) {
+				t.Errorf("replay != data:\n\t-->replay:%v\n\t-->data:%v", replay, data)
+			}
+			if less == 0 {
+				t.Error("no less step recorded")
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2020 CDFMLR. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at  http://www.apache.org/licenses/LICENSE-2.0
+
+package sortalgo
+
+import (
+	"sort"
+	"sync"
+)
+
+// Step operations
+const (
+	StepLess = "less" // 比较 data[I] < data[J]
+	StepSwap = "swap" // 交换 data[I]、data[J]
+)
+
+// Step 是排序过程中对数据的一步操作，按顺序在原数据上重放所有 swap 即可得到排序的过程
+type Step struct {
+	Op string `json:"op"` // StepLess 或 StepSwap
+	I  int    `json:"i"`
+	J  int    `json:"j"`
+}
+
+// Trace 返回包装了 data 的 sort.Interface，对它排序时，每次 Less、Swap 之前先以这一步调用 record。
+// withLess 为 false 时只记录 Swap。record 被串行调用(ShellSortSync 等并发算法也是如此)
+//
+// Example:
+//		var steps []sortalgo.Step
+//		sortalgo.By(sortalgo.Quick).Sort(sortalgo.Trace(data, true, func(s sortalgo.Step) {
+//			steps = append(steps, s)
+//		}))
+func Trace(data sort.Interface, withLess bool, record func(Step)) sort.Interface {
+	return &tracer{Interface: data, withLess: withLess, record: record}
+}
+
+type tracer struct {
+	sort.Interface
+	withLess bool
+	record   func(Step)
+	mux      sync.Mutex
+}
+
+func (t *tracer) Less(i, j int) bool {
+	if t.withLess {
+		t.mux.Lock()
+		defer t.mux.Unlock()
+		t.record(Step{Op: StepLess, I: i, J: j})
+	}
+	return t.Interface.Less(i, j)
+}
+
+func (t *tracer) Swap(i, j int) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	t.record(Step{Op: StepSwap, I: i, J: j})
+	t.Interface.Swap(i, j)
+}
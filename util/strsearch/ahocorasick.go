@@ -0,0 +1,199 @@
+// Copyright (c) 2020 CDFMLR. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at  http://www.apache.org/licenses/LICENSE-2.0
+
+package strsearch
+
+import "context"
+
+// AhoCorasickAutomaton 是由一组模式串构建的 Aho-Corasick 自动机，
+// 构建完成后可以在一次扫描中找出所有模式串在文本中的出现位置。
+//
+// 自动机按字节(而不是 rune)构建，与其他算法一样返回字节索引。
+type AhoCorasickAutomaton struct {
+	patterns []string // 去重、去空后的模式串
+	nodes    []acNode // nodes[0] 为根节点
+}
+
+// acNode 是 Aho-Corasick 自动机中 trie 的节点
+type acNode struct {
+	next     map[byte]int // trie 边
+	fail     int          // 失配指针
+	output   int          // 以该节点结尾的模式串在 patterns 中的索引，-1 表示没有
+	dictLink int          // 沿失配链可以到达的下一个有 output 的节点，-1 表示没有
+}
+
+// NewAhoCorasickAutomaton 由 patterns 构建 Aho-Corasick 自动机。
+// patterns 中的空串与重复串会被忽略。
+func NewAhoCorasickAutomaton(patterns []string) *AhoCorasickAutomaton {
+	a := &AhoCorasickAutomaton{
+		nodes: []acNode{newAcNode()},
+	}
+
+	// 构建 trie
+	seen := map[string]bool{}
+	for _, p := range patterns {
+		if p == "" || seen[p] {
+			continue
+		}
+		seen[p] = true
+		a.insert(p, len(a.patterns))
+		a.patterns = append(a.patterns, p)
+	}
+
+	// BFS 计算失配指针
+	a.buildFailLinks()
+	return a
+}
+
+func newAcNode() acNode {
+	return acNode{next: map[byte]int{}, output: -1, dictLink: -1}
+}
+
+// insert 把模式串 p 插入 trie，patternIdx 是 p 在 a.patterns 中的索引
+func (a *AhoCorasickAutomaton) insert(p string, patternIdx int) {
+	cur := 0
+	for i := 0; i < len(p); i++ {
+		nxt, ok := a.nodes[cur].next[p[i]]
+		if !ok {
+			nxt = len(a.nodes)
+			a.nodes = append(a.nodes, newAcNode())
+			a.nodes[cur].next[p[i]] = nxt
+		}
+		cur = nxt
+	}
+	a.nodes[cur].output = patternIdx
+}
+
+func (a *AhoCorasickAutomaton) buildFailLinks() {
+	queue := make([]int, 0, len(a.nodes))
+	for _, child := range a.nodes[0].next {
+		a.nodes[child].fail = 0
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for c, child := range a.nodes[cur].next {
+			f := a.nodes[cur].fail
+			for f > 0 {
+				if _, ok := a.nodes[f].next[c]; ok {
+					break
+				}
+				f = a.nodes[f].fail
+			}
+			if nxt, ok := a.nodes[f].next[c]; ok && nxt != child {
+				a.nodes[child].fail = nxt
+			} else {
+				a.nodes[child].fail = 0
+			}
+			fail := a.nodes[child].fail
+			if a.nodes[fail].output >= 0 {
+				a.nodes[child].dictLink = fail
+			} else {
+				a.nodes[child].dictLink = a.nodes[fail].dictLink
+			}
+			queue = append(queue, child)
+		}
+	}
+}
+
+// Patterns 返回自动机中的(去重、去空后的)模式串
+func (a *AhoCorasickAutomaton) Patterns() []string {
+	return a.patterns
+}
+
+// FindAll 在 text 中搜索所有模式串，返回 {模式串: 所有匹配位置起点索引}
+// maxMatches > 0 时，每个模式串至多返回 maxMatches 个匹配。
+func (a *AhoCorasickAutomaton) FindAll(text string, maxMatches int) map[string][]int {
+	m := a.newMatcher(maxMatches)
+	for i := 0; i < len(text); i++ {
+		m.feed(i, text[i])
+	}
+	return m.res
+}
+
+// FindAllBytes 同 FindAll，在 []byte 上搜索
+func (a *AhoCorasickAutomaton) FindAllBytes(text []byte, maxMatches int) map[string][]int {
+	return a.findAllBytesContext(context.Background(), text, maxMatches)
+}
+
+// findAllBytesContext 同 FindAllBytes，每扫描 cancelCheckInterval 字节检查一次 ctx，取消后返回已找到的匹配
+func (a *AhoCorasickAutomaton) findAllBytesContext(ctx context.Context, text []byte, maxMatches int) map[string][]int {
+	m := a.newMatcher(maxMatches)
+	for start := 0; start < len(text) && ctx.Err() == nil; start += cancelCheckInterval {
+		end := start + cancelCheckInterval
+		if end > len(text) {
+			end = len(text)
+		}
+		for i := start; i < end; i++ {
+			m.feed(i, text[i])
+		}
+	}
+	return m.res
+}
+
+// acMatcher 是自动机的一次扫描，FindAll、FindAllBytes 逐字节调用 feed，不需要复制 text
+type acMatcher struct {
+	a          *AhoCorasickAutomaton
+	cur        int   // 当前状态
+	counts     []int // 各模式串已找到的匹配数
+	maxMatches int
+	res        map[string][]int
+}
+
+func (a *AhoCorasickAutomaton) newMatcher(maxMatches int) *acMatcher {
+	return &acMatcher{a: a, counts: make([]int, len(a.patterns)), maxMatches: maxMatches, res: map[string][]int{}}
+}
+
+// feed 读入 text[i] == c，记录以 i 结尾的所有匹配
+func (m *acMatcher) feed(i int, c byte) {
+	nodes := m.a.nodes
+	for {
+		if nxt, ok := nodes[m.cur].next[c]; ok {
+			m.cur = nxt
+			break
+		}
+		if m.cur == 0 {
+			break
+		}
+		m.cur = nodes[m.cur].fail
+	}
+
+	out := m.cur
+	if nodes[out].output < 0 {
+		out = nodes[out].dictLink
+	}
+	for ; out >= 0; out = nodes[out].dictLink {
+		pi := nodes[out].output
+		if m.maxMatches > 0 && m.counts[pi] >= m.maxMatches {
+			continue
+		}
+		p := m.a.patterns[pi]
+		m.res[p] = append(m.res[p], i-len(p)+1)
+		m.counts[pi]++
+	}
+}
+
+// Aho-Corasick algorithm
+// 单模式串时退化为用只含 substr 的自动机搜索，多模式串请使用 NewAhoCorasickAutomaton 或 MultiBy(AhoCorasick)
+func AhoCorasickSearch(s, substr string, maxMatches int) (indices []int) {
+	if len(s) == 0 || len(substr) == 0 || len(substr) > len(s) {
+		return indices
+	}
+	return NewAhoCorasickAutomaton([]string{substr}).FindAll(s, maxMatches)[substr]
+}
+
+// AhoCorasickSearchBytes 是 AhoCorasickSearch 的 []byte 实现
+func AhoCorasickSearchBytes(b []byte, pattern string, maxMatches int) (indices []int) {
+	if len(b) == 0 || len(pattern) == 0 || len(pattern) > len(b) {
+		return indices
+	}
+	return NewAhoCorasickAutomaton([]string{pattern}).FindAllBytes(b, maxMatches)[pattern]
+}
+
+// ahoCorasickMultiSearch 是 AhoCorasick 的 MultiStrSearchAlgorithm 实现
+func ahoCorasickMultiSearch(text []byte, patterns []string, maxMatches int) map[string][]int {
+	return NewAhoCorasickAutomaton(patterns).FindAllBytes(text, maxMatches)
+}
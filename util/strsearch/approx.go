@@ -0,0 +1,185 @@
+// Copyright (c) 2020 CDFMLR. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at  http://www.apache.org/licenses/LICENSE-2.0
+
+package strsearch
+
+import (
+	"bufio"
+	"io"
+	"unicode/utf8"
+)
+
+// ApproxMatch 是一个近似匹配: text[Start:End] 与模式串的编辑距离为 Distance
+// Start、End 是字节索引，但编辑距离按 rune 计算(一个汉字算一次编辑)。
+type ApproxMatch struct {
+	Start    int `json:"start"`
+	End      int `json:"end"`
+	Distance int `json:"distance"`
+}
+
+// ApproxSearch 在 s 中搜索与 pattern 的编辑距离(插入、删除、替换)不超过 k 的所有子串，
+// maxMatches > 0 时至多返回 maxMatches 个匹配。
+//
+// 使用 Sellers 算法(对文本的每个 rune 更新一列编辑距离的动态规划)，时间 O(n·m)，空间 O(m)，
+// m 是 pattern 的 rune 数。k 会被限制在 [0, m-1] 内，即至少要有一个字与 pattern 对得上。
+//
+// 同一处文本附近通常有许多长短不一的子串都满足条件，ApproxSearch 只保留互不重叠的匹配，
+// 相互重叠时取编辑距离最小(相同时取靠前)的一个。
+func ApproxSearch(s, pattern string, k int, maxMatches int) (matches []ApproxMatch) {
+	if len(s) == 0 || len(pattern) == 0 {
+		return matches
+	}
+	a := newApproxSearcher(pattern, k, func(m ApproxMatch) bool {
+		matches = append(matches, m)
+		return maxMatches <= 0 || len(matches) < maxMatches
+	})
+	for offset, r := range s {
+		if !a.feed(r, offset+utf8.RuneLen(r)) {
+			return matches
+		}
+	}
+	a.flush()
+	return matches
+}
+
+// ApproxSearchReader 在 r 中流式地近似搜索所有 patterns，返回 {模式串: 近似匹配}。
+// 逐 rune 读取 r，内存占用与 r 的大小无关；匹配的定义见 ApproxSearch。
+// opts 的 IgnoreCase、NormalizeWidth 在比较 rune 时生效，WholeWord 对近似匹配没有意义，会被忽略。
+func ApproxSearchReader(r io.Reader, patterns []string, k int, opts MatchOptions) (map[string][]ApproxMatch, error) {
+	res := map[string][]ApproxMatch{}
+	var searchers []*approxSearcher
+	for _, p := range patterns {
+		if _, ok := res[p]; ok || len(p) == 0 {
+			continue
+		}
+		res[p] = nil
+		p := p
+		searchers = append(searchers, newApproxSearcher(opts.NormalizeString(p), k, func(m ApproxMatch) bool {
+			res[p] = append(res[p], m)
+			return true
+		}))
+	}
+
+	br := bufio.NewReader(r)
+	offset := 0
+	for {
+		c, size, err := br.ReadRune()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return res, err
+		}
+		c = opts.normalizeRune(c)
+		for _, a := range searchers {
+			a.feed(c, offset+size)
+		}
+		offset += size
+	}
+	for _, a := range searchers {
+		a.flush()
+	}
+	return res, nil
+}
+
+// approxSearcher 是 Sellers 算法的状态，每次 feed 一个 rune
+type approxSearcher struct {
+	pattern []rune
+	k       int
+
+	dist  []int // dist[i]: pattern[:i] 与以当前位置结尾的某个子串的最小编辑距离
+	start []int // start[i]: 上述子串的起点(字节)
+
+	pending *ApproxMatch           // 尚未确定的候选匹配，可能被之后重叠且更好的匹配替换
+	lastEnd int                    // 上一个已输出匹配的终点
+	emit    func(ApproxMatch) bool // 输出匹配，返回 false 表示停止搜索
+	stopped bool
+}
+
+func newApproxSearcher(pattern string, k int, emit func(ApproxMatch) bool) *approxSearcher {
+	p := []rune(pattern)
+	if k > len(p)-1 {
+		k = len(p) - 1
+	}
+	if k < 0 {
+		k = 0
+	}
+	a := &approxSearcher{
+		pattern: p,
+		k:       k,
+		dist:    make([]int, len(p)+1),
+		start:   make([]int, len(p)+1),
+		emit:    emit,
+	}
+	for i := range a.dist {
+		a.dist[i] = i
+	}
+	return a
+}
+
+// feed 读入一个 rune c，next 是 c 之后的字节索引，返回 false 表示已经停止搜索
+func (a *approxSearcher) feed(c rune, next int) bool {
+	if a.stopped {
+		return false
+	}
+	// 原地更新 dist 列: diag 保存上一列的 dist[i-1]
+	diag, diagStart := a.dist[0], a.start[0]
+	a.dist[0], a.start[0] = 0, next
+	for i := 1; i <= len(a.pattern); i++ {
+		sub, subStart := diag, diagStart // 替换(或相等)
+		if a.pattern[i-1] != c {
+			sub++
+		}
+		diag, diagStart = a.dist[i], a.start[i]
+
+		best, bestStart := sub, subStart
+		if del := a.dist[i] + 1; del < best { // 文本多出一个字
+			best, bestStart = del, a.start[i]
+		}
+		if ins := a.dist[i-1] + 1; ins < best { // 文本少了一个字
+			best, bestStart = ins, a.start[i-1]
+		}
+		if best > a.k+1 {
+			best = a.k + 1
+		}
+		a.dist[i], a.start[i] = best, bestStart
+	}
+
+	if d := a.dist[len(a.pattern)]; d <= a.k {
+		a.candidate(ApproxMatch{Start: a.start[len(a.pattern)], End: next, Distance: d})
+	}
+	return !a.stopped
+}
+
+// candidate 处理一个以当前位置结尾的候选匹配，只保留互不重叠的最优匹配
+func (a *approxSearcher) candidate(m ApproxMatch) {
+	if m.Start < a.lastEnd {
+		return
+	}
+	if a.pending == nil {
+		a.pending = &m
+		return
+	}
+	if m.Start < a.pending.End { // 与候选重叠
+		if m.Distance < a.pending.Distance {
+			a.pending = &m
+		}
+		return
+	}
+	a.flush()
+	a.pending = &m
+}
+
+// flush 输出尚未确定的候选匹配
+func (a *approxSearcher) flush() {
+	if a.pending == nil || a.stopped {
+		return
+	}
+	a.lastEnd = a.pending.End
+	if !a.emit(*a.pending) {
+		a.stopped = true
+	}
+	a.pending = nil
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2020 CDFMLR. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at  http://www.apache.org/licenses/LICENSE-2.0
+
+package strsearch
+
+// Boyer-Moore algorithm
+// 模式串从右向左比较，失配时取坏字符规则与好后缀规则中较大的移动距离
+func BoyerMooreSearch(s, substr string, maxMatches int) (indices []int) {
+	if len(s) == 0 || len(substr) == 0 || len(substr) > len(s) {
+		return indices
+	}
+	m := len(substr)
+	last := computeLastOccurrence(substr)
+	goodSuffix := computeGoodSuffixShift(substr)
+
+	for i := 0; i <= len(s)-m; {
+		j := m - 1
+		for j >= 0 && substr[j] == s[i+j] {
+			j--
+		}
+		if j < 0 {
+			indices = append(indices, i)
+			if maxMatches > 0 && len(indices) >= maxMatches {
+				return indices
+			}
+			i += goodSuffix[0]
+			continue
+		}
+		badChar := j - last[s[i+j]]
+		if goodSuffix[j+1] > badChar {
+			i += goodSuffix[j+1]
+		} else {
+			i += badChar
+		}
+	}
+	return indices
+}
+
+// computeLastOccurrence 坏字符规则: 返回每个字节在 substr 中最后出现的位置，不出现的为 -1
+func computeLastOccurrence(substr string) (last [256]int) {
+	for c := range last {
+		last[c] = -1
+	}
+	for i := 0; i < len(substr); i++ {
+		last[substr[i]] = i
+	}
+	return last
+}
+
+// computeGoodSuffixShift 好后缀规则(strong good suffix rule):
+// shift[j] 是 substr[j:] 已匹配、substr[j-1] 失配时模式串可以右移的距离，
+// shift[0] 是完全匹配后的移动距离(即 substr 的最小周期)。
+func computeGoodSuffixShift(substr string) []int {
+	m := len(substr)
+	shift := make([]int, m+1)
+	border := make([]int, m+1) // border[i]: substr[i:] 的最长真 border 的起始位置
+
+	// case 1: 已匹配的后缀在模式串中的其他位置出现
+	i, j := m, m+1
+	border[i] = j
+	for i > 0 {
+		for j <= m && substr[i-1] != substr[j-1] {
+			if shift[j] == 0 {
+				shift[j] = j - i
+			}
+			j = border[j]
+		}
+		i--
+		j--
+		border[i] = j
+	}
+
+	// case 2: 已匹配后缀的一部分是模式串的前缀
+	j = border[0]
+	for i = 0; i <= m; i++ {
+		if shift[i] == 0 {
+			shift[i] = j
+		}
+		if i == j {
+			j = border[j]
+		}
+	}
+	return shift
+}
+
+// Boyer-Moore-Horspool algorithm
+// 只用坏字符规则，并且总是以窗口最后一个字符查移动距离
+func HorspoolSearch(s, substr string, maxMatches int) (indices []int) {
+	if len(s) == 0 || len(substr) == 0 || len(substr) > len(s) {
+		return indices
+	}
+	m := len(substr)
+	var shift [256]int
+	for c := range shift {
+		shift[c] = m
+	}
+	for i := 0; i < m-1; i++ {
+		shift[substr[i]] = m - 1 - i
+	}
+
+	for i := 0; i <= len(s)-m; i += shift[s[i+m-1]] {
+		if s[i:i+m] == substr {
+			indices = append(indices, i)
+			if maxMatches > 0 && len(indices) >= maxMatches {
+				return indices
+			}
+		}
+	}
+	return indices
+}
+
+// Sunday algorithm
+// 失配时以窗口之后的第一个字符查移动距离
+func SundaySearch(s, substr string, maxMatches int) (indices []int) {
+	if len(s) == 0 || len(substr) == 0 || len(substr) > len(s) {
+		return indices
+	}
+	m := len(substr)
+	var shift [256]int
+	for c := range shift {
+		shift[c] = m + 1
+	}
+	for i := 0; i < m; i++ {
+		shift[substr[i]] = m - i
+	}
+
+	for i := 0; i <= len(s)-m; {
+		if s[i:i+m] == substr {
+			indices = append(indices, i)
+			if maxMatches > 0 && len(indices) >= maxMatches {
+				return indices
+			}
+		}
+		if i+m >= len(s) {
+			break
+		}
+		i += shift[s[i+m]]
+	}
+	return indices
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2020 CDFMLR. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at  http://www.apache.org/licenses/LICENSE-2.0
+
+package strsearch
+
+import (
+	"regexp"
+)
+
+// BytesSearchAlgorithm 是 StrSearchAlgorithm 在 []byte 上的实现，见 BytesBy
+type BytesSearchAlgorithm func(b []byte, pattern string, maxMatches int) (indices []int)
+
+// FindAll 在 text ([]byte) 中搜索 pattern，返回所有匹配位置起点索引
+func (b BytesSearchAlgorithm) FindAll(text []byte, pattern string) []int {
+	return b(text, pattern, -1)
+}
+
+// viaString 把没有原生 []byte 实现的 s 包装成 BytesSearchAlgorithm: 每次检索都把 text 复制成 string
+func viaString(s StrSearchAlgorithm) BytesSearchAlgorithm {
+	return func(b []byte, pattern string, maxMatches int) []int {
+		return s(string(b), pattern, maxMatches)
+	}
+}
+
+// NaiveSearchByCharBytes 是 NaiveSearchByChar 的 []byte 实现
+func NaiveSearchByCharBytes(b []byte, pattern string, maxMatches int) (indices []int) {
+	if len(b) == 0 || len(pattern) == 0 || len(pattern) > len(b) {
+		return indices
+	}
+	for i := 0; i < len(b)-len(pattern)+1; i++ {
+		for j := 0; j < len(pattern); j++ {
+			if b[i+j] != pattern[j] {
+				break
+			}
+			if j == len(pattern)-1 {
+				indices = append(indices, i)
+				if maxMatches > 0 && len(indices) >= maxMatches {
+					return indices
+				}
+			}
+		}
+	}
+	return indices
+}
+
+// KmpSearchBytes 是 KmpSearch 的 []byte 实现
+func KmpSearchBytes(b []byte, pattern string, maxMatches int) (indices []int) {
+	if len(b) == 0 || len(pattern) == 0 || len(pattern) > len(b) {
+		return indices
+	}
+	next := computePrefixFunction(pattern)
+	numMatchedChar := -1
+	for i := 0; i < len(b); i++ {
+		for numMatchedChar > -1 && pattern[numMatchedChar+1] != b[i] {
+			numMatchedChar = next[numMatchedChar]
+		}
+		if pattern[numMatchedChar+1] == b[i] {
+			numMatchedChar++
+		}
+		if numMatchedChar == len(pattern)-1 {
+			indices = append(indices, i-len(pattern)+1)
+			if maxMatches > 0 && len(indices) >= maxMatches {
+				return indices
+			}
+			numMatchedChar = next[numMatchedChar]
+		}
+	}
+	return indices
+}
+
+// RabinKarpSearchBytes 是 RabinKarpSearch 的 []byte 实现
+func RabinKarpSearchBytes(b []byte, pattern string, maxMatches int) (indices []int) {
+	if len(b) == 0 || len(pattern) == 0 || len(pattern) > len(b) {
+		return indices
+	}
+	m := len(pattern)
+	hpattern, pow := rollingHash(pattern)
+
+	var hb uint32
+	for i := 0; i < m; i++ {
+		hb = hb*primeRK + uint32(b[i])
+	}
+	for i := 0; ; i++ {
+		if hb == hpattern && string(b[i:i+m]) == pattern {
+			indices = append(indices, i)
+			if maxMatches > 0 && len(indices) >= maxMatches {
+				return indices
+			}
+		}
+		if i+m >= len(b) {
+			break
+		}
+		hb = hb*primeRK + uint32(b[i+m]) - pow*uint32(b[i])
+	}
+	return indices
+}
+
+// goStlRegSearchBytes cost less than goStlRegSearch
+func goStlRegSearchBytes(b []byte, pattern string, maxMatches int) (indices []int) {
+	if len(b) == 0 || len(pattern) == 0 || len(pattern) > len(b) {
+		return indices
+	}
+	reg := regexp.MustCompile(regexp.QuoteMeta(pattern))
+	for offset := 0; offset <= len(b)-len(pattern); {
+		loc := reg.FindIndex(b[offset:])
+		if loc == nil {
+			break
+		}
+		indices = append(indices, offset+loc[0])
+		if maxMatches > 0 && len(indices) >= maxMatches {
+			return indices
+		}
+		offset += loc[0] + 1
+	}
+	return indices
+}
+
+// goStlRegexpSearchBytes 是 goStlRegexpSearch 的 []byte 实现
+func goStlRegexpSearchBytes(b []byte, expr string, maxMatches int) (indices []int) {
+	if len(b) == 0 || len(expr) == 0 {
+		return indices
+	}
+	reg, err := regexp.Compile(expr)
+	if err != nil {
+		return nil
+	}
+	return regexpStartIndices(reg.FindAllIndex(b, regexpMaxMatches(maxMatches)))
+}
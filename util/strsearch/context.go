@@ -0,0 +1,224 @@
+// Copyright (c) 2020 CDFMLR. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at  http://www.apache.org/licenses/LICENSE-2.0
+
+package strsearch
+
+import (
+	"context"
+	"io"
+	"regexp"
+	"regexp/syntax"
+	"unicode/utf8"
+)
+
+// cancelCheckInterval 是可取消的搜索在内层循环中检查 ctx 的间隔(字节数或 rune 数)
+const cancelCheckInterval = 64 << 10
+
+// MultiByContext 同 MultiBy，但返回的算法在搜索过程中定期检查 ctx，ctx 取消后立即返回不完整的结果。
+// 逐个模式串搜索的算法在每个模式串之前检查；AhoCorasick、RabinKarp 每扫描 64 KiB 检查一次；
+// LibRegexp 每扫描约 64 KiB 检查一次，整个文本作为一块也能被中途取消，见 regexpFinder。
+// 与 FindAllBytesContext、FindAllReaderContext 一起使用，取消后它们丢弃不完整的结果，返回 ctx.Err()
+func MultiByContext(ctx context.Context, algorithm int) MultiStrSearchAlgorithm {
+	if !Valid(algorithm) {
+		panic("Unknown algorithm")
+	}
+	switch algorithm {
+	case AhoCorasick:
+		return func(text []byte, patterns []string, maxMatches int) map[string][]int {
+			return NewAhoCorasickAutomaton(patterns).findAllBytesContext(ctx, text, maxMatches)
+		}
+	case RabinKarp:
+		return func(text []byte, patterns []string, maxMatches int) map[string][]int {
+			return rabinKarpMultiSearchContext(ctx, text, patterns, maxMatches)
+		}
+	case LibRegexp:
+		return eachPatternContext(ctx, func(b []byte, expr string, maxMatches int) []int {
+			return regexpSearchContext(ctx, b, expr, maxMatches)
+		})
+	}
+	return eachPatternContext(ctx, BytesBy(algorithm))
+}
+
+// eachPatternContext 同 eachPattern，但在搜索每个模式串之前检查 ctx，取消后不再搜索余下的模式串
+func eachPatternContext(ctx context.Context, algorithm BytesSearchAlgorithm) MultiStrSearchAlgorithm {
+	return func(text []byte, patterns []string, maxMatches int) map[string][]int {
+		res := map[string][]int{}
+		for _, p := range patterns {
+			if ctx.Err() != nil {
+				break
+			}
+			if _, ok := res[p]; ok {
+				continue
+			}
+			res[p] = algorithm(text, p, maxMatches)
+		}
+		return res
+	}
+}
+
+// regexpSearchContext 同 goStlRegexpSearchBytes，但 ctx 可以取消时，每扫描约 cancelCheckInterval 字节检查一次 ctx，
+// 取消后尽快返回。每个匹配由 regexpFinder 找出，何时接受空匹配、如何前进与 regexp.FindAllIndex 一致
+func regexpSearchContext(ctx context.Context, b []byte, expr string, maxMatches int) (indices []int) {
+	if ctx.Done() == nil {
+		return goStlRegexpSearchBytes(b, expr, maxMatches)
+	}
+	if len(b) == 0 || len(expr) == 0 {
+		return indices
+	}
+	find := regexpFinder(ctx, b, expr)
+	if find == nil {
+		return nil
+	}
+	for pos, prevMatchEnd := 0, -1; pos <= len(b); {
+		start, end, ok := find(pos)
+		if !ok || ctx.Err() != nil {
+			break
+		}
+		accept := true
+		if end == pos { // 空匹配
+			if start == prevMatchEnd { // 紧接着上一个匹配的空匹配不算
+				accept = false
+			}
+			if pos < len(b) {
+				_, size := utf8.DecodeRune(b[pos:])
+				pos += size
+			} else {
+				pos++
+			}
+		} else {
+			pos = end
+		}
+		prevMatchEnd = end
+		if accept {
+			indices = append(indices, start)
+			if maxMatches > 0 && len(indices) >= maxMatches {
+				break
+			}
+		}
+	}
+	return indices
+}
+
+// regexpFinder 返回在 b 中找出起点不小于 pos 的最左匹配 b[start:end] 的函数，ctx 取消后返回 ok == false。
+// expr 不合法时返回 nil。
+//
+// 匹配长度有上限 maxLen、且不依赖上文(不含 ^、\A、\b、\B)的表达式，在 b[from:from+cancelCheckInterval+maxLen+utf8.UTFMax]
+// 的窗口中用 FindIndex 查找：起点在窗口前 cancelCheckInterval 字节内的匹配及其下文都在窗口内，与在整个 b 中查找相同。
+//
+// 其他表达式通过每 cancelCheckInterval 个 rune 检查一次 ctx 的 io.RuneReader 匹配。regexp 不能从中间开始、带着上文匹配，
+// 所以 pos > 0 时从 pos 之前的一个 rune 开始读，用 \A(?s:.)(?s:.*?)(expr) 跳过它：
+// 这个 rune 作为 ^、\b 等的上文，而 .*? 使 expr 的匹配与非锚定的查找相同，是最左的那个。
+// 这种方式没有 regexp 对字面量前缀的加速，较慢
+func regexpFinder(ctx context.Context, b []byte, expr string) func(pos int) (start, end int, ok bool) {
+	reg, err := regexp.Compile(expr)
+	if err != nil {
+		return nil
+	}
+	re, _ := syntax.Parse(expr, syntax.Perl)
+	if maxLen, ok := regexpMaxLen(re); ok && !regexpNeedsPrefix(re) {
+		return func(pos int) (int, int, bool) {
+			for from := pos; from <= len(b) && ctx.Err() == nil; from += cancelCheckInterval {
+				hi := from + cancelCheckInterval + maxLen + utf8.UTFMax
+				if hi >= len(b) {
+					if loc := reg.FindIndex(b[from:]); loc != nil {
+						return from + loc[0], from + loc[1], true
+					}
+					break
+				}
+				if loc := reg.FindIndex(b[from:hi]); loc != nil && loc[0] < cancelCheckInterval {
+					return from + loc[0], from + loc[1], true
+				}
+			}
+			return 0, 0, false
+		}
+	}
+
+	first := regexp.MustCompile(`\A(?s:.*?)(` + expr + `)`)
+	next := regexp.MustCompile(`\A(?s:.)(?s:.*?)(` + expr + `)`)
+	return func(pos int) (int, int, bool) {
+		reg, from := first, 0
+		if pos > 0 {
+			_, size := utf8.DecodeLastRune(b[:pos])
+			reg, from = next, pos-size
+		}
+		r := &ctxRuneReader{ctx: ctx, b: b, pos: from}
+		loc := reg.FindReaderSubmatchIndex(r)
+		if r.cancelled || loc == nil {
+			return 0, 0, false
+		}
+		return from + loc[2], from + loc[3], true
+	}
+}
+
+// regexpMaxLenLimit 是 regexpFinder 按窗口查找时，表达式匹配长度上限的上限
+const regexpMaxLenLimit = 1 << 16
+
+// regexpMaxLen 返回 re 的匹配长度(字节)的上限，没有上限或上限超过 regexpMaxLenLimit 时 ok 为 false
+func regexpMaxLen(re *syntax.Regexp) (n int, ok bool) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		n = len(re.Rune) * utf8.UTFMax // 忽略大小写时，字母可能匹配更长的 rune
+	case syntax.OpCharClass, syntax.OpAnyCharNotNL, syntax.OpAnyChar:
+		n = utf8.UTFMax
+	case syntax.OpCapture, syntax.OpQuest:
+		return regexpMaxLen(re.Sub[0])
+	case syntax.OpConcat, syntax.OpAlternate:
+		for _, sub := range re.Sub {
+			m, ok := regexpMaxLen(sub)
+			if !ok {
+				return 0, false
+			}
+			if re.Op == syntax.OpConcat {
+				n += m
+			} else if m > n {
+				n = m
+			}
+		}
+	case syntax.OpRepeat:
+		m, ok := regexpMaxLen(re.Sub[0])
+		if !ok || re.Max < 0 || (m > 0 && re.Max > regexpMaxLenLimit/m) {
+			return 0, false
+		}
+		n = m * re.Max
+	case syntax.OpStar, syntax.OpPlus:
+		return 0, false
+	}
+	return n, n <= regexpMaxLenLimit
+}
+
+// regexpNeedsPrefix 判断 re 的匹配是否依赖匹配之前的上文，即是否含有 ^、\A、\b、\B
+func regexpNeedsPrefix(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpBeginLine, syntax.OpBeginText, syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+		return true
+	}
+	for _, sub := range re.Sub {
+		if regexpNeedsPrefix(sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// ctxRuneReader 从 b[pos:] 逐个读出 rune，每读 cancelCheckInterval 个检查一次 ctx，取消后如同读到末尾
+type ctxRuneReader struct {
+	ctx       context.Context
+	b         []byte
+	pos       int
+	n         int
+	cancelled bool
+}
+
+func (r *ctxRuneReader) ReadRune() (c rune, size int, err error) {
+	if r.n++; r.n%cancelCheckInterval == 0 && r.ctx.Err() != nil {
+		r.cancelled = true
+	}
+	if r.cancelled || r.pos >= len(r.b) {
+		return 0, 0, io.EOF
+	}
+	c, size = utf8.DecodeRune(r.b[r.pos:])
+	r.pos += size
+	return c, size, nil
+}
@@ -5,53 +5,192 @@
 //  - NaiveSearchBySlice
 //  - KmpSearch
 //  - RabinKarpSearch
+//  - RabinKarpMd5Search
+//  - AhoCorasickSearch (AhoCorasickAutomaton)
+//  - ApproxSearch (Sellers, approximate matching within k edits)
+//  - BoyerMooreSearch
+//  - HorspoolSearch
+//  - SundaySearch
+//  - ZSearch (Gusfield Z algorithm)
+//  - TwoWaySearch (Crochemore-Perrin Two-Way, O(1) extra space)
 //
 // Notes:
 //  NaiveSearchBySlice is slower than NaiveSearchByChar
-//  RabinKarpSearch is despised, for its md5 calling as a hash function, it's too slowwwwww.
+//  RabinKarpSearch uses a polynomial rolling hash, updated in O(1) per window.
+//  RabinKarpMd5Search is despised, for its md5 calling as a hash function, it's too slowwwwww.
+//  It is kept only for comparison.
+//  BytesBy(ALGORITHM).FindAll searches []byte natively (without copying text into a string) for
+//  Naive, Kmp, RabinKarp, LibRe, LibRegexp and AhoCorasick, see bytes.go.
 //
 // Usage:
-// 		strsearch.By(strsearch.ALGORITHM).FindAll/FindAllBytes(text, pattern)
+// 		strsearch.By(strsearch.ALGORITHM).FindAll(text, pattern)
+// 		strsearch.BytesBy(strsearch.ALGORITHM).FindAll(textBytes, pattern)
 // 	ALGORITHM may be:
 //		Naive		// 暴力法
 //		Kmp			// KMP 算法
 //		RabinKarp	// Rabin-Karp 算法
-//		LibRe		// regexp.FindAllIndex (go lib)
+//		LibRe		// regexp.FindAllIndex (go lib)，pattern 按字面量匹配
+//		LibRegexp	// regexp.FindAllIndex (go lib)，pattern 是正则表达式
+//		AhoCorasick	// Aho-Corasick 自动机
+//		BoyerMoore	// Boyer-Moore 算法
+//		Horspool	// Boyer-Moore-Horspool 算法
+//		Sunday		// Sunday 算法
+//		RabinKarpMd5	// Rabin-Karp 算法 (md5 哈希，仅用于对比)
+//		ZAlgorithm	// Z 算法
+//		TwoWay		// Two-Way 算法
+//
+// 	在大文件(io.Reader)中流式搜索，内存占用与文件大小无关(LibRegexp 不能流式搜索，见 Chunkable):
+// 		strsearch.By(strsearch.ALGORITHM).FindAllReader(reader, pattern)
+// 		strsearch.MultiBy(strsearch.ALGORITHM).FindAllReader(reader, patterns)
+//
+// 	把单个大文本分成相互重叠的块，多个 goroutine 并行搜索(LibRegexp 以外的算法，见 Chunkable)，结果与顺序搜索相同:
+// 		par := strsearch.ParallelOptions{Workers: runtime.NumCPU()}
+// 		strsearch.MultiBy(strsearch.ALGORITHM).FindAllBytesParallel(text, patterns, opts, par)
+// 		strsearch.MultiBy(strsearch.ALGORITHM).FindAllReaderParallel(reader, patterns, opts, par)
+//
+// 	可取消的搜索，ctx 取消后在算法的内层循环中尽早停止(包括整体搜索的 LibRegexp):
+// 		strsearch.MultiByContext(ctx, strsearch.ALGORITHM).FindAllBytesContext(ctx, text, patterns, opts, par)
+//
+// 	近似(模糊)搜索，找出与 pattern 编辑距离不超过 k 的子串，编辑距离按 rune 计:
+// 		strsearch.ApproxSearch(text, pattern, k, maxMatches)
+// 		strsearch.ApproxSearchReader(reader, patterns, k, opts)
+//
+// 	忽略大小写、全词匹配、全半角归一 (MatchOptions) 可以配合 LibRegexp 以外的任意算法使用:
+// 		opts := strsearch.MatchOptions{IgnoreCase: true, WholeWord: true, NormalizeWidth: true}
+// 		strsearch.MultiBy(strsearch.ALGORITHM).FindAllBytesOptions(text, patterns, opts)
+// 		strsearch.MultiBy(strsearch.ALGORITHM).FindAllReaderOptions(reader, patterns, opts)
+//
+// 	LibRegexp 以外的算法都把 pattern 当作字面量。对用户输入的 pattern，
+// 	搜索前可以先调用 ValidatePattern(ALGORITHM, pattern) 检查，以得到不合法正则表达式的编译错误；
+// 	LibRegexp 不能使用 MatchOptions，可以用 ValidateOptions(ALGORITHM, opts) 检查。
+//
+// 	同时搜索多个模式串:
+// 		strsearch.MultiBy(strsearch.ALGORITHM).FindAll/FindAllBytes(text, patterns)
+// 	AhoCorasick 一次扫描即可找出所有模式串，RabinKarp 对每种模式串长度各扫描一遍，
+// 	其他算法对每个模式串各扫描一遍。
 
 package strsearch
 
-//Algorithms
+import (
+	"errors"
+	"regexp"
+)
+
+// Algorithms
 const (
-	LibRe     = iota // regexp.FindAllIndex (go lib)
-	Kmp              // KMP 算法
-	RabinKarp        // Rabin-Karp 算法
-	Naive            // 暴力法
+	LibRe        = iota // regexp.FindAllIndex (go lib), 字面量匹配
+	Kmp                 // KMP 算法
+	RabinKarp           // Rabin-Karp 算法
+	Naive               // 暴力法
+	AhoCorasick         // Aho-Corasick 自动机
+	BoyerMoore          // Boyer-Moore 算法
+	Horspool            // Boyer-Moore-Horspool 算法
+	Sunday              // Sunday 算法
+	RabinKarpMd5        // Rabin-Karp 算法 (md5 哈希)
+	LibRegexp           // regexp.FindAllIndex (go lib), 正则表达式匹配
+	ZAlgorithm          // Z 算法
+	TwoWay              // Two-Way 算法
 	_nothing
 )
 
 var StrsearchAlgorithmsMap = map[string]int{
-	"LibRe":     LibRe,
-	"Kmp":       Kmp,
-	"RabinKarp": RabinKarp,
-	"Naive":     Naive,
+	"LibRe":        LibRe,
+	"Kmp":          Kmp,
+	"RabinKarp":    RabinKarp,
+	"Naive":        Naive,
+	"AhoCorasick":  AhoCorasick,
+	"BoyerMoore":   BoyerMoore,
+	"Horspool":     Horspool,
+	"Sunday":       Sunday,
+	"RabinKarpMd5": RabinKarpMd5,
+	"LibRegexp":    LibRegexp,
+	"ZAlgorithm":   ZAlgorithm,
+	"TwoWay":       TwoWay,
+}
+
+// Valid 判断 algorithm 是否为已实现的算法
+func Valid(algorithm int) bool {
+	return algorithm >= 0 && algorithm < _nothing
+}
+
+// algorithms: 每个算法在 string 与 []byte 上的实现。
+// 没有原生 []byte 实现的算法显式地用 viaString 包装，检索时会把 text 复制成 string
+var algorithms = [_nothing]struct {
+	s StrSearchAlgorithm
+	b BytesSearchAlgorithm
+}{
+	LibRe:        {goStlRegSearch, goStlRegSearchBytes},
+	Kmp:          {KmpSearch, KmpSearchBytes},
+	RabinKarp:    {RabinKarpSearch, RabinKarpSearchBytes},
+	Naive:        {NaiveSearchByChar, NaiveSearchByCharBytes},
+	AhoCorasick:  {AhoCorasickSearch, AhoCorasickSearchBytes},
+	BoyerMoore:   {BoyerMooreSearch, viaString(BoyerMooreSearch)},
+	Horspool:     {HorspoolSearch, viaString(HorspoolSearch)},
+	Sunday:       {SundaySearch, viaString(SundaySearch)},
+	RabinKarpMd5: {RabinKarpMd5Search, viaString(RabinKarpMd5Search)},
+	LibRegexp:    {goStlRegexpSearch, goStlRegexpSearchBytes},
+	ZAlgorithm:   {ZSearch, viaString(ZSearch)},
+	TwoWay:       {TwoWaySearch, viaString(TwoWaySearch)},
 }
 
 func By(algorithm int) StrSearchAlgorithm {
-	if algorithm < 0 || algorithm >= _nothing {
+	if !Valid(algorithm) {
+		panic("Unknown algorithm")
+	}
+	return algorithms[algorithm].s
+}
+
+// BytesBy 返回 algorithm 在 []byte 上的实现。
+// Naive、Kmp、RabinKarp、LibRe、LibRegexp、AhoCorasick 直接在 []byte 上检索，其他算法会先把 text 复制成 string
+func BytesBy(algorithm int) BytesSearchAlgorithm {
+	if !Valid(algorithm) {
+		panic("Unknown algorithm")
+	}
+	return algorithms[algorithm].b
+}
+
+// ValidatePattern 检查 pattern 能否用 algorithm 搜索。
+// 对 LibRegexp 返回 pattern 作为正则表达式的编译错误，其他算法把 pattern 当作字面量，总是返回 nil
+func ValidatePattern(algorithm int, pattern string) error {
+	if algorithm == LibRegexp {
+		_, err := regexp.Compile(pattern)
+		return err
+	}
+	return nil
+}
+
+// Chunkable 判断 algorithm 能否分块搜索(FindAllReader、FindAllBytesParallel、FindAllBytesContext 等)，
+// 即分块搜索的结果是否与整体搜索相同。
+// LibRegexp 不能: 正则匹配的长度不受 pattern 长度限制，跨块的长匹配会被漏掉；
+// ^、\A、\b、\B 等还依赖块外的上下文，在每块的开头会误匹配。
+// 对这样的算法，请把整个 text 读入内存，用 FindAllBytes、FindAllBytesOptions 搜索
+func Chunkable(algorithm int) bool {
+	return algorithm != LibRegexp
+}
+
+// ValidateOptions 检查 opts 能否与 algorithm 一起使用。
+// MatchOptions 把 pattern 当作字面量规范化，对 LibRegexp 的正则表达式会改变其含义(如 IgnoreCase 把 \d 变成 \D)，
+// 所以 LibRegexp 不能使用任何选项，请在表达式中使用 (?i)、\b 等语法
+func ValidateOptions(algorithm int, opts MatchOptions) error {
+	if algorithm == LibRegexp && !opts.IsZero() {
+		return errors.New("ignore_case, whole_word and normalize_width cannot be used with LibRegexp, use (?i) or \\b in the expression instead")
+	}
+	return nil
+}
+
+// MultiBy 返回 algorithm 对应的多模式串搜索算法
+// 对没有原生多模式实现的算法，退化为对每个模式串分别调用 By(algorithm)
+func MultiBy(algorithm int) MultiStrSearchAlgorithm {
+	if !Valid(algorithm) {
 		panic("Unknown algorithm")
 	}
-	var strSearchAlgo StrSearchAlgorithm
 	switch algorithm {
-	case LibRe:
-		strSearchAlgo = goStlRegSearch
-	case Kmp:
-		strSearchAlgo = KmpSearch
+	case AhoCorasick:
+		return ahoCorasickMultiSearch
 	case RabinKarp:
-		strSearchAlgo = RabinKarpSearch
-	case Naive:
-		strSearchAlgo = NaiveSearchByChar
+		return rabinKarpMultiSearch
 	}
-	return strSearchAlgo
+	return eachPattern(BytesBy(algorithm))
 }
 
 func FindAll(text string, pattern string) []int {
@@ -59,7 +198,7 @@ func FindAll(text string, pattern string) []int {
 }
 
 func FindAllBytes(text []byte, pattern string) []int {
-	return By(LibRe).FindAllBytes(text, pattern)
+	return BytesBy(LibRe).FindAll(text, pattern)
 }
 
 /******************************************************************************
@@ -0,0 +1,203 @@
+// Copyright (c) 2020 CDFMLR. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at  http://www.apache.org/licenses/LICENSE-2.0
+
+package strsearch
+
+import (
+	"CiFa/util/segment"
+	"sort"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MatchOptions 是匹配选项，零值表示逐字节精确匹配
+//
+// 选项通过先规范化 text 和 patterns、再用任意算法搜索、最后把索引映射回原 text 实现，
+// 所以 patterns 会被当作字面量规范化：LibRegexp 不能使用这些选项(见 ValidateOptions)，请使用 (?i)、\b 等正则语法。
+type MatchOptions struct {
+	IgnoreCase     bool `json:"ignore_case"`     // Unicode 大小写折叠: "max" 匹配 "Max"、"MAX"
+	WholeWord      bool `json:"whole_word"`      // 只匹配完整的单词: "max" 不匹配 "maximum"，只对拉丁字母等以空格分词的文字生效
+	NormalizeWidth bool `json:"normalize_width"` // 全角、半角视为相同: "Ｑ" 匹配 "Q"
+}
+
+// IsZero 判断 o 是否为零值(不需要任何额外处理)
+func (o MatchOptions) IsZero() bool {
+	return o == MatchOptions{}
+}
+
+// normalizeRune 按 o 规范化一个 rune
+func (o MatchOptions) normalizeRune(r rune) rune {
+	if o.NormalizeWidth {
+		r = narrowRune(r)
+	}
+	if o.IgnoreCase {
+		r = foldRune(r)
+	}
+	return r
+}
+
+// NormalizeString 按 o 规范化 s，规范化后相同的两个字符串在 o 下视为匹配
+func (o MatchOptions) NormalizeString(s string) string {
+	if !o.IgnoreCase && !o.NormalizeWidth {
+		return s
+	}
+	norm, _ := o.normalize([]byte(s))
+	return string(norm)
+}
+
+// offsetShift 记录规范化后的文本中 norm 处对应原文本中的 orig 处，
+// 规范化改变了某个 rune 的字节长度时才需要记录
+type offsetShift struct {
+	norm int
+	orig int
+}
+
+type offsetShifts []offsetShift
+
+// orig 把规范化后的文本中的字节索引 n 映射回原文本
+func (s offsetShifts) orig(n int) int {
+	i := sort.Search(len(s), func(i int) bool { return s[i].norm > n }) - 1
+	return s[i].orig + (n - s[i].norm)
+}
+
+// normalize 按 o 规范化 text，返回规范化后的文本，以及把其中的索引映射回 text 的 offsetShifts
+func (o MatchOptions) normalize(text []byte) ([]byte, offsetShifts) {
+	shifts := offsetShifts{{0, 0}}
+	norm := make([]byte, 0, len(text))
+	var buf [utf8.UTFMax]byte
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRune(text[i:])
+		nr := o.normalizeRune(r)
+		if nr == r || (r == utf8.RuneError && size == 1) {
+			norm = append(norm, text[i:i+size]...)
+			i += size
+			continue
+		}
+		n := utf8.EncodeRune(buf[:], nr)
+		norm = append(norm, buf[:n]...)
+		i += size
+		if n != size {
+			shifts = append(shifts, offsetShift{norm: len(norm), orig: i})
+		}
+	}
+	return norm, shifts
+}
+
+// foldRune 返回 r 的大小写折叠等价类(unicode.SimpleFold 的轨道)中最小的 rune
+func foldRune(r rune) rune {
+	if r < utf8.RuneSelf {
+		if 'a' <= r && r <= 'z' {
+			r -= 'a' - 'A'
+		}
+		return r
+	}
+	min := r
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if f < min {
+			min = f
+		}
+	}
+	return min
+}
+
+// narrowRune 把全角字符转换为对应的半角字符
+func narrowRune(r rune) rune {
+	switch {
+	case 0xFF01 <= r && r <= 0xFF5E: // 全角 ASCII
+		return r - 0xFEE0
+	case r == 0x3000: // 全角空格
+		return ' '
+	}
+	switch r {
+	case '￠':
+		return '¢'
+	case '￡':
+		return '£'
+	case '￢':
+		return '¬'
+	case '￣':
+		return '¯'
+	case '￤':
+		return '¦'
+	case '￥':
+		return '¥'
+	case '￦':
+		return '₩'
+	}
+	return r
+}
+
+// isWholeWord 判断 text[start:end] 两端是否都在单词边界上
+func isWholeWord(text []byte, start, end int) bool {
+	if start > 0 {
+		before, _ := utf8.DecodeLastRune(text[:start])
+		first, _ := utf8.DecodeRune(text[start:])
+		if isWordRune(before) && isWordRune(first) {
+			return false
+		}
+	}
+	if end < len(text) {
+		last, _ := utf8.DecodeLastRune(text[:end])
+		after, _ := utf8.DecodeRune(text[end:])
+		if isWordRune(last) && isWordRune(after) {
+			return false
+		}
+	}
+	return true
+}
+
+// isWordRune 判断 r 是否是以空格分词的文字(如拉丁字母、谚文)中可以组成单词的字符。
+// 汉字、假名等不以空格分词，每个字都视为单独的词，所以不是 word rune，见 segment.IsUnspaced
+func isWordRune(r rune) bool {
+	return r == '_' || segment.IsWordRune(r) && !segment.IsUnspaced(r)
+}
+
+// FindAllBytesOptions 在 text 中按 opts 搜索所有 patterns，返回的索引是 text 中的字节索引
+func (m MultiStrSearchAlgorithm) FindAllBytesOptions(text []byte, patterns []string, opts MatchOptions) map[string][]int {
+	return m.findOptions(text, patterns, -1, opts)
+}
+
+func (m MultiStrSearchAlgorithm) findOptions(text []byte, patterns []string, maxMatches int, opts MatchOptions) map[string][]int {
+	if opts.IsZero() {
+		return m(text, patterns, maxMatches)
+	}
+
+	norm, shifts := text, offsetShifts{{0, 0}}
+	if opts.IgnoreCase || opts.NormalizeWidth {
+		norm, shifts = opts.normalize(text)
+	}
+	// {规范化后的模式串: [原模式串...]}
+	originals := map[string][]string{}
+	var normPatterns []string
+	seen := map[string]bool{}
+	for _, p := range patterns {
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		np := opts.NormalizeString(p)
+		if _, ok := originals[np]; !ok {
+			normPatterns = append(normPatterns, np)
+		}
+		originals[np] = append(originals[np], p)
+	}
+
+	res := map[string][]int{}
+	for np, indices := range m(norm, normPatterns, -1) {
+		for _, i := range indices {
+			start, end := shifts.orig(i), shifts.orig(i+len(np))
+			if opts.WholeWord && !isWholeWord(text, start, end) {
+				continue
+			}
+			for _, p := range originals[np] {
+				if maxMatches > 0 && len(res[p]) >= maxMatches {
+					continue
+				}
+				res[p] = append(res[p], start)
+			}
+		}
+	}
+	return res
+}
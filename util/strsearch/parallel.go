@@ -0,0 +1,279 @@
+// Copyright (c) 2020 CDFMLR. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at  http://www.apache.org/licenses/LICENSE-2.0
+
+package strsearch
+
+import (
+	"context"
+	"io"
+	"sync"
+	"unicode/utf8"
+)
+
+// ParallelChunkSize 是在内存中的文本上分块并行搜索时，每块的默认大小(字节)
+var ParallelChunkSize = 4 << 20
+
+// ParallelOptions 控制在单个文本内的分块并行搜索
+type ParallelOptions struct {
+	Workers   int // 并行搜索的 goroutine 数，<= 1 时顺序搜索
+	ChunkSize int // 每块的大小(字节)，<= 0 时使用默认值 (ParallelChunkSize 或 ReaderChunkSize)
+
+	// 不为 nil 时，每搜索完一块，以这一块负责的字节数调用 Progress，用于报告进度。
+	// 并行搜索时可能被多个 goroutine 同时调用
+	Progress func(n int)
+}
+
+// FindAllBytesParallel 把 text 分成相互重叠的块，用 par.Workers 个 goroutine 并行地按 opts 搜索所有 patterns。
+// 结果与 FindAllBytesOptions 相同: 每个匹配只属于一块，重叠部分的匹配不会被重复计数。
+// LibRegexp 不能分块搜索，见 Chunkable。
+func (m MultiStrSearchAlgorithm) FindAllBytesParallel(text []byte, patterns []string, opts MatchOptions, par ParallelOptions) map[string][]int {
+	if par.Workers <= 1 {
+		return m.findOptions(text, patterns, -1, opts)
+	}
+	res, _ := m.FindAllBytesContext(context.Background(), text, patterns, opts, par)
+	return res
+}
+
+// FindAllBytesContext 同 FindAllBytesParallel，但 ctx 取消后不再搜索新的块，返回 (nil, ctx.Err())。
+// 即使 par.Workers <= 1，大于一块的 text 也会分块顺序搜索。
+// m 由 MultiByContext(ctx, algorithm) 得到时，正在搜索的块也会在算法的内层循环中被中途取消，见 MultiByContext；
+// 否则每个 worker 要搜索完当前的一块才返回。
+// text 不大于 par.ChunkSize 时整体搜索，不能分块的算法(见 Chunkable)可以把 par.ChunkSize 设为 len(text)
+func (m MultiStrSearchAlgorithm) FindAllBytesContext(ctx context.Context, text []byte, patterns []string, opts MatchOptions, par ParallelOptions) (map[string][]int, error) {
+	chunkSize := par.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = ParallelChunkSize
+	}
+	if len(text) <= chunkSize {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		res := m.findOptions(text, patterns, -1, opts)
+		if err := ctx.Err(); err != nil { // m 可能中途被取消，结果不完整
+			return nil, err
+		}
+		if par.Progress != nil {
+			par.Progress(len(text))
+		}
+		return res, nil
+	}
+	tail, lead := chunkMargins(patterns, opts)
+	return findAllChunks(ctx, m, patterns, opts, par, func(emit func(chunk) bool) error {
+		sliceChunks(text, chunkSize, tail, lead, emit)
+		return nil
+	})
+}
+
+// FindAllReaderParallel 从 r 中依次读出各块，用 par.Workers 个 goroutine 并行地按 opts 搜索所有 patterns，
+// 见 FindAllReaderOptions。同时在内存中的块约为 2*par.Workers 个。
+func (m MultiStrSearchAlgorithm) FindAllReaderParallel(r io.Reader, patterns []string, opts MatchOptions, par ParallelOptions) (map[string][]int, error) {
+	return m.FindAllReaderContext(context.Background(), r, patterns, opts, par)
+}
+
+// FindAllReaderContext 同 FindAllReaderParallel，但 ctx 取消后不再读取、搜索新的块，返回 (nil, ctx.Err())。
+// 与 FindAllBytesContext 一样，m 由 MultiByContext 得到时正在搜索的块也会被中途取消
+func (m MultiStrSearchAlgorithm) FindAllReaderContext(ctx context.Context, r io.Reader, patterns []string, opts MatchOptions, par ParallelOptions) (map[string][]int, error) {
+	chunkSize := par.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = ReaderChunkSize
+	}
+	return findAllReaderContext(ctx, m, r, patterns, chunkSize, opts, par)
+}
+
+// chunk 是分块搜索中的一块: buf 在原文中的偏移为 base，
+// 这一块只负责起点(原文中的偏移)在 [from, to) 内的匹配
+type chunk struct {
+	seq      int // 块的序号，合并结果时按序号排列
+	base     int
+	buf      []byte
+	from, to int
+}
+
+// search 在 c 中按 opts 搜索 patterns，返回起点属于这一块的匹配(原文中的偏移)
+func (c chunk) search(m MultiStrSearchAlgorithm, patterns []string, opts MatchOptions) map[string][]int {
+	res := map[string][]int{}
+	for p, indices := range m.findOptions(c.buf, patterns, -1, opts) {
+		for _, i := range indices {
+			if i += c.base; i >= c.from && i < c.to {
+				res[p] = append(res[p], i)
+			}
+		}
+	}
+	return res
+}
+
+// chunkMargins 返回每块在负责的范围之后(tail)、之前(lead)需要额外带上的字节数。
+// 精确匹配时 tail 为最长模式串长度 - 1，lead 为 0；
+// 使用 MatchOptions 时，规范化可能改变匹配的字节长度，判断单词边界也需要匹配前后各一个 rune，
+// 所以 tail、lead 都要放宽。
+func chunkMargins(patterns []string, opts MatchOptions) (tail, lead int) {
+	maxLen := 0
+	for _, p := range patterns {
+		if len(p) > maxLen {
+			maxLen = len(p)
+		}
+	}
+	if !opts.IsZero() {
+		return maxLen*utf8.UTFMax + utf8.UTFMax, utf8.UTFMax
+	}
+	if maxLen == 0 {
+		return 0, 0
+	}
+	return maxLen - 1, 0
+}
+
+// sliceChunks 把 text 切分为负责 chunkSize 字节的块，块之间共享 text 的内存，emit 返回 false 时停止
+func sliceChunks(text []byte, chunkSize, tail, lead int, emit func(chunk) bool) {
+	for seq, start := 0, 0; start < len(text); seq, start = seq+1, start+chunkSize {
+		end := start + chunkSize
+		if end > len(text) {
+			end = len(text)
+		}
+		lo, hi := start-lead, end+tail
+		if lo < 0 {
+			lo = 0
+		}
+		if hi > len(text) {
+			hi = len(text)
+		}
+		if !emit(chunk{seq: seq, base: lo, buf: text[lo:hi], from: start, to: end}) {
+			return
+		}
+	}
+}
+
+// readChunks 每次从 r 读入 chunkSize 字节，与上一块末尾保留的 tail + lead 字节拼成一块交给 emit。
+//
+// 除最后一块外，起点在末尾 tail 字节内的匹配可能没读完，留给下一块；
+// 保留下来的字节中，前 lead 字节只作为下一块的上文，其中的起点已经在这一块处理过了。
+// reuse 为 true 时各块共用同一个缓冲区，emit 返回后缓冲区即被覆盖。emit 返回 false 时停止读取。
+func readChunks(r io.Reader, chunkSize, tail, lead int, reuse bool, emit func(chunk) bool) error {
+	buf := make([]byte, tail+lead+chunkSize)
+	carry := 0 // buf[:carry] 是上一块保留下来的部分
+	base := 0  // buf[0] 在 r 中的偏移
+	for seq := 0; ; seq++ {
+		n, err := io.ReadFull(r, buf[carry:])
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		end := carry + n
+		eof := err != nil
+		if end == 0 {
+			return nil
+		}
+
+		from, to := carry-tail, end
+		if from < 0 {
+			from = 0
+		}
+		if !eof {
+			to = end - tail
+		}
+		if !emit(chunk{seq: seq, base: base, buf: buf[:end], from: base + from, to: base + to}) || eof {
+			return nil
+		}
+
+		keep := tail + lead
+		if keep > end {
+			keep = end
+		}
+		next := buf
+		if !reuse {
+			next = make([]byte, len(buf))
+		}
+		copy(next, buf[end-keep:end])
+		buf = next
+		base += end - keep
+		carry = keep
+	}
+}
+
+// findAllChunks 搜索 produce 产生的所有块，par.Workers > 1 时用 par.Workers 个 goroutine 并行搜索，
+// 按块的顺序合并结果，所以每个模式串的索引仍是升序的。
+// 每搜索一块前检查 ctx，取消后停止产生、搜索新的块，返回 (nil, ctx.Err())
+func findAllChunks(ctx context.Context, m MultiStrSearchAlgorithm, patterns []string, opts MatchOptions, par ParallelOptions,
+	produce func(emit func(chunk) bool) error) (map[string][]int, error) {
+
+	workers := par.Workers
+	search := func(c chunk) map[string][]int {
+		found := c.search(m, patterns, opts)
+		if par.Progress != nil {
+			par.Progress(c.to - c.from)
+		}
+		return found
+	}
+	res := map[string][]int{}
+	merge := func(found map[string][]int) {
+		for p, indices := range found {
+			res[p] = append(res[p], indices...)
+		}
+	}
+	if workers <= 1 {
+		err := produce(func(c chunk) bool {
+			if ctx.Err() != nil {
+				return false
+			}
+			merge(search(c))
+			return true
+		})
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return res, err
+	}
+
+	type chunkResult struct {
+		seq   int
+		found map[string][]int
+	}
+	chunks := make(chan chunk, workers)
+	results := make(chan chunkResult, workers)
+
+	var err error
+	go func() {
+		err = produce(func(c chunk) bool {
+			select {
+			case chunks <- c:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+		close(chunks)
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range chunks {
+				if ctx.Err() != nil {
+					continue // 取消后只取出剩余的块，不再搜索
+				}
+				results <- chunkResult{seq: c.seq, found: search(c)}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	pending := map[int]map[string][]int{} // 先于前面的块完成的块
+	next := 0
+	for r := range results {
+		pending[r.seq] = r.found
+		for found, ok := pending[next]; ok; found, ok = pending[next] {
+			merge(found)
+			delete(pending, next)
+			next++
+		}
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return res, err
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2020 CDFMLR. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at  http://www.apache.org/licenses/LICENSE-2.0
+
+package strsearch
+
+import "unicode/utf8"
+
+// Index units: StrSearchAlgorithm 返回的索引可以被转换成的单位
+const (
+	ByteIndex = iota // 字节偏移 (StrSearchAlgorithm 的原始结果)
+	RuneIndex        // 字符(rune)偏移，即"第几个字"
+	LineIndex        // 行号，配合 Position.Column 使用
+)
+
+var IndexUnitsMap = map[string]int{
+	"byte": ByteIndex,
+	"rune": RuneIndex,
+	"line": LineIndex,
+}
+
+// Position 是一个匹配位置在文本中的各种表示
+type Position struct {
+	Byte   int `json:"byte"`   // 字节偏移，从 0 开始
+	Rune   int `json:"rune"`   // 字符(rune)偏移，从 0 开始
+	Line   int `json:"line"`   // 行号，从 1 开始
+	Column int `json:"column"` // 列号(以 rune 计)，从 1 开始
+}
+
+// Positions 把 text 中的字节索引 indices (StrSearchAlgorithm 的结果) 转换为 Position。
+// indices 升序时只需扫描 text 一遍；越界的索引会被截断到 len(text)。
+func Positions(text string, indices []int) []Position {
+	if indices == nil {
+		return nil
+	}
+	res := make([]Position, 0, len(indices))
+
+	cur := Position{Line: 1, Column: 1}
+	for _, idx := range indices {
+		if idx < cur.Byte { // 非升序，从头再数
+			cur = Position{Line: 1, Column: 1}
+		}
+		if idx > len(text) {
+			idx = len(text)
+		}
+		for cur.Byte < idx {
+			r, size := utf8.DecodeRuneInString(text[cur.Byte:])
+			cur.Byte += size
+			cur.Rune++
+			if r == '\n' {
+				cur.Line++
+				cur.Column = 1
+			} else {
+				cur.Column++
+			}
+		}
+		res = append(res, cur)
+	}
+	return res
+}
+
+// ConvertIndices 把 text 中的字节索引 indices 转换为 unit 单位的索引。
+// unit 为 LineIndex 时返回的是行号，列号请使用 Positions 获取。
+func ConvertIndices(text string, indices []int, unit int) []int {
+	if unit == ByteIndex || indices == nil {
+		return indices
+	}
+	res := make([]int, 0, len(indices))
+	for _, p := range Positions(text, indices) {
+		switch unit {
+		case RuneIndex:
+			res = append(res, p.Rune)
+		case LineIndex:
+			res = append(res, p.Line)
+		default:
+			panic("Unknown index unit")
+		}
+	}
+	return res
+}
@@ -0,0 +1,52 @@
+// Copyright (c) 2020 CDFMLR. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at  http://www.apache.org/licenses/LICENSE-2.0
+
+package strsearch
+
+import (
+	"context"
+	"io"
+)
+
+// ReaderChunkSize 是 FindAllReader 每次从 io.Reader 读取的字节数
+var ReaderChunkSize = 1 << 20
+
+// FindAllReader 在 r 中流式地搜索 pattern，返回所有匹配位置起点(相对于 r 开头的字节)索引。
+// r 按 ReaderChunkSize 分块读取，内存占用与 r 的大小无关。
+//
+// 相邻两块之间保留 len(pattern)-1 字节的重叠，跨块的匹配也能被找到，且不会重复计数。
+// LibRegexp 不能流式搜索，见 Chunkable。
+// 每块都会被复制成 string 再检索，MultiBy(algorithm).FindAllReader 则在 []byte 上原生检索(见 BytesBy)。
+func (s StrSearchAlgorithm) FindAllReader(r io.Reader, pattern string) ([]int, error) {
+	res, err := findAllReader(eachPattern(viaString(s)), r, []string{pattern}, ReaderChunkSize, MatchOptions{}, 1)
+	return res[pattern], err
+}
+
+// FindAllReader 在 r 中流式地搜索所有 patterns，见 StrSearchAlgorithm.FindAllReader
+func (m MultiStrSearchAlgorithm) FindAllReader(r io.Reader, patterns []string) (map[string][]int, error) {
+	return findAllReader(m, r, patterns, ReaderChunkSize, MatchOptions{}, 1)
+}
+
+// FindAllReaderOptions 在 r 中流式地按 opts 搜索所有 patterns，见 FindAllBytesOptions
+func (m MultiStrSearchAlgorithm) FindAllReaderOptions(r io.Reader, patterns []string, opts MatchOptions) (map[string][]int, error) {
+	return findAllReader(m, r, patterns, ReaderChunkSize, opts, 1)
+}
+
+// findAllReader 从 r 中依次读出 chunkSize 字节的块并搜索，块的划分见 readChunks。
+// workers > 1 时用 workers 个 goroutine 并行搜索各块。
+func findAllReader(m MultiStrSearchAlgorithm, r io.Reader, patterns []string, chunkSize int, opts MatchOptions, workers int) (map[string][]int, error) {
+	return findAllReaderContext(context.Background(), m, r, patterns, chunkSize, opts, ParallelOptions{Workers: workers})
+}
+
+// findAllReaderContext 同 findAllReader，并行度与进度回调由 par 指定，ctx 取消后不再读取、搜索新的块，返回 ctx.Err()
+func findAllReaderContext(ctx context.Context, m MultiStrSearchAlgorithm, r io.Reader, patterns []string, chunkSize int, opts MatchOptions, par ParallelOptions) (map[string][]int, error) {
+	if chunkSize <= 0 {
+		chunkSize = ReaderChunkSize
+	}
+	tail, lead := chunkMargins(patterns, opts)
+	return findAllChunks(ctx, m, patterns, opts, par, func(emit func(chunk) bool) error {
+		return readChunks(r, chunkSize, tail, lead, par.Workers <= 1, emit)
+	})
+}
@@ -6,6 +6,7 @@
 package strsearch
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/binary"
 	"io"
@@ -19,33 +20,90 @@ func (s StrSearchAlgorithm) FindAll(text string, pattern string) []int {
 	return s(text, pattern, -1)
 }
 
-// FindAllBytes 在 text ([]byte) 中搜索 pattern，返回所有匹配位置起点索引
-// 若 StrSearch 为 LibRe，则会使用 goStlRegSearchBytes 完成检索；
-// 否则会返回 s(string(text), pattern) 的调用结果。
+// FindAllBytes 在 text ([]byte) 中搜索 pattern，返回所有匹配位置起点索引。
+// text 会被复制成 string，不复制地在 []byte 上检索请使用 BytesBy(algorithm).FindAll
 func (s StrSearchAlgorithm) FindAllBytes(text []byte, pattern string) []int {
 	return s(string(text), pattern, -1)
 }
 
-// regexp.FindAllStringIndex in go lib
+// MultiStrSearchAlgorithm 在 text 中同时搜索多个模式串 patterns，
+// 返回 {模式串: 所有匹配位置起点索引}，maxMatches > 0 时每个模式串至多返回 maxMatches 个匹配。
+//
+// 与 StrSearchAlgorithm 不同，MultiStrSearchAlgorithm 直接在 []byte 上检索，主要用于搜索整个文件。
+type MultiStrSearchAlgorithm func(text []byte, patterns []string, maxMatches int) (indices map[string][]int)
+
+// FindAll 在 text (string) 中搜索所有 patterns
+func (m MultiStrSearchAlgorithm) FindAll(text string, patterns []string) map[string][]int {
+	return m([]byte(text), patterns, -1)
+}
+
+// FindAllBytes 在 text ([]byte) 中搜索所有 patterns
+func (m MultiStrSearchAlgorithm) FindAllBytes(text []byte, patterns []string) map[string][]int {
+	return m(text, patterns, -1)
+}
+
+// eachPattern 把单模式串的 BytesSearchAlgorithm 包装成 MultiStrSearchAlgorithm：
+// 对每个模式串分别调用一次 algorithm，即对 text 扫描 len(patterns) 遍。
+func eachPattern(algorithm BytesSearchAlgorithm) MultiStrSearchAlgorithm {
+	return eachPatternContext(context.Background(), algorithm)
+}
+
+// regexp.FindStringIndex in go lib
+// substr 被当作字面量(经 regexp.QuoteMeta 转义)，"C++"、"(注)"、"a.b" 等都按原样匹配。
+// 与其他算法一致，重叠的匹配也会被找出(regexp.FindAll 只返回不重叠的匹配)
 func goStlRegSearch(s, substr string, maxMatches int) (indices []int) {
-	reg := regexp.MustCompile(substr)
-	r := reg.FindAllStringIndex(s, maxMatches)
-	var res []int
-	for _, i := range r {
-		res = append(res, i[0])
+	if len(s) == 0 || len(substr) == 0 || len(substr) > len(s) {
+		return indices
 	}
-	return res
+	reg := regexp.MustCompile(regexp.QuoteMeta(substr))
+	for offset := 0; offset <= len(s)-len(substr); {
+		loc := reg.FindStringIndex(s[offset:])
+		if loc == nil {
+			break
+		}
+		indices = append(indices, offset+loc[0])
+		if maxMatches > 0 && len(indices) >= maxMatches {
+			return indices
+		}
+		offset += loc[0] + 1
+	}
+	return indices
 }
 
-// goStlRegSearchBytes cost less than goStlRegSearch
-func goStlRegSearchBytes(b []byte, pattern string, maxMatches int) (indices []int) {
-	reg := regexp.MustCompile(pattern)
-	r := reg.FindAllIndex(b, maxMatches)
-	var res []int
-	for _, i := range r {
-		res = append(res, i[0])
+// goStlRegexpSearch 把 expr 当作正则表达式搜索，expr 不合法时返回 nil 而不会 panic。
+// 需要得到编译错误时请使用 RegexpSearch 或 ValidatePattern
+func goStlRegexpSearch(s, expr string, maxMatches int) (indices []int) {
+	indices, _ = RegexpSearch(s, expr, maxMatches)
+	return indices
+}
+
+// RegexpSearch 在 s 中搜索正则表达式 expr，返回所有匹配位置起点索引。
+// expr 不是合法的正则表达式时返回 regexp.Compile 的错误。
+func RegexpSearch(s, expr string, maxMatches int) (indices []int, err error) {
+	if len(s) == 0 || len(expr) == 0 {
+		return indices, nil
 	}
-	return res
+	reg, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return regexpStartIndices(reg.FindAllStringIndex(s, regexpMaxMatches(maxMatches))), nil
+}
+
+// regexpMaxMatches 把 StrSearchAlgorithm 的 maxMatches 约定(<=0 表示不限)转换为 regexp 的约定(<0 表示不限)
+func regexpMaxMatches(maxMatches int) int {
+	if maxMatches <= 0 {
+		return -1
+	}
+	return maxMatches
+}
+
+// regexpStartIndices 从 regexp 的 [][start, end] 结果中取出 start
+func regexpStartIndices(loc [][]int) (indices []int) {
+	for _, i := range loc {
+		indices = append(indices, i[0])
+	}
+	return indices
 }
 
 // naive string search algorithm
@@ -125,14 +183,121 @@ func computePrefixFunction(substr string) []int {
 	return pi
 }
 
+// primeRK 是 Rabin-Karp 算法中多项式滚动哈希的基数，哈希值在 uint32 上自然溢出(即 mod 2^32)
+const primeRK = 16777619
+
 // Rabin-Karp algorithm
+// 使用多项式滚动哈希，窗口右移时 O(1) 地更新哈希值，哈希相等时再逐字比较排除冲突
 func RabinKarpSearch(s, substr string, maxMatches int) (indices []int) {
 	if len(s) == 0 || len(substr) == 0 || len(substr) > len(s) {
 		return indices
 	}
-	hsubstr := hash(substr)
+	m := len(substr)
+	hsubstr, pow := rollingHash(substr)
+
+	var hs uint32
+	for i := 0; i < m; i++ {
+		hs = hs*primeRK + uint32(s[i])
+	}
+	for i := 0; ; i++ {
+		if hs == hsubstr && s[i:i+m] == substr {
+			indices = append(indices, i)
+			if maxMatches > 0 && len(indices) >= maxMatches {
+				return indices
+			}
+		}
+		if i+m >= len(s) {
+			break
+		}
+		// 窗口右移: 去掉 s[i]，加入 s[i+m]
+		hs = hs*primeRK + uint32(s[i+m]) - pow*uint32(s[i])
+	}
+	return indices
+}
+
+// rollingHash 返回 s 的多项式哈希值，以及 primeRK^len(s)，后者用于滚动时移除窗口最左的字节
+func rollingHash(s string) (hash uint32, pow uint32) {
+	for i := 0; i < len(s); i++ {
+		hash = hash*primeRK + uint32(s[i])
+	}
+	return hash, rollingPow(len(s))
+}
+
+// rollingPow 返回 primeRK^n (mod 2^32)，即长为 n 的窗口滚动时最左字节的权重
+func rollingPow(n int) (pow uint32) {
+	pow = 1
+	for sq := uint32(primeRK); n > 0; n >>= 1 {
+		if n&1 != 0 {
+			pow *= sq
+		}
+		sq *= sq
+	}
+	return pow
+}
+
+// rabinKarpMultiSearch 是 RabinKarp 的 MultiStrSearchAlgorithm 实现:
+// 长度相同的模式串共用一趟滚动哈希，对 text 扫描的趟数等于模式串不同长度的个数
+func rabinKarpMultiSearch(text []byte, patterns []string, maxMatches int) map[string][]int {
+	return rabinKarpMultiSearchContext(context.Background(), text, patterns, maxMatches)
+}
+
+// rabinKarpMultiSearchContext 同 rabinKarpMultiSearch，每扫描 cancelCheckInterval 字节检查一次 ctx，取消后返回已找到的匹配
+func rabinKarpMultiSearchContext(ctx context.Context, text []byte, patterns []string, maxMatches int) map[string][]int {
+	res := map[string][]int{}
+
+	// 按长度分组: {长度: {哈希值: [模式串...]}}
+	groups := map[int]map[uint32][]string{}
+	for _, p := range patterns {
+		if _, ok := res[p]; ok || len(p) == 0 {
+			continue
+		}
+		res[p] = nil
+		if len(p) > len(text) {
+			continue
+		}
+		h, _ := rollingHash(p)
+		if groups[len(p)] == nil {
+			groups[len(p)] = map[uint32][]string{}
+		}
+		groups[len(p)][h] = append(groups[len(p)][h], p)
+	}
+
+	for m, hashes := range groups {
+		pow := rollingPow(m)
+		var hs uint32
+		for i := 0; i < m; i++ {
+			hs = hs*primeRK + uint32(text[i])
+		}
+		for i := 0; ; i++ {
+			if i%cancelCheckInterval == 0 && ctx.Err() != nil {
+				return res
+			}
+			for _, p := range hashes[hs] {
+				if maxMatches > 0 && len(res[p]) >= maxMatches {
+					continue
+				}
+				if string(text[i:i+m]) == p {
+					res[p] = append(res[p], i)
+				}
+			}
+			if i+m >= len(text) {
+				break
+			}
+			hs = hs*primeRK + uint32(text[i+m]) - pow*uint32(text[i])
+		}
+	}
+	return res
+}
+
+// Rabin-Karp algorithm with md5
+// 每个窗口都重新调用 md5 计算哈希，O(n·m) 且常数很大，仅保留用于与 RabinKarpSearch 对比
+func RabinKarpMd5Search(s, substr string, maxMatches int) (indices []int) {
+	if len(s) == 0 || len(substr) == 0 || len(substr) > len(s) {
+		return indices
+	}
+	hsubstr := md5Hash(substr)
 	for i := 0; i < len(s)-len(substr)+1; i++ {
-		if hs := hash(s[i : i+len(substr)]); hs == hsubstr {
+		if hs := md5Hash(s[i : i+len(substr)]); hs == hsubstr {
 			if s[i:i+len(substr)] == substr { // s[i:i+len(substr)] == substr
 				indices = append(indices, i)
 				if maxMatches > 0 && len(indices) >= maxMatches {
@@ -144,7 +309,7 @@ func RabinKarpSearch(s, substr string, maxMatches int) (indices []int) {
 	return indices
 }
 
-func hash(s string) uint64 {
+func md5Hash(s string) uint64 {
 	h := md5.New()
 	_, _ = io.WriteString(h, s)
 	b := h.Sum(nil)
@@ -6,9 +6,13 @@
 package strsearch
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
+	"math/rand"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -87,6 +91,33 @@ var tests = []struct {
 		},
 		wantIndices: nil,
 	},
+	{
+		name: "periodic-overlapping",
+		args: args{
+			s:          "abababcabababab",
+			substr:     "abab",
+			maxMatches: -1,
+		},
+		wantIndices: []int{0, 2, 7, 9, 11},
+	},
+	{
+		name: "good-suffix",
+		args: args{
+			s:          "GCATCGCAGAGAGTATACAGTACG",
+			substr:     "GCAGAGAG",
+			maxMatches: -1,
+		},
+		wantIndices: []int{5},
+	},
+	{
+		name: "whole-text",
+		args: args{
+			s:          "abc",
+			substr:     "abc",
+			maxMatches: -1,
+		},
+		wantIndices: []int{0},
+	},
 	{
 		name: "text-single-match",
 		args: args{
@@ -161,6 +192,159 @@ func TestRabinKarpSearch(t *testing.T) {
 	}
 }
 
+func TestBoyerMooreSearch(t *testing.T) {
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if gotIndices := BoyerMooreSearch(tt.args.s, tt.args.substr, tt.args.maxMatches); !reflect.DeepEqual(gotIndices, tt.wantIndices) {
+				t.Errorf("BoyerMooreSearch() = %v, want %v", gotIndices, tt.wantIndices)
+			}
+		})
+	}
+}
+
+func TestHorspoolSearch(t *testing.T) {
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if gotIndices := HorspoolSearch(tt.args.s, tt.args.substr, tt.args.maxMatches); !reflect.DeepEqual(gotIndices, tt.wantIndices) {
+				t.Errorf("HorspoolSearch() = %v, want %v", gotIndices, tt.wantIndices)
+			}
+		})
+	}
+}
+
+func TestSundaySearch(t *testing.T) {
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if gotIndices := SundaySearch(tt.args.s, tt.args.substr, tt.args.maxMatches); !reflect.DeepEqual(gotIndices, tt.wantIndices) {
+				t.Errorf("SundaySearch() = %v, want %v", gotIndices, tt.wantIndices)
+			}
+		})
+	}
+}
+
+func TestRabinKarpMd5Search(t *testing.T) {
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if gotIndices := RabinKarpMd5Search(tt.args.s, tt.args.substr, tt.args.maxMatches); !reflect.DeepEqual(gotIndices, tt.wantIndices) {
+				t.Errorf("RabinKarpMd5Search() = %v, want %v", gotIndices, tt.wantIndices)
+			}
+		})
+	}
+}
+
+func TestZSearch(t *testing.T) {
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if gotIndices := ZSearch(tt.args.s, tt.args.substr, tt.args.maxMatches); !reflect.DeepEqual(gotIndices, tt.wantIndices) {
+				t.Errorf("ZSearch() = %v, want %v", gotIndices, tt.wantIndices)
+			}
+		})
+	}
+}
+
+func TestTwoWaySearch(t *testing.T) {
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if gotIndices := TwoWaySearch(tt.args.s, tt.args.substr, tt.args.maxMatches); !reflect.DeepEqual(gotIndices, tt.wantIndices) {
+				t.Errorf("TwoWaySearch() = %v, want %v", gotIndices, tt.wantIndices)
+			}
+		})
+	}
+}
+
+// TestLinearSearchRandom 在小字母表的随机文本上对比线性时间算法与暴力法，覆盖各种周期性的模式串
+func TestLinearSearchRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	random := func(n int, alphabet string) string {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = alphabet[r.Intn(len(alphabet))]
+		}
+		return string(b)
+	}
+	for i := 0; i < 2000; i++ {
+		alphabet := "ab"
+		if i%2 == 1 {
+			alphabet = "abc"
+		}
+		s := random(r.Intn(200), alphabet)
+		substr := random(1+r.Intn(8), alphabet)
+		if i%5 == 0 && len(s) > 10 { // 取自文本，保证有匹配
+			start := r.Intn(len(s) - 10)
+			substr = s[start : start+1+r.Intn(10)]
+		}
+		want := NaiveSearchByChar(s, substr, 0)
+		for name, algorithm := range map[string]StrSearchAlgorithm{"Z": ZSearch, "TwoWay": TwoWaySearch} {
+			if got := algorithm(s, substr, 0); !reflect.DeepEqual(got, want) {
+				t.Fatalf("%v(%q, %q) = %v, want %v", name, s, substr, got, want)
+			}
+		}
+	}
+}
+
+func TestGoStlRegSearch(t *testing.T) {
+	literalTests := append(tests[:len(tests):len(tests)],
+		struct {
+			name        string
+			args        args
+			wantIndices []int
+		}{"literal-meta", args{"C++ 与 C 与 a.b 与 axb (注)", "C++", -1}, []int{0}},
+		struct {
+			name        string
+			args        args
+			wantIndices []int
+		}{"literal-dot", args{"C++ 与 C 与 a.b 与 axb (注)", "a.b", -1}, []int{14}},
+		struct {
+			name        string
+			args        args
+			wantIndices []int
+		}{"literal-paren", args{"C++ 与 C 与 a.b 与 axb (注)", "(注)", -1}, []int{26}},
+	)
+	for _, tt := range literalTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if gotIndices := goStlRegSearch(tt.args.s, tt.args.substr, tt.args.maxMatches); !reflect.DeepEqual(gotIndices, tt.wantIndices) {
+				t.Errorf("goStlRegSearch() = %v, want %v", gotIndices, tt.wantIndices)
+			}
+		})
+	}
+}
+
+func TestRegexpSearch(t *testing.T) {
+	indices, err := RegexpSearch("a.b axb ab", "a.b", -1)
+	if err != nil || !reflect.DeepEqual(indices, []int{0, 4}) {
+		t.Errorf("RegexpSearch() = %v, %v, want [0 4], nil", indices, err)
+	}
+
+	for _, expr := range []string{"C++", "(注", "a[b"} {
+		if _, err := RegexpSearch("C++ (注) a[b", expr, -1); err == nil {
+			t.Errorf("RegexpSearch(%q) want error, got nil", expr)
+		}
+		if err := ValidatePattern(LibRegexp, expr); err == nil {
+			t.Errorf("ValidatePattern(LibRegexp, %q) want error, got nil", expr)
+		}
+		if err := ValidatePattern(LibRe, expr); err != nil {
+			t.Errorf("ValidatePattern(LibRe, %q) = %v, want nil", expr, err)
+		}
+		if got := By(LibRegexp).FindAll("C++ (注) a[b", expr); got != nil {
+			t.Errorf("By(LibRegexp).FindAll(%q) = %v, want nil", expr, got)
+		}
+	}
+}
+
+func TestValidateOptions(t *testing.T) {
+	for _, opts := range []MatchOptions{{IgnoreCase: true}, {WholeWord: true}, {NormalizeWidth: true}} {
+		if err := ValidateOptions(LibRegexp, opts); err == nil {
+			t.Errorf("ValidateOptions(LibRegexp, %+v) want error, got nil", opts)
+		}
+		if err := ValidateOptions(Kmp, opts); err != nil {
+			t.Errorf("ValidateOptions(Kmp, %+v) = %v, want nil", opts, err)
+		}
+	}
+	if err := ValidateOptions(LibRegexp, MatchOptions{}); err != nil {
+		t.Errorf("ValidateOptions(LibRegexp, zero) = %v, want nil", err)
+	}
+}
+
 func TestEff(t *testing.T) {
 	data, err := ioutil.ReadFile("testing_text.txt")
 	if err != nil {
@@ -186,6 +370,24 @@ func TestEff(t *testing.T) {
 	elapsed, res = stringMatchElapsedJudge(RabinKarpSearch, text, pattern, 0)
 	fmt.Println("RabinKarpSearch:\t", elapsed, res)
 
+	elapsed, res = stringMatchElapsedJudge(RabinKarpMd5Search, text, pattern, 0)
+	fmt.Println("RabinKarpMd5Search:\t", elapsed, res)
+
+	elapsed, res = stringMatchElapsedJudge(BoyerMooreSearch, text, pattern, 0)
+	fmt.Println("BoyerMooreSearch:\t", elapsed, res)
+
+	elapsed, res = stringMatchElapsedJudge(HorspoolSearch, text, pattern, 0)
+	fmt.Println("HorspoolSearch:\t\t", elapsed, res)
+
+	elapsed, res = stringMatchElapsedJudge(SundaySearch, text, pattern, 0)
+	fmt.Println("SundaySearch:\t\t", elapsed, res)
+
+	elapsed, res = stringMatchElapsedJudge(ZSearch, text, pattern, 0)
+	fmt.Println("ZSearch:\t\t\t", elapsed, res)
+
+	elapsed, res = stringMatchElapsedJudge(TwoWaySearch, text, pattern, 0)
+	fmt.Println("TwoWaySearch:\t\t", elapsed, res)
+
 	now := time.Now()
 	//reg := regexp.MustCompile(pattern)
 	//r := reg.FindAllIndex(data, -1)
@@ -217,3 +419,375 @@ func TestInterface(t *testing.T) {
 	r := By(LibRe).FindAllBytes(data, pattern)
 	t.Log(r)
 }
+
+func TestAhoCorasickSearch(t *testing.T) {
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if gotIndices := AhoCorasickSearch(tt.args.s, tt.args.substr, tt.args.maxMatches); !reflect.DeepEqual(gotIndices, tt.wantIndices) {
+				t.Errorf("AhoCorasickSearch() = %v, want %v", gotIndices, tt.wantIndices)
+			}
+		})
+	}
+}
+
+func TestMultiBy(t *testing.T) {
+	data, err := ioutil.ReadFile("testing_text.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	patterns := []string{"阿Ｑ", "阿", "Ｑ", "一九一八年", "那么，", "他", "他们", "没有的东西"}
+
+	want := MultiBy(Kmp).FindAllBytes(data, patterns)
+	for name, algorithm := range StrsearchAlgorithmsMap {
+		t.Run(name, func(t *testing.T) {
+			got := MultiBy(algorithm).FindAllBytes(data, patterns)
+			for _, p := range patterns {
+				if !reflect.DeepEqual(got[p], want[p]) {
+					t.Errorf("MultiBy(%v) %q: got %v matches, want %v", name, p, len(got[p]), len(want[p]))
+				}
+			}
+		})
+	}
+}
+
+func TestPositions(t *testing.T) {
+	text := "神经网络\n是一个个「层」组成的。\nab层"
+	indices := KmpSearch(text, "层", -1)
+
+	want := []Position{
+		{Byte: 28, Rune: 10, Line: 2, Column: 6},
+		{Byte: 49, Rune: 19, Line: 3, Column: 3},
+	}
+	if got := Positions(text, indices); !reflect.DeepEqual(got, want) {
+		t.Errorf("Positions() = %v, want %v", got, want)
+	}
+	if got := ConvertIndices(text, indices, RuneIndex); !reflect.DeepEqual(got, []int{10, 19}) {
+		t.Errorf("ConvertIndices(RuneIndex) = %v, want [10 19]", got)
+	}
+	if got := ConvertIndices(text, indices, LineIndex); !reflect.DeepEqual(got, []int{2, 3}) {
+		t.Errorf("ConvertIndices(LineIndex) = %v, want [2 3]", got)
+	}
+	if got := ConvertIndices(text, indices, ByteIndex); !reflect.DeepEqual(got, indices) {
+		t.Errorf("ConvertIndices(ByteIndex) = %v, want %v", got, indices)
+	}
+	// 非升序的索引
+	if got := Positions(text, []int{indices[1], indices[0]}); !reflect.DeepEqual(got, []Position{want[1], want[0]}) {
+		t.Errorf("Positions() unsorted = %v, want %v", got, []Position{want[1], want[0]})
+	}
+}
+
+func TestFindAllReader(t *testing.T) {
+	data, err := ioutil.ReadFile("testing_text.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = data[:len(data)/3]
+	patterns := []string{"阿Ｑ", "阿", "一九一八年", "那么，", "他们", "aa", "没有的东西"}
+	want := MultiBy(Kmp).FindAllBytes(data, patterns)
+
+	for _, chunkSize := range []int{5, 64, 4096, len(data), 2 * len(data)} {
+		for _, algorithm := range []int{Kmp, AhoCorasick, RabinKarp, LibRe} {
+			for _, workers := range []int{1, 4} {
+				got, err := findAllReader(MultiBy(algorithm), bytes.NewReader(data), patterns, chunkSize, MatchOptions{}, workers)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, p := range patterns {
+					if !reflect.DeepEqual(got[p], want[p]) {
+						t.Errorf("findAllReader(algorithm=%v, chunkSize=%v, workers=%v) %q: got %v matches, want %v",
+							algorithm, chunkSize, workers, p, len(got[p]), len(want[p]))
+					}
+				}
+			}
+		}
+	}
+
+	// 跨块的重叠匹配
+	got, err := findAllReader(MultiBy(Naive), strings.NewReader("aaaaaaa"), []string{"aaa", "a"}, 2, MatchOptions{}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got["aaa"], []int{0, 1, 2, 3, 4}) || !reflect.DeepEqual(got["a"], []int{0, 1, 2, 3, 4, 5, 6}) {
+		t.Errorf("findAllReader overlapping = %v", got)
+	}
+
+	indices, err := By(Kmp).FindAllReader(bytes.NewReader(data), "一九一八年")
+	if err != nil || !reflect.DeepEqual(indices, want["一九一八年"]) {
+		t.Errorf("FindAllReader() = %v, %v, want %v", indices, err, want["一九一八年"])
+	}
+}
+
+func TestFindAllBytesParallel(t *testing.T) {
+	data, err := ioutil.ReadFile("testing_text.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	patterns := []string{"阿Ｑ", "阿", "一九一八年", "那么，", "他们", "没有的东西"}
+	want := MultiBy(Kmp).FindAllBytes(data, patterns)
+
+	for name, algorithm := range StrsearchAlgorithmsMap {
+		if algorithm == RabinKarpMd5 {
+			continue // 太慢
+		}
+		for _, par := range []ParallelOptions{{Workers: 4, ChunkSize: 1000}, {Workers: 3, ChunkSize: 4096}, {Workers: 8}} {
+			got := MultiBy(algorithm).FindAllBytesParallel(data, patterns, MatchOptions{}, par)
+			for _, p := range patterns {
+				if !reflect.DeepEqual(got[p], want[p]) {
+					t.Errorf("%v: FindAllBytesParallel(%+v) %q: got %v matches, want %v", name, par, p, len(got[p]), len(want[p]))
+				}
+			}
+		}
+	}
+
+	got, err := MultiBy(AhoCorasick).FindAllReaderParallel(bytes.NewReader(data), patterns, MatchOptions{},
+		ParallelOptions{Workers: 4, ChunkSize: 1 << 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range patterns {
+		if !reflect.DeepEqual(got[p], want[p]) {
+			t.Errorf("FindAllReaderParallel() %q: got %v matches, want %v", p, len(got[p]), len(want[p]))
+		}
+	}
+}
+
+func TestFindAllContext(t *testing.T) {
+	data, err := ioutil.ReadFile("testing_text.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	patterns := []string{"阿Ｑ", "他们"}
+	par := ParallelOptions{Workers: 1, ChunkSize: 1 << 12}
+	want := MultiBy(Kmp).FindAllBytes(data, patterns)
+
+	got, err := MultiBy(Kmp).FindAllBytesContext(context.Background(), data, patterns, MatchOptions{}, par)
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("FindAllBytesContext() = %v matches, %v; want %v matches", len(got), err, len(want))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := MultiBy(Kmp).FindAllBytesContext(ctx, data, patterns, MatchOptions{}, par); err != context.Canceled {
+		t.Errorf("FindAllBytesContext(canceled) error = %v, want %v", err, context.Canceled)
+	}
+	if _, err := MultiBy(Kmp).FindAllReaderContext(ctx, bytes.NewReader(data), patterns, MatchOptions{}, par); err != context.Canceled {
+		t.Errorf("FindAllReaderContext(canceled) error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestMultiByContext(t *testing.T) {
+	data, err := ioutil.ReadFile("testing_text.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	patterns := []string{"阿Ｑ", "他们", "一九一八年"}
+	for name, algorithm := range StrsearchAlgorithmsMap {
+		want := MultiBy(algorithm).FindAllBytes(data, patterns)
+		ctx, cancel := context.WithCancel(context.Background())
+		if got := MultiByContext(ctx, algorithm).FindAllBytes(data, patterns); !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: MultiByContext() = %v matches, want %v", name, len(got), len(want))
+		}
+
+		// 已取消时，搜索在内层循环中停止，找不到 64 Ki 之后的匹配
+		cancel()
+		text := append(bytes.Repeat([]byte("-"), 4*cancelCheckInterval), "阿Ｑ"...)
+		if got := MultiByContext(ctx, algorithm).FindAllBytes(text, patterns); len(got["阿Ｑ"]) != 0 {
+			t.Errorf("%v: MultiByContext(canceled) = %v, want no matches", name, got)
+		}
+		par := ParallelOptions{Workers: 1, ChunkSize: len(text)}
+		if _, err := MultiByContext(ctx, algorithm).FindAllBytesContext(ctx, text, patterns, MatchOptions{}, par); err != context.Canceled {
+			t.Errorf("%v: FindAllBytesContext(canceled) error = %v, want %v", name, err, context.Canceled)
+		}
+	}
+}
+
+func TestRegexpSearchContext(t *testing.T) {
+	texts := []string{
+		"abc abc\nabc xabc", "aaa", "", "a\nb\n\nc", "你好 世界 你好", "\xff\xfeab\xe4\xb8ab", "ab ab  ab",
+	}
+	exprs := []string{
+		"abc", "^abc", "(?m)^abc", "\\babc", "\\Babc", "abc$", "a*", "x*", "(?m)^", "(?m)$", "\\b", "(a)(b)?",
+		"你好|世界", "(?i)ABC", "ab|a", "\\s+", ".", "[^a]",
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for _, text := range texts {
+		for _, expr := range exprs {
+			for _, max := range []int{-1, 1, 2} {
+				want := goStlRegexpSearchBytes([]byte(text), expr, max)
+				if got := regexpSearchContext(ctx, []byte(text), expr, max); !reflect.DeepEqual(got, want) {
+					t.Errorf("regexpSearchContext(%q, %q, %v) = %v, want %v", text, expr, max, got, want)
+				}
+			}
+		}
+	}
+
+	// 长于 cancelCheckInterval 的文本，匹配跨越按窗口查找时的窗口边界
+	long := []byte(strings.Repeat("你好abc \nxyz aab", 3*cancelCheckInterval/17))
+	for _, expr := range []string{"abc \nx", "a{1,3}b", "好.{0,3}c", "(?s).{0,20}?xyz", "[a-z]{2}", "x*", "\\w+", "(?m)^xyz", "\\bab"} {
+		want := goStlRegexpSearchBytes(long, expr, -1)
+		if got := regexpSearchContext(ctx, long, expr, -1); !reflect.DeepEqual(got, want) {
+			t.Errorf("regexpSearchContext(long, %q) = %v matches, want %v", expr, len(got), len(want))
+		}
+	}
+	// 唯一的匹配跨越第一个窗口的末尾；窗口末尾不是文本末尾，\z 不能在那里匹配
+	sparse := []byte(strings.Repeat("-", cancelCheckInterval-2) + "xxxxxxxxx" + strings.Repeat("-", cancelCheckInterval))
+	for _, expr := range []string{"x{1,9}", "-\\z"} {
+		want := goStlRegexpSearchBytes(sparse, expr, -1)
+		if got := regexpSearchContext(ctx, sparse, expr, -1); !reflect.DeepEqual(got, want) {
+			t.Errorf("regexpSearchContext(sparse, %q) = %v, want %v", expr, got, want)
+		}
+	}
+}
+
+func TestBytesBy(t *testing.T) {
+	for name, algorithm := range StrsearchAlgorithmsMap {
+		for _, tt := range tests {
+			got := BytesBy(algorithm)([]byte(tt.args.s), tt.args.substr, tt.args.maxMatches)
+			want := By(algorithm)(tt.args.s, tt.args.substr, tt.args.maxMatches)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%v %v: BytesBy() = %v, want %v", name, tt.name, got, want)
+			}
+		}
+	}
+}
+
+// BenchmarkFindAllBytes 对比在 []byte 上原生检索与先转换为 string 再检索的耗时与内存分配:
+//		go test -bench FindAllBytes -benchmem
+func BenchmarkFindAllBytes(b *testing.B) {
+	data, err := ioutil.ReadFile("testing_text.txt")
+	if err != nil {
+		b.Fatal(err)
+	}
+	pattern := "一九一八年"
+
+	for _, name := range []string{"Naive", "Kmp", "RabinKarp", "LibRe"} {
+		algorithm := StrsearchAlgorithmsMap[name]
+		b.Run(name+"/string", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				By(algorithm)(string(data), pattern, -1)
+			}
+		})
+		b.Run(name+"/bytes", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				BytesBy(algorithm).FindAll(data, pattern)
+			}
+		})
+	}
+}
+
+func TestApproxSearch(t *testing.T) {
+	approxTests := []struct {
+		name    string
+		s       string
+		pattern string
+		k       int
+		want    []ApproxMatch
+	}{
+		{"exact", "xxabcxx", "abc", 1, []ApproxMatch{{2, 5, 0}}},
+		{"substitution", "the quick brwn fox and the quick brown fox", "brown", 1, []ApproxMatch{{10, 14, 1}, {33, 38, 0}}},
+		{"k=0", "the quick brwn fox and the quick brown fox", "brown", 0, []ApproxMatch{{33, 38, 0}}},
+		{"chinese", "阿Q正传，阿Ｑ正传，阿贵正传", "阿Q正传", 1, []ApproxMatch{{0, 10, 0}, {13, 25, 1}, {28, 40, 1}}},
+		{"insertion", "阿Q真正传", "阿Q正传", 1, []ApproxMatch{{0, 13, 1}}},
+		{"deletion", "阿正传", "阿Q正传", 1, []ApproxMatch{{0, 9, 1}}},
+		{"too-far", "阿贵真正传", "阿Q正传", 1, nil},
+		{"empty", "", "abc", 1, nil},
+	}
+	for _, tt := range approxTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ApproxSearch(tt.s, tt.pattern, tt.k, -1); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ApproxSearch() = %v, want %v", got, tt.want)
+			}
+			got, err := ApproxSearchReader(strings.NewReader(tt.s), []string{tt.pattern}, tt.k, MatchOptions{})
+			if err != nil || !reflect.DeepEqual(got[tt.pattern], tt.want) {
+				t.Errorf("ApproxSearchReader() = %v, %v, want %v", got[tt.pattern], err, tt.want)
+			}
+		})
+	}
+
+	if got := ApproxSearch("阿Q正传，阿Ｑ正传，阿贵正传", "阿Q正传", 1, 2); len(got) != 2 {
+		t.Errorf("ApproxSearch() with maxMatches=2 got %v matches", len(got))
+	}
+	// 忽略大小写、全半角
+	got, err := ApproxSearchReader(strings.NewReader("阿q正传，阿Ｑ正传"), []string{"阿Q正传"}, 1,
+		MatchOptions{IgnoreCase: true, NormalizeWidth: true})
+	if want := []ApproxMatch{{0, 10, 0}, {13, 25, 0}}; err != nil || !reflect.DeepEqual(got["阿Q正传"], want) {
+		t.Errorf("ApproxSearchReader() with options = %v, %v, want %v", got["阿Q正传"], err, want)
+	}
+	// k=0 时与精确匹配一致
+	data, err := ioutil.ReadFile("testing_text.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var starts []int
+	for _, m := range ApproxSearch(string(data), "一九一八年", 0, -1) {
+		starts = append(starts, m.Start)
+	}
+	if want := KmpSearch(string(data), "一九一八年", -1); !reflect.DeepEqual(starts, want) {
+		t.Errorf("ApproxSearch(k=0) = %v, want %v", starts, want)
+	}
+}
+
+func TestMatchOptions(t *testing.T) {
+	text := "Max said: MAX is the maximum. ＭＡＸ？max_val, max2 and K(kelvin) vs \u212a; 阿Q和阿ｑ。사랑해 사랑"
+	optsTests := []struct {
+		name    string
+		opts    MatchOptions
+		pattern string
+		want    []string // 匹配到的原文
+	}{
+		{"zero", MatchOptions{}, "max", []string{"max", "max", "max"}},
+		{"ignore-case", MatchOptions{IgnoreCase: true}, "max", []string{"Max", "MAX", "max", "max", "max"}},
+		{"whole-word", MatchOptions{WholeWord: true}, "max", nil},
+		{"case-word", MatchOptions{IgnoreCase: true, WholeWord: true}, "max", []string{"Max", "MAX"}},
+		{"width", MatchOptions{NormalizeWidth: true}, "MAX", []string{"MAX", "ＭＡＸ"}},
+		{"all", MatchOptions{IgnoreCase: true, WholeWord: true, NormalizeWidth: true}, "max", []string{"Max", "MAX", "ＭＡＸ"}},
+		{"kelvin", MatchOptions{IgnoreCase: true}, "k", []string{"K", "k", "\u212a"}},
+		{"cjk-word", MatchOptions{IgnoreCase: true, WholeWord: true, NormalizeWidth: true}, "阿q", []string{"阿Q", "阿ｑ"}},
+		{"hangul", MatchOptions{}, "사랑", []string{"사랑", "사랑"}},
+		{"hangul-word", MatchOptions{WholeWord: true}, "사랑", []string{"사랑"}}, // 谚文以空格分词
+	}
+	for _, tt := range optsTests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, algorithm := range []int{Kmp, AhoCorasick, RabinKarp} {
+				got := MultiBy(algorithm).FindAllBytesOptions([]byte(text), []string{tt.pattern}, tt.opts)[tt.pattern]
+				var matched []string
+				for _, i := range got {
+					// 匹配的原文: 从 i 开始，规范化后等于 pattern 的最短前缀
+					for j := i + 1; j <= len(text); j++ {
+						if tt.opts.NormalizeString(text[i:j]) == tt.opts.NormalizeString(tt.pattern) {
+							matched = append(matched, text[i:j])
+							break
+						}
+					}
+				}
+				if !reflect.DeepEqual(matched, tt.want) {
+					t.Errorf("algorithm %v: FindAllBytesOptions() matched %q, want %q", algorithm, matched, tt.want)
+				}
+
+				// 流式搜索的结果与一次性搜索一致
+				for _, chunkSize := range []int{1, 3, 7, len(text)} {
+					streamed, err := findAllReader(MultiBy(algorithm), strings.NewReader(text), []string{tt.pattern}, chunkSize, tt.opts, 1)
+					if err != nil || !reflect.DeepEqual(streamed[tt.pattern], got) {
+						t.Errorf("algorithm %v: findAllReader(chunkSize=%v) = %v, %v, want %v",
+							algorithm, chunkSize, streamed[tt.pattern], err, got)
+					}
+					parallel := MultiBy(algorithm).FindAllBytesParallel([]byte(text), []string{tt.pattern}, tt.opts,
+						ParallelOptions{Workers: 3, ChunkSize: chunkSize})
+					if !reflect.DeepEqual(parallel[tt.pattern], got) {
+						t.Errorf("algorithm %v: FindAllBytesParallel(chunkSize=%v) = %v, want %v",
+							algorithm, chunkSize, parallel[tt.pattern], got)
+					}
+				}
+			}
+		})
+	}
+
+	// 规范化后相同的模式串各自得到全部匹配
+	got := MultiBy(AhoCorasick).FindAllBytesOptions([]byte("Go go GO"), []string{"go", "GO", "go"}, MatchOptions{IgnoreCase: true})
+	if want := []int{0, 3, 6}; !reflect.DeepEqual(got["go"], want) || !reflect.DeepEqual(got["GO"], want) {
+		t.Errorf("FindAllBytesOptions() duplicated patterns = %v, want %v for each", got, want)
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2020 CDFMLR. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at  http://www.apache.org/licenses/LICENSE-2.0
+
+package strsearch
+
+// Two-Way algorithm (Crochemore-Perrin)
+// 把模式串在临界分解处分为左右两部分，先从左向右比较右半部分，再从右向左比较左半部分。
+// 时间 O(n+m)，除结果外只需 O(1) 的额外空间
+func TwoWaySearch(s, substr string, maxMatches int) (indices []int) {
+	if len(s) == 0 || len(substr) == 0 || len(substr) > len(s) {
+		return indices
+	}
+	m := len(substr)
+	ell, per := criticalFactorization(substr)
+
+	if ell+1+per <= m && substr[:ell+1] == substr[per:per+ell+1] {
+		// 模式串有周期 per: 完全匹配后右移 per，且已知 substr[:memory+1] 与文本相同
+		memory := -1
+		for j := 0; j <= len(s)-m; {
+			i := ell + 1
+			if memory > ell {
+				i = memory + 1
+			}
+			for i < m && substr[i] == s[i+j] {
+				i++
+			}
+			if i < m {
+				j += i - ell
+				memory = -1
+				continue
+			}
+			i = ell
+			for i > memory && substr[i] == s[i+j] {
+				i--
+			}
+			if i <= memory {
+				indices = append(indices, j)
+				if maxMatches > 0 && len(indices) >= maxMatches {
+					return indices
+				}
+			}
+			j += per
+			memory = m - per - 1
+		}
+		return indices
+	}
+
+	// 模式串没有较小的周期: 左半部分失配或完全匹配时，右移 max(ell+1, m-ell-1)+1
+	per = ell + 1
+	if m-ell-1 > per {
+		per = m - ell - 1
+	}
+	per++
+	for j := 0; j <= len(s)-m; {
+		i := ell + 1
+		for i < m && substr[i] == s[i+j] {
+			i++
+		}
+		if i < m {
+			j += i - ell
+			continue
+		}
+		i = ell
+		for i >= 0 && substr[i] == s[i+j] {
+			i--
+		}
+		if i < 0 {
+			indices = append(indices, j)
+			if maxMatches > 0 && len(indices) >= maxMatches {
+				return indices
+			}
+		}
+		j += per
+	}
+	return indices
+}
+
+// criticalFactorization 返回模式串的临界分解 substr[:ell+1]、substr[ell+1:]，
+// 以及右半部分的周期 per。分别按字节的正序、逆序求最大后缀，取起点较靠后的一个
+func criticalFactorization(substr string) (ell, per int) {
+	i, p := maximalSuffix(substr, false)
+	j, q := maximalSuffix(substr, true)
+	if i > j {
+		return i, p
+	}
+	return j, q
+}
+
+// maximalSuffix 返回 substr 的(按 reverse 指定的字节序)最大后缀的起点 - 1，及其周期
+func maximalSuffix(substr string, reverse bool) (ms, per int) {
+	ms, j, k, per := -1, 0, 1, 1
+	for j+k < len(substr) {
+		a, b := substr[j+k], substr[ms+k]
+		if reverse {
+			a, b = b, a
+		}
+		switch {
+		case a < b:
+			j += k
+			k = 1
+			per = j - ms
+		case a == b:
+			if k != per {
+				k++
+			} else {
+				j += per
+				k = 1
+			}
+		default:
+			ms = j
+			j = ms + 1
+			k, per = 1, 1
+		}
+	}
+	return ms, per
+}
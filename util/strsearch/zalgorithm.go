@@ -0,0 +1,62 @@
+// Copyright (c) 2020 CDFMLR. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at  http://www.apache.org/licenses/LICENSE-2.0
+
+package strsearch
+
+// Z algorithm (Gusfield)
+// 维护文本中与模式串前缀相同的最右区间 [l, r)，区间内的位置借助模式串的 Z 数组跳过已知相同的字符，
+// 时间 O(n+m)，空间 O(m)
+func ZSearch(s, substr string, maxMatches int) (indices []int) {
+	if len(s) == 0 || len(substr) == 0 || len(substr) > len(s) {
+		return indices
+	}
+	m := len(substr)
+	z := computeZ(substr)
+
+	l, r := 0, 0 // s[l:r] == substr[:r-l]
+	for i := 0; i <= len(s)-m; i++ {
+		k := 0 // s[i:] 与 substr 的最长公共前缀长度
+		if i < r && z[i-l] < r-i {
+			continue // s[i:r] == substr[i-l:r-l]，与 substr 的公共前缀长度为 z[i-l] < m
+		}
+		if i < r {
+			k = r - i
+		}
+		for k < m && s[i+k] == substr[k] {
+			k++
+		}
+		l, r = i, i+k
+		if k == m {
+			indices = append(indices, i)
+			if maxMatches > 0 && len(indices) >= maxMatches {
+				return indices
+			}
+		}
+	}
+	return indices
+}
+
+// computeZ 计算 Z 数组: z[i] 是 substr[i:] 与 substr 的最长公共前缀长度，z[0] = len(substr)
+func computeZ(substr string) []int {
+	m := len(substr)
+	z := make([]int, m)
+	z[0] = m
+	l, r := 0, 0
+	for i := 1; i < m; i++ {
+		if i < r {
+			z[i] = z[i-l]
+			if z[i] > r-i {
+				z[i] = r - i
+			}
+		}
+		for i+z[i] < m && substr[z[i]] == substr[i+z[i]] {
+			z[i]++
+		}
+		if i+z[i] > r {
+			l, r = i, i+z[i]
+		}
+	}
+	return z
+}
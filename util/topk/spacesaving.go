@@ -0,0 +1,185 @@
+// Copyright (c) 2020 CDFMLR. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at  http://www.apache.org/licenses/LICENSE-2.0
+
+// Package topk 提供内存有界的频数统计，用于在大数据流中找出出现最频繁的元素(heavy hitters)。
+//
+// SpaceSaving 实现 Metwally 等人的 Space-Saving 算法：至多记录 capacity 个元素，
+// 计数器满时，新元素替换计数最小的元素并继承其计数。
+// 每个元素的计数 Count 是真实频数的上界，Count - Error 是下界；
+// 真实频数大于 总数 / capacity 的元素一定会被记录。
+package topk
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// Item 是 SpaceSaving 记录的一个元素
+type Item struct {
+	Key   string
+	Count int // 计数，不小于真实频数
+	Error int // 计数至多比真实频数大 Error
+}
+
+type entry struct {
+	Item
+	index int // 在堆中的下标
+}
+
+// minHeap 是按 Count 排序的小根堆
+type minHeap []*entry
+
+func (h minHeap) Len() int           { return len(h) }
+func (h minHeap) Less(i, j int) bool { return h[i].Count < h[j].Count }
+func (h minHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *minHeap) Push(x interface{}) {
+	e := x.(*entry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *minHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+// SpaceSaving 是至多记录 capacity 个元素的 Space-Saving 计数器，不是并发安全的
+type SpaceSaving struct {
+	capacity int
+	entries  map[string]*entry
+	heap     minHeap
+	total    int // 所有 Add 的计数之和
+}
+
+// New 返回一个至多记录 capacity (至少为 1) 个元素的计数器
+func New(capacity int) *SpaceSaving {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &SpaceSaving{capacity: capacity, entries: map[string]*entry{}}
+}
+
+// Add 把元素 key 的计数加 count
+func (s *SpaceSaving) Add(key string, count int) {
+	s.add(key, count, 0)
+}
+
+func (s *SpaceSaving) add(key string, count, err int) {
+	s.total += count
+	if e, ok := s.entries[key]; ok {
+		e.Count += count
+		e.Error += err
+		heap.Fix(&s.heap, e.index)
+		return
+	}
+	if len(s.heap) < s.capacity {
+		e := &entry{Item: Item{Key: key, Count: count, Error: err}}
+		s.entries[key] = e
+		heap.Push(&s.heap, e)
+		return
+	}
+	// 替换计数最小的元素，新元素继承其计数作为误差
+	min := s.heap[0]
+	delete(s.entries, min.Key)
+	min.Key = key
+	min.Error = min.Count + err
+	min.Count += count
+	s.entries[key] = min
+	heap.Fix(&s.heap, 0)
+}
+
+// Merge 把另一个计数器 other 的所有记录合并进 s，合并后的计数与误差仍满足上、下界。
+// 只在一方有记录的元素，在另一方的频数至多为那一方的最小计数(计数器未满时为 0)，
+// 所以计数与误差都加上该最小计数。合并后只保留计数最大的 capacity 个元素
+func (s *SpaceSaving) Merge(other *SpaceSaving) {
+	sMin, oMin := s.minCount(), other.minCount()
+	merged := make(map[string]Item, len(s.entries)+len(other.entries))
+	for key, e := range s.entries {
+		item := e.Item
+		if _, ok := other.entries[key]; !ok {
+			item.Count += oMin
+			item.Error += oMin
+		}
+		merged[key] = item
+	}
+	for key, e := range other.entries {
+		item, ok := merged[key]
+		if ok {
+			item.Count += e.Count
+			item.Error += e.Error
+		} else {
+			item = e.Item
+			item.Count += sMin
+			item.Error += sMin
+		}
+		merged[key] = item
+	}
+
+	items := make([]Item, 0, len(merged))
+	for _, item := range merged {
+		items = append(items, item)
+	}
+	sortItems(items)
+	if len(items) > s.capacity {
+		items = items[:s.capacity]
+	}
+	s.entries = make(map[string]*entry, len(items))
+	s.heap = make(minHeap, len(items))
+	for i, item := range items {
+		e := &entry{Item: item, index: i}
+		s.entries[item.Key] = e
+		s.heap[i] = e
+	}
+	heap.Init(&s.heap)
+	s.total += other.total
+}
+
+// minCount 返回计数器已满时的最小计数，未满时返回 0 (没有记录的元素频数为 0)
+func (s *SpaceSaving) minCount() int {
+	if len(s.heap) < s.capacity {
+		return 0
+	}
+	return s.heap[0].Count
+}
+
+// Total 返回所有 Add 的计数之和
+func (s *SpaceSaving) Total() int {
+	return s.total
+}
+
+// Len 返回记录的元素个数
+func (s *SpaceSaving) Len() int {
+	return len(s.heap)
+}
+
+// Top 返回计数最大的至多 k 个元素，按计数从大到小排列，k <= 0 时返回全部
+func (s *SpaceSaving) Top(k int) []Item {
+	items := make([]Item, len(s.heap))
+	for i, e := range s.heap {
+		items[i] = e.Item
+	}
+	sortItems(items)
+	if k > 0 && len(items) > k {
+		items = items[:k]
+	}
+	return items
+}
+
+// sortItems 把 items 按计数从大到小排序，计数相同时按 Key 排序
+func sortItems(items []Item) {
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Count != items[j].Count {
+			return items[i].Count > items[j].Count
+		}
+		return items[i].Key < items[j].Key
+	})
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2020 CDFMLR. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at  http://www.apache.org/licenses/LICENSE-2.0
+
+package topk
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// zipfStream 返回服从 Zipf 分布的随机元素序列及各元素的真实频数
+func zipfStream(seed int64, n int) ([]string, map[string]int) {
+	r := rand.New(rand.NewSource(seed))
+	z := rand.NewZipf(r, 1.2, 1, 5000)
+	stream := make([]string, n)
+	freq := map[string]int{}
+	for i := range stream {
+		stream[i] = fmt.Sprint("k", z.Uint64())
+		freq[stream[i]]++
+	}
+	return stream, freq
+}
+
+// checkBounds 检查 s 中每个元素的计数都是真实频数的上界，Count - Error 是下界
+func checkBounds(t *testing.T, name string, s *SpaceSaving, freq map[string]int) {
+	for _, item := range s.Top(0) {
+		if f := freq[item.Key]; item.Count < f || item.Count-item.Error > f {
+			t.Errorf("%v: %v: count %v, error %v, but frequency is %v", name, item.Key, item.Count, item.Error, f)
+		}
+	}
+}
+
+func TestSpaceSaving(t *testing.T) {
+	exact := New(10)
+	for _, k := range []string{"a", "b", "a", "c", "a", "b"} {
+		exact.Add(k, 1)
+	}
+	if got := exact.Top(2); len(got) != 2 || got[0] != (Item{"a", 3, 0}) || got[1] != (Item{"b", 2, 0}) {
+		t.Errorf("Top(2) = %v, want exact counts when not full", got)
+	}
+
+	const capacity = 100
+	stream, freq := zipfStream(1, 100000)
+	s := New(capacity)
+	for _, k := range stream {
+		s.Add(k, 1)
+	}
+	if s.Len() != capacity || s.Total() != len(stream) {
+		t.Errorf("Len() = %v, Total() = %v", s.Len(), s.Total())
+	}
+	checkBounds(t, "Add", s, freq)
+	for k, f := range freq {
+		if f > len(stream)/capacity {
+			if _, ok := s.entries[k]; !ok {
+				t.Errorf("heavy hitter %v (frequency %v) is missing", k, f)
+			}
+		}
+	}
+
+	// 分成几段分别计数，再合并
+	merged := New(capacity)
+	for i := 0; i < len(stream); i += 30000 {
+		end := i + 30000
+		if end > len(stream) {
+			end = len(stream)
+		}
+		part := New(capacity)
+		for _, k := range stream[i:end] {
+			part.Add(k, 1)
+		}
+		merged.Merge(part)
+	}
+	if merged.Total() != len(stream) {
+		t.Errorf("merged Total() = %v, want %v", merged.Total(), len(stream))
+	}
+	checkBounds(t, "Merge", merged, freq)
+	if top := merged.Top(1); top[0].Key != s.Top(1)[0].Key {
+		t.Errorf("merged Top(1) = %v, want %v", top, s.Top(1))
+	}
+}
@@ -0,0 +1,332 @@
+// Copyright (c) 2020 CDFMLR. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at  http://www.apache.org/licenses/LICENSE-2.0
+
+// websocket 包是服务端 WebSocket (RFC 6455) 的最小实现：
+// 握手、文本/二进制消息(含分片)、ping/pong 与关闭，不支持扩展(如 permessage-deflate)与子协议。
+//
+// Example:
+//		func handler(w http.ResponseWriter, r *http.Request) {
+//			conn, err := websocket.Upgrade(w, r)
+//			if err != nil {
+//				return	// Upgrade 已经回复了错误
+//			}
+//			defer conn.Close()
+//			for {
+//				op, msg, err := conn.ReadMessage()
+//				if err != nil {
+//					return
+//				}
+//				_ = conn.WriteMessage(op, msg)	// echo
+//			}
+//		}
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Opcodes
+const (
+	OpContinuation = 0x0
+	OpText         = 0x1
+	OpBinary       = 0x2
+	OpClose        = 0x8
+	OpPing         = 0x9
+	OpPong         = 0xA
+)
+
+// Close status codes
+const (
+	CloseNormal          = 1000
+	CloseProtocolError   = 1002
+	CloseInvalidData     = 1007
+	CloseMessageTooBig   = 1009
+	closeNoStatusPresent = 1005
+)
+
+// DefaultMaxMessageSize 是 Conn.MaxMessageSize 的默认值
+const DefaultMaxMessageSize = 16 << 20
+
+// acceptGUID 是计算 Sec-WebSocket-Accept 时拼接在 key 之后的固定字符串
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+var (
+	ErrClosed            = errors.New("websocket: connection closed")
+	ErrProtocol          = errors.New("websocket: protocol error")
+	ErrMessageTooBig     = errors.New("websocket: message too big")
+	ErrInvalidUTF8       = errors.New("websocket: invalid UTF-8 in text message")
+	errBadHandshake      = errors.New("websocket: bad handshake")
+	errHijackUnsupported = errors.New("websocket: response does not support hijacking")
+)
+
+// CloseError 是对方发来关闭帧时 ReadMessage 返回的错误
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: closed by peer: %v %v", e.Code, e.Reason)
+}
+
+// Conn 是一个 WebSocket 连接。ReadMessage 只能在一个 goroutine 中调用，
+// WriteMessage、WriteJSON、Close 可以在多个 goroutine 中同时调用
+type Conn struct {
+	MaxMessageSize int64 // 单个消息(合并分片后)的最大字节数，超过时关闭连接，<= 0 时不限
+
+	conn net.Conn
+	br   *bufio.Reader
+
+	wmux   sync.Mutex
+	closed bool // 已发送关闭帧
+}
+
+// Upgrade 完成 WebSocket 握手，把 HTTP 连接升级为 WebSocket 连接。
+// 握手失败时已经以 400 等状态码回复了请求，调用者只需返回
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	if r.Method != "GET" ||
+		!headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "websocket: expected an upgrade request", http.StatusBadRequest)
+		return nil, errBadHandshake
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "websocket: unsupported version", http.StatusUpgradeRequired)
+		return nil, errBadHandshake
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		http.Error(w, "websocket: bad Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, errBadHandshake
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, errHijackUnsupported.Error(), http.StatusInternalServerError)
+		return nil, errHijackUnsupported
+	}
+	conn, brw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	resp := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + AcceptKey(key) + "\r\n\r\n"
+	if _, err := conn.Write([]byte(resp)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &Conn{MaxMessageSize: DefaultMaxMessageSize, conn: conn, br: brw.Reader}, nil
+}
+
+// AcceptKey 返回握手请求的 Sec-WebSocket-Key 为 key 时，响应的 Sec-WebSocket-Accept
+func AcceptKey(key string) string {
+	h := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// headerContains 判断请求头 name 的逗号分隔的值中是否有 token (不区分大小写)
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h[http.CanonicalHeaderKey(name)] {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ReadMessage 读取下一个文本(OpText)或二进制(OpBinary)消息，合并分片。
+// 期间收到的 ping 自动回复 pong；收到关闭帧时回复关闭帧，返回 *CloseError
+func (c *Conn) ReadMessage() (op int, data []byte, err error) {
+	op = -1
+	for {
+		fin, frameOp, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		switch frameOp {
+		case OpPing:
+			if err := c.writeFrame(OpPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case OpPong:
+			continue
+		case OpClose:
+			return 0, nil, c.handleClose(payload)
+		case OpText, OpBinary:
+			if op != -1 {
+				return 0, nil, c.fail(CloseProtocolError, ErrProtocol) // 上一个消息的分片未结束
+			}
+			op = frameOp
+		case OpContinuation:
+			if op == -1 {
+				return 0, nil, c.fail(CloseProtocolError, ErrProtocol)
+			}
+		default:
+			return 0, nil, c.fail(CloseProtocolError, ErrProtocol)
+		}
+
+		data = append(data, payload...)
+		if c.MaxMessageSize > 0 && int64(len(data)) > c.MaxMessageSize {
+			return 0, nil, c.fail(CloseMessageTooBig, ErrMessageTooBig)
+		}
+		if fin {
+			if op == OpText && !utf8.Valid(data) {
+				return 0, nil, c.fail(CloseInvalidData, ErrInvalidUTF8)
+			}
+			return op, data, nil
+		}
+	}
+}
+
+// readFrame 读取一帧，返回去掉掩码后的负载。客户端发来的帧必须带掩码
+func (c *Conn) readFrame() (fin bool, op int, payload []byte, err error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.br, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin = head[0]&0x80 != 0
+	op = int(head[0] & 0x0F)
+	masked := head[1]&0x80 != 0
+	if head[0]&0x70 != 0 || !masked { // 没有协商扩展，RSV 必须为 0
+		return false, 0, nil, c.fail(CloseProtocolError, ErrProtocol)
+	}
+
+	n := int64(head[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = int64(binary.BigEndian.Uint64(ext[:]))
+		if n < 0 {
+			return false, 0, nil, c.fail(CloseProtocolError, ErrProtocol)
+		}
+	}
+	if op >= OpClose && (n > 125 || !fin) { // 控制帧不能分片，负载不超过 125 字节
+		return false, 0, nil, c.fail(CloseProtocolError, ErrProtocol)
+	}
+	if c.MaxMessageSize > 0 && n > c.MaxMessageSize {
+		return false, 0, nil, c.fail(CloseMessageTooBig, ErrMessageTooBig)
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, op, payload, nil
+}
+
+// handleClose 回复对方的关闭帧并关闭连接，返回 *CloseError
+func (c *Conn) handleClose(payload []byte) error {
+	e := &CloseError{Code: closeNoStatusPresent}
+	if len(payload) >= 2 {
+		e.Code = int(binary.BigEndian.Uint16(payload))
+		e.Reason = string(payload[2:])
+	}
+	_ = c.writeClose(CloseNormal, "")
+	c.conn.Close()
+	return e
+}
+
+// fail 以状态码 code 关闭连接，返回 err
+func (c *Conn) fail(code int, err error) error {
+	_ = c.writeClose(code, err.Error())
+	c.conn.Close()
+	return err
+}
+
+// WriteMessage 发送一个消息，op 为 OpText 或 OpBinary
+func (c *Conn) WriteMessage(op int, data []byte) error {
+	if op != OpText && op != OpBinary {
+		return ErrProtocol
+	}
+	return c.writeFrame(op, data)
+}
+
+// WriteJSON 把 v Marshal 成 JSON，作为文本消息发送
+func (c *Conn) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.WriteMessage(OpText, data)
+}
+
+// writeFrame 发送一个不分片、不带掩码的帧
+func (c *Conn) writeFrame(op int, payload []byte) error {
+	c.wmux.Lock()
+	defer c.wmux.Unlock()
+	if c.closed {
+		return ErrClosed
+	}
+	if op == OpClose {
+		c.closed = true
+	}
+
+	frame := make([]byte, 0, len(payload)+10)
+	frame = append(frame, 0x80|byte(op))
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, byte(n))
+	case n <= 0xFFFF:
+		frame = append(frame, 126, byte(n>>8), byte(n))
+	default:
+		frame = append(frame, 127)
+		var ext [8]byte
+		binary.BigEndian.PutUint64(ext[:], uint64(n))
+		frame = append(frame, ext[:]...)
+	}
+	frame = append(frame, payload...)
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// writeClose 发送状态码为 code 的关闭帧，之后不能再发送消息
+func (c *Conn) writeClose(code int, reason string) error {
+	if len(reason) > 123 {
+		reason = reason[:123]
+	}
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	return c.writeFrame(OpClose, append(payload, reason...))
+}
+
+// Close 发送正常关闭的关闭帧并关闭底层连接
+func (c *Conn) Close() error {
+	_ = c.writeClose(CloseNormal, "")
+	return c.conn.Close()
+}
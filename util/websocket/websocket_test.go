@@ -0,0 +1,217 @@
+// Copyright (c) 2020 CDFMLR. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at  http://www.apache.org/licenses/LICENSE-2.0
+
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// echoServer 把收到的消息原样发回，ReadMessage 的错误发送到 errs
+func echoServer(errs chan<- error) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			op, msg, err := conn.ReadMessage()
+			if err != nil {
+				errs <- err
+				return
+			}
+			_ = conn.WriteMessage(op, msg)
+		}
+	}))
+}
+
+// testClient 是测试用的最小客户端
+type testClient struct {
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+func dial(t *testing.T, server *httptest.Server) *testClient {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := "dGhlIHNhbXBsZSBub25jZQ=="
+	_, _ = conn.Write([]byte("GET / HTTP/1.1\r\nHost: test\r\n" +
+		"Upgrade: websocket\r\nConnection: keep-alive, Upgrade\r\n" +
+		"Sec-WebSocket-Key: " + key + "\r\nSec-WebSocket-Version: 13\r\n\r\n"))
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatal("handshake failed:", resp.Status)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" { // RFC 6455 1.3 的例子
+		t.Fatal("bad Sec-WebSocket-Accept:", got)
+	}
+	return &testClient{conn: conn, br: br}
+}
+
+// writeFrame 发送一个带掩码的帧
+func (c *testClient) writeFrame(fin bool, op int, payload []byte) {
+	head := byte(op)
+	if fin {
+		head |= 0x80
+	}
+	frame := []byte{head}
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, 0x80|byte(n))
+	case n <= 0xFFFF:
+		frame = append(frame, 0x80|126, byte(n>>8), byte(n))
+	default:
+		var ext [8]byte
+		binary.BigEndian.PutUint64(ext[:], uint64(n))
+		frame = append(append(frame, 0x80|127), ext[:]...)
+	}
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	_, _ = c.conn.Write(frame)
+}
+
+// readFrame 读取服务端发来的一帧
+func (c *testClient) readFrame(t *testing.T) (op int, payload []byte) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.br, head[:]); err != nil {
+		t.Fatal(err)
+	}
+	if head[1]&0x80 != 0 {
+		t.Fatal("server frame should not be masked")
+	}
+	n := int(head[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		_, _ = io.ReadFull(c.br, ext[:])
+		n = int(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		_, _ = io.ReadFull(c.br, ext[:])
+		n = int(binary.BigEndian.Uint64(ext[:]))
+	}
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		t.Fatal(err)
+	}
+	return int(head[0] & 0x0F), payload
+}
+
+func TestEcho(t *testing.T) {
+	errs := make(chan error, 1)
+	server := echoServer(errs)
+	defer server.Close()
+	c := dial(t, server)
+	defer c.conn.Close()
+
+	tests := []struct {
+		name string
+		op   int
+		data []byte
+	}{
+		{"text", OpText, []byte("你好, websocket")},
+		{"binary", OpBinary, []byte{0, 1, 2, 255}},
+		{"empty", OpText, []byte{}},
+		{"len16", OpText, bytes.Repeat([]byte("a"), 300)},
+		{"len64", OpBinary, bytes.Repeat([]byte("b"), 70000)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.writeFrame(true, tt.op, tt.data)
+			op, got := c.readFrame(t)
+			if op != tt.op || !bytes.Equal(got, tt.data) {
+				t.Errorf("got op=%v len=%v, want op=%v len=%v", op, len(got), tt.op, len(tt.data))
+			}
+		})
+	}
+
+	// 分片的消息，中间夹着 ping
+	c.writeFrame(false, OpText, []byte("frag"))
+	c.writeFrame(true, OpPing, []byte("ping"))
+	c.writeFrame(false, OpContinuation, []byte("men"))
+	c.writeFrame(true, OpContinuation, []byte("ted"))
+	if op, got := c.readFrame(t); op != OpPong || string(got) != "ping" {
+		t.Errorf("want pong, got op=%v %q", op, got)
+	}
+	if op, got := c.readFrame(t); op != OpText || string(got) != "fragmented" {
+		t.Errorf("want fragmented, got op=%v %q", op, got)
+	}
+
+	// 关闭
+	c.writeFrame(true, OpClose, []byte{0x03, 0xE8})
+	if op, got := c.readFrame(t); op != OpClose || binary.BigEndian.Uint16(got) != CloseNormal {
+		t.Errorf("want close 1000, got op=%v %v", op, got)
+	}
+	if ce, ok := (<-errs).(*CloseError); !ok || ce.Code != CloseNormal {
+		t.Errorf("want CloseError 1000, got %v", ce)
+	}
+}
+
+func TestProtocolError(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(c *testClient)
+		code  uint16
+		err   error
+	}{
+		{"continuation first", func(c *testClient) { c.writeFrame(true, OpContinuation, []byte("x")) },
+			CloseProtocolError, ErrProtocol},
+		{"invalid utf8", func(c *testClient) { c.writeFrame(true, OpText, []byte{0xff, 0xfe}) },
+			CloseInvalidData, ErrInvalidUTF8},
+		{"fragmented ping", func(c *testClient) { c.writeFrame(false, OpPing, nil) },
+			CloseProtocolError, ErrProtocol},
+		{"unmasked", func(c *testClient) { _, _ = c.conn.Write([]byte{0x81, 0x01, 'x'}) },
+			CloseProtocolError, ErrProtocol},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := make(chan error, 1)
+			server := echoServer(errs)
+			defer server.Close()
+			c := dial(t, server)
+			defer c.conn.Close()
+
+			tt.write(c)
+			if op, got := c.readFrame(t); op != OpClose || binary.BigEndian.Uint16(got) != tt.code {
+				t.Errorf("want close %v, got op=%v %q", tt.code, op, got)
+			}
+			if err := <-errs; err != tt.err {
+				t.Errorf("want %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestUpgradeBadRequest(t *testing.T) {
+	server := echoServer(make(chan error, 1))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("want 400, got %v", resp.Status)
+	}
+}
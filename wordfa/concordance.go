@@ -0,0 +1,212 @@
+// Copyright (c) 2020 CDFMLR. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at  http://www.apache.org/licenses/LICENSE-2.0
+
+package wordfa
+
+import (
+	"CiFa/util/strsearch"
+	"bufio"
+	"context"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultContextRunes 是 ConcordanceOptions.Context 的默认值
+const DefaultContextRunes = 20
+
+// regexpSnippetRunes 是 LibRegexp 的关键词在片段中至多显示的 rune 数
+const regexpSnippetRunes = 64
+
+// ConcordanceOptions 控制 KWIC (keyword in context，关键词及其上下文) 片段的收集
+type ConcordanceOptions struct {
+	Snippets int // 每个关键词至多收集的片段数，<= 0 时不收集
+	Context  int // 片段中关键词左、右各保留的 rune 数，<= 0 时使用 DefaultContextRunes
+}
+
+// Snippet 是关键词的一次出现及其上下文
+type Snippet struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`    // 行号，从 1 开始
+	Column  int    `json:"column"`  // 列号(以 rune 计)，从 1 开始
+	Left    string `json:"left"`    // 左侧的上下文
+	Keyword string `json:"keyword"` // 原文中匹配的文本，使用 MatchOptions 或 LibRegexp 时可能与关键词不同
+	Right   string `json:"right"`   // 右侧的上下文
+}
+
+// snippetRequest 是要截取的一个片段: 关键词 pattern 在 offset (字节) 处的匹配
+type snippetRequest struct {
+	offset  int
+	pattern string
+}
+
+// collectSnippets 截取 file 中各关键词前 Concordance.Snippets 个匹配(found 是 matchFile 的结果)的片段
+func (t *Task) collectSnippets(ctx context.Context, file string, found map[string][]int) (map[string][]Snippet, error) {
+	var reqs []snippetRequest
+	for pattern, indices := range found {
+		if len(indices) > t.Concordance.Snippets {
+			indices = indices[:t.Concordance.Snippets]
+		}
+		for _, i := range indices {
+			reqs = append(reqs, snippetRequest{offset: i, pattern: pattern})
+		}
+	}
+	if len(reqs) == 0 {
+		return nil, nil
+	}
+	sort.Slice(reqs, func(i, j int) bool { return reqs[i].offset < reqs[j].offset })
+
+	f, err := t.openFile(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	width := t.Concordance.Context
+	if width <= 0 {
+		width = DefaultContextRunes
+	}
+	return readSnippets(f, file, reqs, width, t.keywordLength)
+}
+
+// keywordLength 返回 pattern 的匹配至多有多少个 rune，
+// 以及在以匹配起点开头的文本 tail 中，匹配的长度(字节)
+func (t *Task) keywordLength(pattern string) (maxRunes int, length func(tail string) int) {
+	if t.StrSearchAlgorithm == strsearch.LibRegexp {
+		re := t.keywordRes[pattern] // 在 prepare 中编译
+		return regexpSnippetRunes, func(tail string) int {
+			if re == nil {
+				return 0
+			}
+			if loc := re.FindStringIndex(tail); loc != nil {
+				return loc[1]
+			}
+			return 0
+		}
+	}
+	// MatchOptions 的规范化逐 rune 进行，匹配与 pattern 的 rune 数相同
+	n := utf8.RuneCountInString(pattern)
+	return n, func(tail string) int {
+		return len(firstRunes(tail, n))
+	}
+}
+
+// pendingSnippet 是已经读到起点、还在读取关键词与右侧上下文的片段
+type pendingSnippet struct {
+	pattern string
+	snippet Snippet
+	tail    []rune // 从匹配起点开始读到的文本
+	need    int    // 需要读取的 rune 数
+	length  func(tail string) int
+}
+
+// readSnippets 流式地读取 r，按 reqs (按 offset 升序) 截取片段，读完最后一个片段即停止
+func readSnippets(r io.Reader, file string, reqs []snippetRequest, width int,
+	keywordLength func(pattern string) (int, func(string) int)) (map[string][]Snippet, error) {
+
+	res := map[string][]Snippet{}
+	finish := func(p *pendingSnippet) {
+		tail := string(p.tail)
+		n := p.length(tail)
+		p.snippet.Keyword = oneLine(tail[:n])
+		p.snippet.Right = oneLine(firstRunes(tail[n:], width))
+		res[p.pattern] = append(res[p.pattern], p.snippet)
+	}
+
+	br := bufio.NewReader(r)
+	left := make([]rune, 0, width) // 最近读到的 width 个 rune
+	offset, line, column := 0, 1, 1
+	var pending []*pendingSnippet
+	for next := 0; next < len(reqs) || len(pending) > 0; {
+		for ; next < len(reqs) && reqs[next].offset <= offset; next++ {
+			if reqs[next].offset < offset { // 不在 rune 的边界上
+				continue
+			}
+			maxRunes, length := keywordLength(reqs[next].pattern)
+			pending = append(pending, &pendingSnippet{
+				pattern: reqs[next].pattern,
+				snippet: Snippet{File: file, Line: line, Column: column, Left: oneLine(string(left))},
+				need:    maxRunes + width,
+				length:  length,
+			})
+		}
+
+		c, size, err := br.ReadRune()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return res, err
+		}
+
+		remain := pending[:0]
+		for _, p := range pending {
+			p.tail = append(p.tail, c)
+			if len(p.tail) < p.need {
+				remain = append(remain, p)
+			} else {
+				finish(p)
+			}
+		}
+		pending = remain
+
+		if width > 0 {
+			if len(left) == width {
+				left = append(left[:0], left[1:]...)
+			}
+			left = append(left, c)
+		}
+		offset += size
+		if c == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+	for _, p := range pending {
+		finish(p)
+	}
+	return res, nil
+}
+
+// firstRunes 返回 s 的前 n 个 rune
+func firstRunes(s string, n int) string {
+	i := 0
+	for k := 0; k < n && i < len(s); k++ {
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+	}
+	return s[:i]
+}
+
+// oneLine 把片段中的换行、制表符替换为空格，使片段可以在一行中显示
+func oneLine(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' || r == '\t' {
+			return ' '
+		}
+		return r
+	}, s)
+}
+
+// sortSnippets 把片段按文件、位置排序，并截断到至多 n 个
+func sortSnippets(snippets []Snippet, n int) []Snippet {
+	sort.Slice(snippets, func(i, j int) bool {
+		a, b := snippets[i], snippets[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	if len(snippets) > n {
+		snippets = snippets[:n]
+	}
+	return snippets
+}
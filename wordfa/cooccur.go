@@ -0,0 +1,335 @@
+// Copyright (c) 2020 CDFMLR. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at  http://www.apache.org/licenses/LICENSE-2.0
+
+package wordfa
+
+import (
+	"bufio"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Co-occurrence scopes
+const (
+	CooccurNone      = iota // 不统计共现
+	CooccurSentence         // 同一句中共现，句子以 。！？；!?; 及换行分隔，英文句点后需跟空白
+	CooccurParagraph        // 同一段中共现，段落以换行分隔
+	CooccurWindow           // 相距不超过 CooccurrenceOptions.Window 个字时共现
+	_nothingCooccur
+)
+
+var CooccurrenceScopesMap = map[string]int{
+	"Sentence":  CooccurSentence,
+	"Paragraph": CooccurParagraph,
+	"Window":    CooccurWindow,
+}
+
+// ValidCooccurrenceScope 判断 scope 是否是合法的共现范围枚举值
+func ValidCooccurrenceScope(scope int) bool {
+	return scope >= 0 && scope < _nothingCooccur
+}
+
+// DefaultCooccurWindow 是 CooccurrenceOptions.Window 的默认值
+const DefaultCooccurWindow = 20
+
+// CooccurrenceOptions 控制关键词共现的统计，结果见 Task.GetCooccurrence
+//
+// 共现只在子串匹配模式中统计(需要匹配的位置)，词汇发现、分词匹配与 n-gram 模式中不起作用，
+// 统计共现时不使用 Index
+type CooccurrenceOptions struct {
+	Scope  int // 共现的范围，见 CooccurrenceScopesMap，默认不统计
+	Window int // Scope 为 CooccurWindow 时，两个关键词的起点至多相距这么多个字，<= 0 时使用 DefaultCooccurWindow
+}
+
+func (o CooccurrenceOptions) window() int {
+	if o.Window <= 0 {
+		return DefaultCooccurWindow
+	}
+	return o.Window
+}
+
+// cooccurring 判断 Task 是否统计共现
+func (t *Task) cooccurring() bool {
+	return t.Cooccurrence.Scope != CooccurNone && !t.segmenting() && !t.ngramming()
+}
+
+// cooccurPair 是一对关键词，a < b
+type cooccurPair struct {
+	a, b string
+}
+
+func newCooccurPair(x, y string) cooccurPair {
+	if x > y {
+		x, y = y, x
+	}
+	return cooccurPair{a: x, b: y}
+}
+
+// cooccurCounts 是单个文件中的共现统计
+type cooccurCounts struct {
+	pairs map[cooccurPair]int // 各对关键词的共现次数
+	freq  map[string]int      // 各关键词出现在多少个句子/段落中，窗口模式中是出现次数
+	units int                 // 非空的句子/段落数，窗口模式中是总字数
+}
+
+// occurrence 是一次匹配: 关键词与其在文件中的位置
+type occurrence struct {
+	offset  int // 字节
+	pattern string
+	unit    int // 所在的句子/段落的序号
+	rune    int // 在文件中的第几个字
+}
+
+// cooccurFile 根据单个文件中各关键词匹配的位置 found (字节索引) 统计共现。
+// 文件被流式地读取一遍，以确定每个匹配所在的句子/段落与字位置
+func (t *Task) cooccurFile(ctx context.Context, file string, found map[string][]int) (cooccurCounts, error) {
+	var occs []occurrence
+	for pattern, indices := range found {
+		for _, i := range indices {
+			occs = append(occs, occurrence{offset: i, pattern: pattern})
+		}
+	}
+	sort.Slice(occs, func(i, j int) bool { return occs[i].offset < occs[j].offset })
+
+	f, err := t.openFile(ctx, file)
+	if err != nil {
+		return cooccurCounts{}, err
+	}
+	defer f.Close()
+	units, runes, err := locateOccurrences(bufio.NewReader(f), occs, t.Cooccurrence.Scope)
+	if err != nil {
+		return cooccurCounts{}, err
+	}
+
+	counts := cooccurCounts{pairs: map[cooccurPair]int{}, freq: map[string]int{}}
+	if t.Cooccurrence.Scope == CooccurWindow {
+		counts.units = runes
+		window := t.Cooccurrence.window()
+		for i, x := range occs {
+			counts.freq[x.pattern]++
+			for _, y := range occs[i+1:] {
+				if y.rune-x.rune > window {
+					break
+				}
+				if y.pattern != x.pattern {
+					counts.pairs[newCooccurPair(x.pattern, y.pattern)]++
+				}
+			}
+		}
+		return counts, nil
+	}
+
+	counts.units = units
+	for i := 0; i < len(occs); {
+		// occs[i:j] 在同一个句子/段落中
+		j := i
+		inUnit := map[string]bool{}
+		for ; j < len(occs) && occs[j].unit == occs[i].unit; j++ {
+			inUnit[occs[j].pattern] = true
+		}
+		var patterns []string
+		for p := range inUnit {
+			patterns = append(patterns, p)
+			counts.freq[p]++
+		}
+		for m := range patterns {
+			for n := m + 1; n < len(patterns); n++ {
+				counts.pairs[newCooccurPair(patterns[m], patterns[n])]++
+			}
+		}
+		i = j
+	}
+	return counts, nil
+}
+
+// locateOccurrences 读取 r，为 occs (按 offset 升序) 中的每个匹配填写 unit、rune，
+// 返回非空(含非空白字符)的句子/段落数与总字数
+func locateOccurrences(r *bufio.Reader, occs []occurrence, scope int) (units int, runes int, err error) {
+	offset := 0
+	unit := 0
+	hasText := false // 当前句子/段落中是否有非空白字符
+	next := 0        // occs 中下一个待定位的匹配
+	for {
+		c, size, err := r.ReadRune()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		for ; next < len(occs) && occs[next].offset <= offset; next++ {
+			occs[next].unit = unit
+			occs[next].rune = runes
+		}
+		if !unicode.IsSpace(c) {
+			hasText = true
+		}
+		offset += size
+		runes++
+
+		if isUnitBreak(r, c, scope) {
+			if hasText {
+				units++
+			}
+			hasText = false
+			unit++
+		}
+	}
+	for ; next < len(occs); next++ {
+		occs[next].unit = unit
+		occs[next].rune = runes
+	}
+	if hasText {
+		units++
+	}
+	return units, runes, nil
+}
+
+// isUnitBreak 判断刚读到的字符 c 是否结束了一个句子/段落，r 是 c 之后的文本
+func isUnitBreak(r *bufio.Reader, c rune, scope int) bool {
+	switch scope {
+	case CooccurParagraph:
+		return c == '\n'
+	case CooccurSentence:
+		switch c {
+		case '\n', '。', '！', '？', '；', '!', '?', ';':
+			return true
+		case '.':
+			next, _, err := r.ReadRune()
+			if err != nil {
+				return true
+			}
+			r.UnreadRune()
+			return unicode.IsSpace(next)
+		}
+	}
+	return false
+}
+
+// Cooccurrence 是关键词共现的稀疏矩阵
+type Cooccurrence struct {
+	Scope       string            `json:"scope"`       // 共现的范围，见 CooccurrenceScopesMap
+	Units       int               `json:"units"`       // 非空的句子/段落总数，窗口模式中是总字数
+	Keywords    []string          `json:"keywords"`    // 与 GetResult 结果的顺序相同
+	Frequencies []int             `json:"frequencies"` // Frequencies[i] 是含 Keywords[i] 的句子/段落数，窗口模式中是 Keywords[i] 的出现次数
+	Pairs       []CooccurPairItem `json:"pairs"`       // 共现过的关键词对，按共现次数从大到小排序
+}
+
+// CooccurPairItem 是 Cooccurrence 中的一个非零项
+type CooccurPairItem struct {
+	I     int     `json:"i"`     // Keywords 中的下标，I < J
+	J     int     `json:"j"`     // Keywords 中的下标
+	Count int     `json:"count"` // 共现的句子/段落数，窗口模式中是相距不超过窗口的匹配对数
+	PMI   float64 `json:"pmi"`   // 点互信息 log2(实际共现次数 / 两词独立时的期望共现次数)
+}
+
+// GetCooccurrence 返回 Task 的关键词共现矩阵，Task 未完成时返回 (nil, false)，不统计共现时返回 (nil, true)
+//
+// 句子/段落模式中，期望共现次数是 Frequencies[i] * Frequencies[j] / Units；
+// 窗口模式中每次匹配的前后各 Window 个字内，另一个词的期望出现次数是 2 * Window * Frequencies[j] / Units
+func (t *Task) GetCooccurrence(sortAlgorithm int) (c *Cooccurrence, ok bool) {
+	result, ok := t.GetResult(sortAlgorithm)
+	if !ok || !t.cooccurring() {
+		return nil, ok
+	}
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	c = &Cooccurrence{Units: t.cooccurUnits, Pairs: []CooccurPairItem{}}
+	for name, scope := range CooccurrenceScopesMap {
+		if scope == t.Cooccurrence.Scope {
+			c.Scope = name
+		}
+	}
+	index := map[string]int{}
+	for i, item := range result {
+		index[item.Keyword] = i
+		c.Keywords = append(c.Keywords, item.Keyword)
+		c.Frequencies = append(c.Frequencies, t.cooccurFreq[item.Keyword])
+	}
+	for pair, n := range t.cooccurPairs {
+		i, j := index[pair.a], index[pair.b]
+		if i > j {
+			i, j = j, i
+		}
+		expected := float64(c.Frequencies[i]) * float64(c.Frequencies[j]) / float64(c.Units)
+		if t.Cooccurrence.Scope == CooccurWindow {
+			expected *= float64(2 * t.Cooccurrence.window())
+		}
+		c.Pairs = append(c.Pairs, CooccurPairItem{I: i, J: j, Count: n, PMI: math.Log2(float64(n) / expected)})
+	}
+	sort.Slice(c.Pairs, func(m, n int) bool {
+		p, q := c.Pairs[m], c.Pairs[n]
+		if p.Count != q.Count {
+			return p.Count > q.Count
+		}
+		if p.I != q.I {
+			return p.I < q.I
+		}
+		return p.J < q.J
+	})
+	return c, true
+}
+
+// WriteGraphML 把共现矩阵以 GraphML 格式写入 w：关键词是节点，共现的关键词对是无向边
+func (c *Cooccurrence) WriteGraphML(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprint(bw, xml.Header)
+	fmt.Fprintln(bw, `<graphml xmlns="http://graphml.graphdrawing.org/xmlns">`)
+	fmt.Fprintln(bw, `  <key id="label" for="node" attr.name="label" attr.type="string"/>`)
+	fmt.Fprintln(bw, `  <key id="frequency" for="node" attr.name="frequency" attr.type="int"/>`)
+	fmt.Fprintln(bw, `  <key id="weight" for="edge" attr.name="weight" attr.type="int"/>`)
+	fmt.Fprintln(bw, `  <key id="pmi" for="edge" attr.name="pmi" attr.type="double"/>`)
+	fmt.Fprintln(bw, `  <graph id="cooccurrence" edgedefault="undirected">`)
+	for i, k := range c.Keywords {
+		fmt.Fprintf(bw, "    <node id=\"n%d\"><data key=\"label\">%s</data><data key=\"frequency\">%d</data></node>\n",
+			i, escapeXML(k), c.Frequencies[i])
+	}
+	for e, p := range c.Pairs {
+		fmt.Fprintf(bw, "    <edge id=\"e%d\" source=\"n%d\" target=\"n%d\"><data key=\"weight\">%d</data><data key=\"pmi\">%v</data></edge>\n",
+			e, p.I, p.J, p.Count, p.PMI)
+	}
+	fmt.Fprintln(bw, `  </graph>`)
+	fmt.Fprintln(bw, `</graphml>`)
+	return bw.Flush()
+}
+
+// WriteGEXF 把共现矩阵以 GEXF 1.2 格式写入 w：关键词是节点，共现的关键词对是无向边，边的权重是共现次数
+func (c *Cooccurrence) WriteGEXF(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprint(bw, xml.Header)
+	fmt.Fprintln(bw, `<gexf xmlns="http://www.gexf.net/1.2draft" version="1.2">`)
+	fmt.Fprintln(bw, `  <graph mode="static" defaultedgetype="undirected">`)
+	fmt.Fprintln(bw, `    <attributes class="node"><attribute id="0" title="frequency" type="integer"/></attributes>`)
+	fmt.Fprintln(bw, `    <attributes class="edge"><attribute id="0" title="pmi" type="double"/></attributes>`)
+	fmt.Fprintln(bw, `    <nodes>`)
+	for i, k := range c.Keywords {
+		fmt.Fprintf(bw, "      <node id=\"%d\" label=\"%s\"><attvalues><attvalue for=\"0\" value=\"%d\"/></attvalues></node>\n",
+			i, escapeXML(k), c.Frequencies[i])
+	}
+	fmt.Fprintln(bw, `    </nodes>`)
+	fmt.Fprintln(bw, `    <edges>`)
+	for e, p := range c.Pairs {
+		fmt.Fprintf(bw, "      <edge id=\"%d\" source=\"%d\" target=\"%d\" weight=\"%d\"><attvalues><attvalue for=\"0\" value=\"%v\"/></attvalues></edge>\n",
+			e, p.I, p.J, p.Count, p.PMI)
+	}
+	fmt.Fprintln(bw, `    </edges>`)
+	fmt.Fprintln(bw, `  </graph>`)
+	fmt.Fprintln(bw, `</gexf>`)
+	return bw.Flush()
+}
+
+// escapeXML 转义 s 中 XML 的特殊字符，用于元素内容与属性值
+func escapeXML(s string) string {
+	var sb strings.Builder
+	xml.EscapeText(&sb, []byte(s))
+	return sb.String()
+}
@@ -0,0 +1,203 @@
+// Copyright (c) 2020 CDFMLR. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at  http://www.apache.org/licenses/LICENSE-2.0
+
+package wordfa
+
+import (
+	"CiFa/util/sortalgo"
+	"context"
+	"math"
+)
+
+// RatePer 是 DiffItem 中归一化频率的单位: 每 RatePer 词中出现的次数
+const RatePer = 10000
+
+// DiffTask 是"比较关键词 Patterns 在两组文件 (语料 A、B) 中的频数"的任务
+//
+// DiffTask 内嵌的 Task 统计语料 A (Task.SrcFiles)，语料 B (SrcFilesB) 以与之相同的匹配选项统计，
+// 两者同时进行。为了计算归一化的频率，两组文件都会用 Task.Segmenter 分词统计词数。
+// 比较任务不统计近似匹配、KWIC、n-gram，也不评分。
+// 调用 Run() 开始任务，GetStatus() 或 GetProgress() 获取进度，完成后调用 GetResult() 得到结果。
+type DiffTask struct {
+	Task               // 统计语料 A 的任务，其选项同样用于语料 B
+	SrcFilesB []string // 语料 B 的文件
+
+	b *Task // 统计语料 B 的任务，Run 时创建
+}
+
+func NewDiffTask(srcFilesA []string, srcFilesB []string, patterns []string) *DiffTask {
+	return &DiffTask{Task: Task{SrcFiles: srcFilesA, Patterns: patterns}, SrcFilesB: srcFilesB}
+}
+
+// Run 同时统计语料 A、B，阻塞直到两者都完成或被 Stop
+func (d *DiffTask) Run() {
+	d.RunContext(context.Background())
+}
+
+// RunContext 同 Run，ctx 取消或超时后两组语料的统计都中途停止，见 Task.RunContext。
+// 任意一组被取消时，另一组也随之停止
+func (d *DiffTask) RunContext(ctx context.Context) error {
+	d.mux.Lock()
+	d.withWords = true
+	d.b = d.Task.sibling(d.SrcFilesB)
+	b := d.b
+	d.mux.Unlock()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errB := make(chan error, 1)
+	go func() {
+		err := b.RunContext(ctx)
+		if err != nil {
+			cancel()
+		}
+		errB <- err
+	}()
+	err := d.Task.RunContext(ctx)
+	if err != nil {
+		cancel()
+	}
+	if e := <-errB; err == nil {
+		err = e
+	}
+	return err
+}
+
+// Stop 停止正在运行的 DiffTask
+func (d *DiffTask) Stop() {
+	d.Task.Stop()
+	if b := d.taskB(); b != nil {
+		b.Stop()
+	}
+}
+
+// taskB 返回统计语料 B 的任务，Run 之前为 nil
+func (d *DiffTask) taskB() *Task {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	return d.b
+}
+
+// GetStatus 返回两组语料合计的进度，见 Task.GetStatus
+func (d *DiffTask) GetStatus() Progress {
+	a := d.Task.GetStatus()
+	b := d.taskB()
+	if b == nil {
+		return a
+	}
+	return a.merge(b.GetStatus())
+}
+
+// GetProgress 返回两组语料合计已完成的比例，取值的含义同 Task.GetProgress
+func (d *DiffTask) GetProgress() float32 {
+	return float32(d.GetStatus().Fraction())
+}
+
+// GetErrors 返回处理两组语料时出错的文件及错误信息(按文件排序)，见 Task.GetErrors
+func (d *DiffTask) GetErrors() []FileError {
+	errors := map[string]string{}
+	for _, e := range d.Task.GetErrors() {
+		errors[e.File] = e.Error
+	}
+	if b := d.taskB(); b != nil {
+		for _, e := range b.GetErrors() {
+			errors[e.File] = e.Error
+		}
+	}
+	return sortErrors(errors)
+}
+
+// Failed 判断两组语料中是否有文件处理出错
+func (d *DiffTask) Failed() bool {
+	return len(d.GetErrors()) > 0
+}
+
+// GetResult 返回比较的结果与 ok=true，任务未完成时返回 (nil, false)。
+// 结果按每万词频率的变化量的绝对值从大到小排序
+func (d *DiffTask) GetResult(sortAlgorithm int) (result DiffResult, ok bool) {
+	if b := d.taskB(); b == nil || !d.Task.finished() || !b.finished() {
+		return nil, false
+	}
+	a, b := d.Task.counts(), d.b.counts()
+	for k := range b.matches {
+		if _, ok := a.matches[k]; !ok {
+			a.matches[k] = 0
+		}
+	}
+	for k, fa := range a.matches {
+		fb := b.matches[k]
+		item := DiffItem{
+			Keyword:    k,
+			FrequencyA: fa,
+			FrequencyB: fb,
+			Change:     fb - fa,
+			RateA:      rate(fa, a.words),
+			RateB:      rate(fb, b.words),
+		}
+		if fa > 0 {
+			item.RelativeChange = float64(fb-fa) / float64(fa)
+		}
+		result = append(result, item)
+	}
+	if d.SortFuncName != "" {
+		sortAlgorithm = sortalgo.SortAlgorithmsMap[d.SortFuncName]
+	}
+	sortalgo.By(sortAlgorithm).Sort(result)
+	return result, true
+}
+
+// corpusCounts 是一组语料中各词的频数与总词数
+type corpusCounts struct {
+	matches map[string]int
+	words   int
+}
+
+// counts 返回 t 中各词频数的副本与总词数
+func (t *Task) counts() corpusCounts {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	c := corpusCounts{matches: map[string]int{}}
+	for k, n := range t.matches {
+		c.matches[k] = n
+	}
+	for _, n := range t.words {
+		c.words += n
+	}
+	return c
+}
+
+// rate 返回共 words 词的语料中出现 n 次的词的每万词频率，words 为 0 时返回 0
+func rate(n, words int) float64 {
+	if words == 0 {
+		return 0
+	}
+	return float64(n) * RatePer / float64(words)
+}
+
+// DiffResult 是 DiffTask 的结果，按每万词频率的变化量的绝对值从大到小排序
+type DiffResult []DiffItem
+
+// DiffItem 是 DiffResult 切片中的数据条目
+type DiffItem struct {
+	Keyword        string  `json:"keyword"`
+	FrequencyA     int     `json:"frequency_a"`     // 在语料 A 中的频数
+	FrequencyB     int     `json:"frequency_b"`     // 在语料 B 中的频数
+	Change         int     `json:"change"`          // 频数的变化量 FrequencyB - FrequencyA
+	RelativeChange float64 `json:"relative_change"` // 频数的相对变化 Change / FrequencyA，FrequencyA 为 0 时为 0
+	RateA          float64 `json:"rate_a"`          // 在语料 A 中的每万词频率
+	RateB          float64 `json:"rate_b"`          // 在语料 B 中的每万词频率
+}
+
+func (r DiffResult) Len() int {
+	return len(r)
+}
+
+func (r DiffResult) Less(i, j int) bool {
+	return math.Abs(r[i].RateB-r[i].RateA) > math.Abs(r[j].RateB-r[j].RateA)
+}
+
+func (r DiffResult) Swap(i, j int) {
+	r[i], r[j] = r[j], r[i]
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2020 CDFMLR. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at  http://www.apache.org/licenses/LICENSE-2.0
+
+package wordfa
+
+import (
+	"time"
+)
+
+// Event types
+const (
+	EventProgress = "progress" // 运行中每隔 ProgressInterval 报告一次进度
+	EventFile     = "file"     // 一个文件处理完成或出错(Error 不为空)，使用 Index 时不逐个报告
+	EventEnd      = "end"      // 任务结束: 完成、有文件出错或被取消(Error 为取消的原因)
+)
+
+// ProgressInterval 是运行中报告 EventProgress 的间隔
+var ProgressInterval = 200 * time.Millisecond
+
+// Event 是 Task 运行中产生的事件，见 Task.Subscribe
+type Event struct {
+	Type   string   `json:"type"`            // 事件类型，见 EventProgress、EventFile、EventEnd
+	File   string   `json:"file,omitempty"`  // EventFile 的文件
+	Error  string   `json:"error,omitempty"` // EventFile 中文件的错误，或 EventEnd 中任务被取消的原因
+	Status Progress `json:"status"`          // 产生事件时的进度
+}
+
+// Observer 接收 Task 的事件，见 Task.Subscribe
+type Observer func(e Event)
+
+// Subscribe 注册观察者 o，返回取消注册的函数。
+// o 在产生事件的 goroutine (如处理文件的 worker) 中被同步调用，应尽快返回，不能阻塞
+func (t *Task) Subscribe(o Observer) (unsubscribe func()) {
+	t.omux.Lock()
+	defer t.omux.Unlock()
+
+	if t.observers == nil {
+		t.observers = map[int]Observer{}
+	}
+	t.nextObserver++
+	id := t.nextObserver
+	t.observers[id] = o
+	return func() {
+		t.omux.Lock()
+		defer t.omux.Unlock()
+		delete(t.observers, id)
+	}
+}
+
+// notify 把事件发送给所有观察者，没有观察者时什么都不做。调用者不能持有 t.mux
+func (t *Task) notify(typ string, file string, err error) {
+	t.omux.Lock()
+	observers := make([]Observer, 0, len(t.observers))
+	for _, o := range t.observers {
+		observers = append(observers, o)
+	}
+	t.omux.Unlock()
+	if len(observers) == 0 {
+		return
+	}
+
+	e := Event{Type: typ, File: file, Status: t.GetStatus()}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	for _, o := range observers {
+		o(e)
+	}
+}
+
+// reportProgress 每隔 ProgressInterval 发送一次 EventProgress，直到 stop 被关闭
+func (t *Task) reportProgress(stop <-chan struct{}) {
+	ticker := time.NewTicker(ProgressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			t.notify(EventProgress, "", nil)
+		case <-stop:
+			return
+		}
+	}
+}
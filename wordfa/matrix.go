@@ -0,0 +1,89 @@
+// Copyright (c) 2020 CDFMLR. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at  http://www.apache.org/licenses/LICENSE-2.0
+
+package wordfa
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+)
+
+// ErrNoCounts 表示矩阵没有各词在各文件中的频数(n-gram 模式)，不能写成 CSV
+var ErrNoCounts = errors.New("n-gram results have no per-keyword matrix")
+
+// Matrix 是文档-词矩阵 (document-term matrix)：各词在各文件中的频数
+type Matrix struct {
+	Files    []string `json:"files"`    // 行，按路径排序
+	Keywords []string `json:"keywords"` // 列，与 GetResult 结果的顺序相同
+	Counts   [][]int  `json:"counts"`   // Counts[i][j] 是 Keywords[j] 在 Files[i] 中的频数
+
+	Scores [][]float64 `json:"scores,omitempty"` // 评分方法为 ScoreTfIdf 时，Scores[i][j] 是 Keywords[j] 在 Files[i] 中的 TF-IDF
+}
+
+// GetMatrix 返回 Task 的文档-词矩阵，列是 GetResult(sortAlgorithm) 结果中的词，
+// Task 未完成时返回 (nil, false)。n-gram 模式不统计各文件的频数，Counts 为 nil
+func (t *Task) GetMatrix(sortAlgorithm int) (matrix *Matrix, ok bool) {
+	result, ok := t.GetResult(sortAlgorithm)
+	if !ok {
+		return nil, false
+	}
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	matrix = &Matrix{Keywords: make([]string, len(result))}
+	for j, item := range result {
+		matrix.Keywords[j] = item.Keyword
+	}
+	matrix.Files = t.files()
+	if t.ngramming() {
+		return matrix, true
+	}
+
+	matrix.Counts = make([][]int, len(matrix.Files))
+	for i, file := range matrix.Files {
+		matrix.Counts[i] = make([]int, len(matrix.Keywords))
+		for j, k := range matrix.Keywords {
+			matrix.Counts[i][j] = t.fileMatches[file][k]
+		}
+	}
+	if t.scoring() && t.Score.Method == ScoreTfIdf {
+		matrix.Scores = make([][]float64, len(matrix.Files))
+		for i := range matrix.Scores {
+			matrix.Scores[i] = make([]float64, len(matrix.Keywords))
+		}
+		for j, k := range matrix.Keywords {
+			for i, s := range t.tfidf(k, matrix.Files) {
+				matrix.Scores[i][j] = s
+			}
+		}
+	}
+	return matrix, true
+}
+
+// WriteCSV 把矩阵以 CSV 格式写入 w：第一行是 "file" 与各词，之后每行是一个文件及各词的频数。
+// 没有频数 (Counts 为 nil，n-gram 模式) 时不写入任何内容，返回 ErrNoCounts
+func (m *Matrix) WriteCSV(w io.Writer) error {
+	if m.Counts == nil {
+		return ErrNoCounts
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(append([]string{"file"}, m.Keywords...)); err != nil {
+		return err
+	}
+	for i, file := range m.Files {
+		record := make([]string, 0, len(m.Keywords)+1)
+		record = append(record, file)
+		for _, n := range m.Counts[i] {
+			record = append(record, strconv.Itoa(n))
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2020 CDFMLR. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at  http://www.apache.org/licenses/LICENSE-2.0
+
+package wordfa
+
+import (
+	"CiFa/util/segment"
+	"CiFa/util/topk"
+	"bufio"
+	"context"
+	"io"
+	"strings"
+)
+
+// MaxNGram 是 NGramOptions.N 的最大值
+const MaxNGram = 5
+
+// DefaultNGramTopK 是 NGramOptions.TopK 的默认值
+const DefaultNGramTopK = 100
+
+// DefaultNGramCapacity 是 NGramOptions.Capacity 的默认值
+const DefaultNGramCapacity = 1 << 16
+
+// NGramOptions 控制 n-gram 统计模式
+//
+// n-gram 用 Space-Saving 算法计数(见 topk.SpaceSaving)，内存占用与语料大小无关，
+// 代价是计数可能偏大：ResultItem.Frequency 是频数的上界，Frequency - FrequencyError 是下界。
+// 频数超过 n-gram 总数 / Capacity 的 n-gram 一定会被统计到
+type NGramOptions struct {
+	N        int  // n-gram 的长度，1~MaxNGram，0 时不统计 n-gram
+	Chars    bool // 统计字 n-gram (连续的 n 个字，如 "人工智能" 中的 "人工"、"工智"、"智能")；否则统计分词后的词 n-gram
+	TopK     int  // 只报告频数最高的 TopK 个，<= 0 时使用 DefaultNGramTopK
+	Capacity int  // 计数器至多记录的 n-gram 数，<= 0 时使用 DefaultNGramCapacity，不小于 TopK
+}
+
+func (o NGramOptions) topK() int {
+	if o.TopK <= 0 {
+		return DefaultNGramTopK
+	}
+	return o.TopK
+}
+
+func (o NGramOptions) capacity() int {
+	c := o.Capacity
+	if c <= 0 {
+		c = DefaultNGramCapacity
+	}
+	if k := o.topK(); c < k {
+		c = k
+	}
+	return c
+}
+
+// wordNGramSeparator 连接词 n-gram 中的词
+const wordNGramSeparator = " "
+
+// ngramming 判断 Task 是否处于 n-gram 统计模式
+func (t *Task) ngramming() bool {
+	return t.NGram.N > 0
+}
+
+// countNGrams 统计单个文件中的 n-gram。n-gram 不跨越标点，字 n-gram 也不跨越空白
+func (t *Task) countNGrams(ctx context.Context, file string) (*topk.SpaceSaving, error) {
+	f, err := t.openFile(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	counter := topk.New(t.NGram.capacity())
+	n := t.NGram.N
+	if t.NGram.Chars {
+		err = t.charNGrams(f, n, counter)
+		return counter, err
+	}
+	err = segment.SegmentSentencesReader(f, t.segmenter(), func(words []string) {
+		for i, w := range words {
+			words[i] = t.normalizeWord(w)
+		}
+		for i := 0; i+n <= len(words); i++ {
+			counter.Add(strings.Join(words[i:i+n], wordNGramSeparator), 1)
+		}
+	})
+	return counter, err
+}
+
+// charNGrams 统计 r 中连续的 n 个 word rune (见 segment.IsWordRune) 组成的 n-gram
+func (t *Task) charNGrams(r io.Reader, n int, counter *topk.SpaceSaving) error {
+	br := bufio.NewReader(r)
+	window := make([]rune, 0, n)
+	for {
+		c, _, err := br.ReadRune()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !segment.IsWordRune(c) {
+			window = window[:0]
+			continue
+		}
+		if len(window) == n {
+			window = append(window[:0], window[1:]...)
+		}
+		window = append(window, c)
+		if len(window) == n {
+			counter.Add(t.normalizeWord(string(window)), 1)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+// Copyright (c) 2020 CDFMLR. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at  http://www.apache.org/licenses/LICENSE-2.0
+
+package wordfa
+
+import (
+	"context"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// State 是任务的状态，JSON 中为其名字，如 "running"
+type State int
+
+// Task states
+const (
+	StatePending   State = iota // 未开始
+	StateRunning                // 运行中
+	StateDone                   // 已完成
+	StateFailed                 // 已完成，但有文件处理出错，结果只包含其余文件，见 Task.GetErrors
+	StateCancelled              // 被 Stop 或 RunContext 的 ctx 取消、超时，没有结果，见 Task.Err
+)
+
+var stateNames = [...]string{"pending", "running", "done", "failed", "cancelled"}
+
+func (s State) String() string {
+	if s < 0 || int(s) >= len(stateNames) {
+		return "unknown"
+	}
+	return stateNames[s]
+}
+
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Finished 判断任务是否已完成(包括有文件出错的完成)，此时可以获取结果
+func (s State) Finished() bool {
+	return s == StateDone || s == StateFailed
+}
+
+// Progress 是任务的执行进度。字节数按文件大小计，一个文件读取多遍时(如近似匹配、KWIC)只计最多的一遍
+type Progress struct {
+	State State `json:"state"`
+
+	Files     int   `json:"files"`      // 文件总数
+	FilesDone int   `json:"files_done"` // 已处理完成(含出错)的文件数
+	Bytes     int64 `json:"bytes"`      // 文件总字节数
+	BytesDone int64 `json:"bytes_done"` // 已处理的字节数
+
+	CurrentFiles []string `json:"current_files,omitempty"` // 正在处理的文件(按路径排序)
+
+	Elapsed    float64 `json:"elapsed"`    // 已运行的秒数
+	Throughput float64 `json:"throughput"` // 平均处理速度，字节/秒
+	ETA        float64 `json:"eta"`        // 预计剩余秒数，无法估计时为 -1
+}
+
+// Fraction 返回已完成的比例 [0, 1]，按字节计(文件都为空时按文件数计)。
+// 只有任务完成时才返回 1，未完成时至多返回 0.99
+func (p Progress) Fraction() float64 {
+	if p.State.Finished() {
+		return 1
+	}
+	f := 0.0
+	if p.Bytes > 0 {
+		f = float64(p.BytesDone) / float64(p.Bytes)
+	} else if p.Files > 0 {
+		f = float64(p.FilesDone) / float64(p.Files)
+	}
+	return math.Min(f, 0.99)
+}
+
+// estimate 由 BytesDone、Bytes 与 Elapsed 计算 Throughput 与 ETA
+func (p *Progress) estimate() {
+	p.Throughput, p.ETA = 0, -1
+	if p.Elapsed > 0 {
+		p.Throughput = float64(p.BytesDone) / p.Elapsed
+	}
+	switch {
+	case p.State.Finished():
+		p.ETA = 0
+	case p.State == StateRunning && p.Throughput > 0:
+		p.ETA = float64(p.Bytes-p.BytesDone) / p.Throughput
+	}
+}
+
+// merge 返回同时运行的两个任务 p、q 合计的进度
+func (p Progress) merge(q Progress) Progress {
+	m := Progress{
+		Files:        p.Files + q.Files,
+		FilesDone:    p.FilesDone + q.FilesDone,
+		Bytes:        p.Bytes + q.Bytes,
+		BytesDone:    p.BytesDone + q.BytesDone,
+		CurrentFiles: append(append([]string{}, p.CurrentFiles...), q.CurrentFiles...),
+		Elapsed:      math.Max(p.Elapsed, q.Elapsed),
+	}
+	sort.Strings(m.CurrentFiles)
+	if len(m.CurrentFiles) == 0 {
+		m.CurrentFiles = nil
+	}
+	// 按优先级: 任一被取消、运行中、未开始、出错，否则两者都已完成
+	for _, s := range []State{StateCancelled, StateRunning, StatePending, StateFailed, StateDone} {
+		if p.State == s || q.State == s {
+			m.State = s
+			break
+		}
+	}
+	m.estimate()
+	return m
+}
+
+// GetStatus 返回 Task 的执行进度
+func (t *Task) GetStatus() Progress {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	p := Progress{State: t.state()}
+	for file, finished := range t.fileMap {
+		size := t.sizes[file]
+		p.Files++
+		p.Bytes += size
+		if finished {
+			p.FilesDone++
+			p.BytesDone += size
+		} else if n := atomic.LoadInt64(t.read[file]); n < size {
+			p.BytesDone += n
+		} else {
+			p.BytesDone += size
+		}
+	}
+	for file := range t.current {
+		p.CurrentFiles = append(p.CurrentFiles, file)
+	}
+	sort.Strings(p.CurrentFiles)
+	if !t.startAt.IsZero() {
+		end := t.endAt
+		if t.running {
+			end = time.Now()
+		}
+		p.Elapsed = end.Sub(t.startAt).Seconds()
+	}
+	p.estimate()
+	return p
+}
+
+// state 返回 Task 的状态，调用者需持有 t.mux
+func (t *Task) state() State {
+	switch {
+	case t.err != nil:
+		return StateCancelled
+	case t.fileMap == nil:
+		return StatePending
+	case !t.unfinished():
+		if len(t.errors) > 0 {
+			return StateFailed
+		}
+		return StateDone
+	case t.running:
+		return StateRunning
+	}
+	return StatePending
+}
+
+// prepared 判断 Task 是否已经 prepare
+func (t *Task) prepared() bool {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	return t.fileMap != nil && t.matches != nil
+}
+
+// finished 判断 Task 是否已完成，即所有文件都已处理完成(含出错)
+func (t *Task) finished() bool {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	return t.fileMap != nil && !t.unfinished()
+}
+
+// statFiles 记下 SrcFiles 中各文件的大小，并为各文件准备已读取字节数的计数器，调用者需持有 t.mux
+func (t *Task) statFiles() {
+	t.sizes = map[string]int64{}
+	t.read = map[string]*int64{}
+	for file := range t.fileMap {
+		if info, err := os.Stat(file); err == nil {
+			t.sizes[file] = info.Size()
+		}
+		t.read[file] = new(int64)
+	}
+	t.current = map[string]bool{}
+}
+
+// setCurrent 标记文件 file 是否正在处理
+func (t *Task) setCurrent(file string, current bool) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if current {
+		t.current[file] = true
+	} else {
+		delete(t.current, file)
+	}
+}
+
+// openFile 打开文件 file 用于读取。ctx 取消后，从中读取会返回 ctx.Err()，
+// 所以流式处理文件的分词、近似匹配、KWIC 等都会在下一次读取时停止。
+// 读取的字节数计入 GetStatus 的进度
+func (t *Task) openFile(ctx context.Context, file string) (io.ReadCloser, error) {
+	// prepare 之后 t.read 只读，不需要加锁
+	return openReader(ctx, file, t.read[file])
+}
+
+// searchProgress 返回 strsearch.ParallelOptions.Progress 回调，把在文件 file 中已搜索的字节数计入进度
+func (t *Task) searchProgress(file string) func(n int) {
+	read := t.read[file]
+	var searched int64
+	return func(n int) {
+		recordMax(read, atomic.AddInt64(&searched, int64(n)))
+	}
+}
+
+// recordMax 在 n 更大时把 *counter 更新为 n，counter 为 nil 时什么都不做
+func recordMax(counter *int64, n int64) {
+	if counter == nil {
+		return
+	}
+	for {
+		old := atomic.LoadInt64(counter)
+		if old >= n || atomic.CompareAndSwapInt64(counter, old, n) {
+			return
+		}
+	}
+}
+
+// openReader 打开文件 file，返回 ctx 取消后读取返回 ctx.Err() 的 contextReader，读取的字节数计入 read
+func openReader(ctx context.Context, file string, read *int64) (io.ReadCloser, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	return &contextReader{ctx: ctx, ReadCloser: f, read: read}, nil
+}
+
+// contextReader 是 ctx 取消后读取返回 ctx.Err() 的 io.ReadCloser，
+// 同时把本次已读取的字节数(若更大)记入 read
+type contextReader struct {
+	ctx context.Context
+	io.ReadCloser
+	n    int64
+	read *int64
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	recordMax(r.read, r.n)
+	return n, err
+}
@@ -0,0 +1,225 @@
+// Copyright (c) 2020 CDFMLR. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at  http://www.apache.org/licenses/LICENSE-2.0
+
+package wordfa
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+)
+
+// Scoring methods
+const (
+	ScoreFrequency     = iota // 不评分，按频数排序
+	ScoreTfIdf                // TF-IDF: 词在某个文件中的 TF-IDF 的最大值，即该词最能代表的那个文件中的得分
+	ScoreLogLikelihood        // 对数似然比 (G²) 关键性: 语料相对参照语料的关键性
+	ScoreChiSquared           // 卡方 (χ²) 关键性: 语料相对参照语料的关键性
+	_nothingScore
+)
+
+var ScoringMethodsMap = map[string]int{
+	"Frequency":     ScoreFrequency,
+	"TfIdf":         ScoreTfIdf,
+	"LogLikelihood": ScoreLogLikelihood,
+	"ChiSquared":    ScoreChiSquared,
+}
+
+// ValidScoringMethod 判断 method 是否是合法的评分方法枚举值
+func ValidScoringMethod(method int) bool {
+	return method >= 0 && method < _nothingScore
+}
+
+// ScoreOptions 控制结果的评分。评分时结果按 ResultItem.Score 从大到小排序，而不是按频数
+//
+// 评分需要各文件的词数(用 Task.Segmenter 分词统计)，所以会多扫描一遍文件。
+// 关键性为正表示词在语料中比在参照语料中更常用，为负表示更少用。n-gram 模式不评分
+type ScoreOptions struct {
+	Method    int      // 评分方法，见 ScoringMethodsMap
+	Reference []string // 关键性评分的参照语料文件，以与 Task 相同的选项统计
+}
+
+// ErrNoReference 表示关键性评分没有给出参照语料
+var ErrNoReference = errors.New("LogLikelihood and ChiSquared scoring need a reference corpus")
+
+// Validate 检查评分选项：关键性评分(ScoreLogLikelihood、ScoreChiSquared)必须给出参照语料
+func (o ScoreOptions) Validate() error {
+	if (o.Method == ScoreLogLikelihood || o.Method == ScoreChiSquared) && len(o.Reference) == 0 {
+		return ErrNoReference
+	}
+	return nil
+}
+
+// scoring 判断 Task 是否需要评分
+func (t *Task) scoring() bool {
+	return t.Score.Method != ScoreFrequency && !t.ngramming()
+}
+
+// keyness 判断 Task 是否计算相对参照语料的关键性
+func (t *Task) keyness() bool {
+	return t.scoring() && (t.Score.Method == ScoreLogLikelihood || t.Score.Method == ScoreChiSquared)
+}
+
+// needWords 判断是否需要统计各文件的词数
+func (t *Task) needWords() bool {
+	return t.scoring() || t.withWords
+}
+
+// countWords 返回单个文件的词数
+func (t *Task) countWords(ctx context.Context, file string) (int, error) {
+	n := 0
+	err := t.segmentFile(ctx, file, func(string) { n++ })
+	return n, err
+}
+
+// sibling 返回以与 t 相同的匹配选项统计 srcFiles 中各词频数、词数的任务，
+// 不含近似匹配、KWIC、索引、n-gram 与评分
+func (t *Task) sibling(srcFiles []string) *Task {
+	return &Task{
+		SrcFiles:           srcFiles,
+		Patterns:           t.Patterns,
+		StrSearchAlgorithm: t.StrSearchAlgorithm,
+		StrSearchFuncName:  t.StrSearchFuncName,
+		StreamThreshold:    t.StreamThreshold,
+		Parallel:           t.Parallel,
+		MatchOptions:       t.MatchOptions,
+		Discover:           DiscoverOptions{MinRunes: t.Discover.MinRunes},
+		TokenMatch:         t.TokenMatch,
+		Workers:            t.Workers,
+		Segmenter:          t.Segmenter,
+		withWords:          true,
+	}
+}
+
+// ReferenceErrorPrefix 是 GetErrors 中参照语料文件的前缀，以区别于 SrcFiles 中的文件
+const ReferenceErrorPrefix = "reference:"
+
+// matchReference 以与 t 相同的选项统计参照语料 Score.Reference，
+// 结果放入 t.refMatches、t.refWords，出错的文件以 ReferenceErrorPrefix 为前缀记入 t.errors
+func (t *Task) matchReference(ctx context.Context) {
+	ref := t.sibling(t.Score.Reference)
+	ref.RunContext(ctx)
+
+	ref.mux.Lock()
+	defer ref.mux.Unlock()
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	t.refMatches = ref.matches
+	for file, e := range ref.errors {
+		t.errors[ReferenceErrorPrefix+file] = e
+	}
+	t.refWords = 0
+	for _, n := range ref.words {
+		t.refWords += n
+	}
+}
+
+// files 返回 Task 统计的所有文件(按路径排序)，调用者需持有 t.mux
+func (t *Task) files() []string {
+	var files []string
+	for file := range t.fileMap {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// tfidf 返回词 k 在各文件 files 中的 TF-IDF，调用者需持有 t.mux。
+// TF 是词在文件中的频数 / 文件的词数，IDF 是平滑的逆文档频率 ln((1 + 文件数) / (1 + 含该词的文件数)) + 1
+func (t *Task) tfidf(k string, files []string) []float64 {
+	df := 0
+	for _, file := range files {
+		if t.fileMatches[file][k] > 0 {
+			df++
+		}
+	}
+	idf := math.Log(float64(1+len(files))/float64(1+df)) + 1
+
+	scores := make([]float64, len(files))
+	for i, file := range files {
+		if n := t.words[file]; n > 0 {
+			scores[i] = float64(t.fileMatches[file][k]) / float64(n) * idf
+		}
+	}
+	return scores
+}
+
+// score 返回词 k 的得分，调用者需持有 t.mux
+func (t *Task) score(k string, files []string) float64 {
+	switch t.Score.Method {
+	case ScoreTfIdf:
+		max := 0.0
+		for _, s := range t.tfidf(k, files) {
+			max = math.Max(max, s)
+		}
+		return max
+	case ScoreLogLikelihood, ScoreChiSquared:
+		words := 0
+		for _, n := range t.words {
+			words += n
+		}
+		if t.Score.Method == ScoreLogLikelihood {
+			return logLikelihood(t.matches[k], t.refMatches[k], words, t.refWords)
+		}
+		return chiSquared(t.matches[k], t.refMatches[k], words, t.refWords)
+	}
+	return 0
+}
+
+// logLikelihood 返回频数 a (语料共 c 词) 相对频数 b (参照语料共 d 词) 的对数似然比 G²，
+// 语料中的相对频数较低时取负值
+func logLikelihood(a, b, c, d int) float64 {
+	n := float64(c + d)
+	if n == 0 {
+		return 0
+	}
+	e1 := float64(c) * float64(a+b) / n
+	e2 := float64(d) * float64(a+b) / n
+	g2 := 2 * (xlogy(float64(a), e1) + xlogy(float64(b), e2))
+	return signed(g2, a, b, c, d)
+}
+
+// chiSquared 返回 2x2 列联表 [[a, b], [c-a, d-b]] 的 Pearson 卡方值，语料中的相对频数较低时取负值
+func chiSquared(a, b, c, d int) float64 {
+	o11, o12, o21, o22 := float64(a), float64(b), float64(c-a), float64(d-b)
+	den := (o11 + o12) * (o21 + o22) * (o11 + o21) * (o12 + o22)
+	if den == 0 {
+		return 0
+	}
+	x := o11*o22 - o12*o21
+	return signed(float64(c+d)*x*x/den, a, b, c, d)
+}
+
+// xlogy 返回 x * ln(x / y)，x 为 0 时返回 0
+func xlogy(x, y float64) float64 {
+	if x == 0 {
+		return 0
+	}
+	return x * math.Log(x/y)
+}
+
+// signed 在语料中的相对频数 a/c 低于参照语料 b/d 时返回 -v，否则返回 v
+func signed(v float64, a, b, c, d int) float64 {
+	if float64(a)*float64(d) < float64(b)*float64(c) {
+		return -v
+	}
+	return v
+}
+
+// byScore 使 Result 按 Score 从大到小排序
+type byScore Result
+
+func (r byScore) Len() int {
+	return len(r)
+}
+
+func (r byScore) Less(i, j int) bool {
+	return r[i].Score > r[j].Score
+}
+
+func (r byScore) Swap(i, j int) {
+	r[i], r[j] = r[j], r[i]
+}
@@ -0,0 +1,133 @@
+// Copyright (c) 2020 CDFMLR. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at  http://www.apache.org/licenses/LICENSE-2.0
+
+package wordfa
+
+import (
+	"CiFa/util/segment"
+	"CiFa/util/strsearch"
+	"context"
+	"strings"
+	"unicode/utf8"
+)
+
+// DiscoverOptions 控制词汇发现模式(Task.Patterns 为空时)的结果
+type DiscoverOptions struct {
+	TopN     int // 只保留频数最高的 TopN 个词，<= 0 时保留全部
+	MinRunes int // 只统计至少有 MinRunes 个字的词，如 2 可以排除 "的"、"了" 等单字
+}
+
+// discovering 判断 Task 是否处于词汇发现模式：没有给定关键词时，对文本分词并统计所有词
+func (t *Task) discovering() bool {
+	return len(t.Patterns) == 0
+}
+
+// segmenting 判断 Task 是否需要分词(词汇发现或分词匹配模式)，而不是搜索子串
+func (t *Task) segmenting() bool {
+	return t.discovering() || t.TokenMatch
+}
+
+// segmentFile 用 Task.Segmenter 对单个文件分词，对每个词调用 fn
+func (t *Task) segmentFile(ctx context.Context, file string, fn func(word string)) error {
+	f, err := t.openFile(ctx, file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return segment.SegmentReader(f, t.segmenter(), fn)
+}
+
+func (t *Task) segmenter() segment.Segmenter {
+	if t.Segmenter != nil {
+		return t.Segmenter
+	}
+	return &segment.ForwardMaxMatch{}
+}
+
+// countTokens 对单个文件分词并计数：词汇发现模式返回各词的出现次数，分词匹配模式返回各关键词的出现次数
+func (t *Task) countTokens(ctx context.Context, file string) (map[string]int, error) {
+	if t.discovering() {
+		return t.discoverFile(ctx, file)
+	}
+	return t.matchTokens(ctx, file)
+}
+
+// discoverFile 对单个文件分词，返回各词的出现次数。
+// MatchOptions.IgnoreCase 时词被转为小写，MatchOptions.NormalizeWidth 时全角字符被转为半角
+func (t *Task) discoverFile(ctx context.Context, file string) (map[string]int, error) {
+	counts := map[string]int{}
+	err := t.segmentFile(ctx, file, func(word string) {
+		if utf8.RuneCountInString(word) < t.Discover.MinRunes {
+			return
+		}
+		counts[t.normalizeWord(word)]++
+	})
+	return counts, err
+}
+
+// normalizeWord 规范化统计结果中的词: MatchOptions.IgnoreCase 时转为小写，MatchOptions.NormalizeWidth 时全角转为半角
+func (t *Task) normalizeWord(word string) string {
+	if t.MatchOptions.NormalizeWidth {
+		word = strsearch.MatchOptions{NormalizeWidth: true}.NormalizeString(word)
+	}
+	if t.MatchOptions.IgnoreCase {
+		word = strings.ToLower(word)
+	}
+	return word
+}
+
+// tokenSeparator 连接词序列，作为 matchTokens 中词序列的 key
+const tokenSeparator = "\x00"
+
+// matchTokens 对单个文件分词，返回各关键词作为完整的词(或连续的词序列)出现的次数。
+// 关键词用同一分词器切分，其中的标点被忽略；文本中的词序列不跨越标点，如 "end. Start" 不匹配 "end start"。
+// 词与关键词都按 MatchOptions 规范化后比较
+func (t *Task) matchTokens(ctx context.Context, file string) (map[string]int, error) {
+	seg := t.segmenter()
+	keys := map[string][]string{} // {"词序列": 关键词}
+	seen := map[string]bool{}
+	maxWords := 0
+	for _, p := range t.Patterns {
+		words := seg.Segment(p)
+		if len(words) == 0 || seen[p] {
+			continue
+		}
+		seen[p] = true
+		for i, w := range words {
+			words[i] = t.MatchOptions.NormalizeString(w)
+		}
+		key := strings.Join(words, tokenSeparator)
+		keys[key] = append(keys[key], p)
+		if len(words) > maxWords {
+			maxWords = len(words)
+		}
+	}
+
+	counts := map[string]int{}
+	if maxWords == 0 { // 关键词都只有标点，不会匹配任何词
+		return counts, nil
+	}
+	f, err := t.openFile(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	window := make([]string, 0, maxWords) // 当前句子中最近的 maxWords 个词
+	err = segment.SegmentSentencesReader(f, seg, func(words []string) {
+		window = window[:0]
+		for _, word := range words {
+			if len(window) == maxWords {
+				window = append(window[:0], window[1:]...)
+			}
+			window = append(window, t.MatchOptions.NormalizeString(word))
+			for n := 1; n <= len(window); n++ {
+				for _, p := range keys[strings.Join(window[len(window)-n:], tokenSeparator)] {
+					counts[p]++
+				}
+			}
+		}
+	})
+	return counts, err
+}
@@ -6,21 +6,35 @@
 package wordfa
 
 import (
+	"CiFa/util/index"
+	"CiFa/util/segment"
 	"CiFa/util/sortalgo"
 	"CiFa/util/strsearch"
+	"CiFa/util/topk"
+	"context"
+	"fmt"
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
 	"sync"
+	"time"
 )
 
 // Task 是"统计给定关键词 Patterns 在一系列文本文件 SrcFiles 中出现的频数"的任务
 //
+// Patterns 为空时，Task 工作在词汇发现模式：对文本分词，统计所有词的频数，见 Discover。
+// NGram.N > 0 时，Task 忽略 Patterns，统计最频繁的 n-gram，见 NGram。
+//
 // 可以通过对 StrSearchAlgorithm 字段赋值，以使用不同算法。
 // 调用 Task 实例的 Run() 方法开始统计任务，
-// 调用 Task 实例的 GetProgress() 方法获取任务执行进度，
+// 调用 Task 实例的 GetStatus() (或 GetProgress()) 方法获取任务执行进度，
 // 在 Run 完成后，调用 Task 实例的 GetResult() 方法得到结果。
 type Task struct {
 	SrcFiles []string // 待检测的文件
-	Patterns []string // 待匹配的词
+	Patterns []string // 待匹配的词，为空时统计所有词
 
 	// 指定使用的字符串搜索算法，StrSearchFunc 不为 nil 时，将忽略 StrSearchAlgorithm 指定的值
 	StrSearchAlgorithm int    // 指定使用的字符串搜索算法, see strsearch
@@ -28,13 +42,81 @@ type Task struct {
 
 	SortFuncName string // 获取结果时的排序算法 sortalgo.SortAlgorithm 的函数名，通过反射机制调用，此值不为 nil 则会覆盖 GetResult 的 sortAlgorithm 参数效果
 
-	fileMap map[string]bool // SrcFiles 中的所有文件，value 是代表是否检索完成的
-	matches map[string]int  // 已完成的匹配 {"词": 出现次数}
+	StreamThreshold int64 // 大于此大小(字节)的文件将流式读取、分块检索，以限制内存占用，<= 0 时使用 DefaultStreamThreshold
+
+	// 单个文件内的分块并行搜索: 文件被分成相互重叠的块，由 Parallel.Workers 个 goroutine 并行搜索。
+	// Parallel.Workers 为 0 时使用 runtime.NumCPU()，为 1 时不分块并行
+	Parallel strsearch.ParallelOptions
+
+	MatchOptions strsearch.MatchOptions // 匹配选项: 忽略大小写、全词匹配、全半角归一，零值为逐字节精确匹配
+
+	MaxEditDistance int // > 0 时额外统计与关键词的编辑距离(按字计)在 [1, MaxEditDistance] 内的近似匹配，见 strsearch.ApproxSearch
+
+	Concordance ConcordanceOptions // Concordance.Snippets > 0 时为每个关键词收集 KWIC 片段，见 ResultItem.Snippets
+
+	// 预先建立的语料索引，不为 nil 时在索引上二分查找得到频数，不再扫描文件，见 index.Index。
+	// SrcFiles 为空时使用建立索引的文件，否则只统计 SrcFiles 中的文件，其中不在索引中的文件记为出错。
+	// 索引只能回答精确匹配的频数：设置了 MatchOptions、MaxEditDistance、Concordance、Cooccurrence 或使用 LibRegexp 时仍会扫描文件
+	Index *index.Index
+
+	// 词汇发现模式(Patterns 为空)的选项。该模式只统计频数，忽略 MaxEditDistance、Concordance 和 Index，
+	// MatchOptions 中 IgnoreCase 把词转为小写，NormalizeWidth 把全角字符转为半角
+	Discover DiscoverOptions
+
+	// 分词匹配模式: 只统计分词后完整的词，如 "是" 不计入 "不是" 中的 "是"。
+	// 由多个词组成的关键词匹配连续的词序列。该模式忽略 StrSearchAlgorithm、MaxEditDistance、Concordance 和 Index
+	TokenMatch bool
+
+	// n-gram 统计模式的选项，NGram.N > 0 时统计 n-gram 而不是 Patterns，
+	// 结果是频数最高的 NGram.TopK 个 n-gram。该模式忽略 MaxEditDistance、Concordance 和 Index
+	NGram NGramOptions
+
+	Score ScoreOptions // 结果的评分(TF-IDF、关键性)，默认不评分，按频数排序
+
+	Cooccurrence CooccurrenceOptions // 关键词在同一句子、段落或窗口内的共现，默认不统计，见 GetCooccurrence
 
-	exit chan bool
-	mux  sync.Mutex
+	// 同时处理的文件数(工作池的大小)，<= 0 时使用 runtime.NumCPU()。
+	// 处理某个文件出错时不会中断任务：错误被记录下来(见 GetErrors)，结果中只是不含该文件
+	Workers int
+
+	Segmenter segment.Segmenter // 词汇发现、分词匹配、词 n-gram 模式与评分中统计词数使用的分词器，为 nil 时使用内置词典的正向最大匹配，见 segment.By
+
+	fileMap      map[string]bool           // SrcFiles 中的所有文件，value 是代表是否检索完成的
+	matches      map[string]int            // 已完成的匹配 {"词": 出现次数}
+	fileMatches  map[string]map[string]int // 各文件中已完成的匹配 {"文件": {"词": 出现次数}}，n-gram 模式不统计
+	fuzzyMatches map[string]int            // 已完成的近似匹配 {"词": 出现次数}，不含精确匹配
+	snippets     map[string][]Snippet      // 已收集的 KWIC 片段 {"词": 片段}，按文件、位置排序，至多 Concordance.Snippets 个
+	keywordRes   map[string]*regexp.Regexp // LibRegexp 收集片段时，匹配各关键词的 ^(?:关键词)，见 keywordLength
+	ngrams       *topk.SpaceSaving         // n-gram 模式中已完成的 n-gram 计数
+	words        map[string]int            // 评分或 withWords 时各文件的词数 {"文件": 词数}
+	refMatches   map[string]int            // 参照语料中的匹配 {"词": 出现次数}
+	refWords     int                       // 参照语料的词数
+	withWords    bool                      // 总是统计各文件的词数，用于参照语料、比较任务的子任务
+	cooccurPairs map[cooccurPair]int       // 各对关键词的共现次数
+	cooccurFreq  map[string]int            // 共现统计中各关键词的频数，见 Cooccurrence.Frequencies
+	cooccurUnits int                       // 共现统计中的句子/段落数或总字数，见 Cooccurrence.Units
+	errors       map[string]string         // 处理出错的文件 {"文件": 错误信息}
+	sizes        map[string]int64          // 各文件的字节数
+	read         map[string]*int64         // 各文件已读取的字节数，prepare 后 map 只读，计数器原子地更新
+	current      map[string]bool           // 正在处理的文件
+
+	running bool      // RunContext 正在运行
+	startAt time.Time // 开始运行的时间
+	endAt   time.Time // 运行结束的时间
+
+	observers    map[int]Observer // 见 Subscribe
+	nextObserver int
+	omux         sync.Mutex // 保护 observers，通知观察者时不持有 mux
+
+	cancel  context.CancelFunc // 取消正在进行的 RunContext
+	stopped bool               // 已调用过 Stop
+	err     error              // 被取消的原因，见 Err
+	mux     sync.Mutex
 }
 
+// DefaultStreamThreshold 是 Task.StreamThreshold 的默认值
+const DefaultStreamThreshold = 64 << 20
+
 func NewTask(srcFiles []string, patterns []string) *Task {
 	return &Task{SrcFiles: srcFiles, Patterns: patterns}
 }
@@ -46,162 +128,546 @@ func (t *Task) prepare() {
 
 	// map patterns
 	t.matches = map[string]int{}
+	t.fileMatches = map[string]map[string]int{}
+	t.words = map[string]int{}
+	t.refMatches = map[string]int{}
+	t.refWords = 0
+	t.cooccurPairs = map[cooccurPair]int{}
+	t.cooccurFreq = map[string]int{}
+	t.cooccurUnits = 0
+	t.errors = map[string]string{}
+	t.fuzzyMatches = map[string]int{}
+	t.snippets = map[string][]Snippet{}
+	t.ngrams = nil
+	if t.ngramming() {
+		t.ngrams = topk.New(t.NGram.capacity())
+	}
 	for _, p := range t.Patterns {
 		t.matches[p] = 0
+		t.fuzzyMatches[p] = 0
 	}
 
 	// Map files
+	if t.Index != nil && len(t.SrcFiles) == 0 {
+		t.SrcFiles = t.Index.Paths()
+	}
 	t.fileMap = map[string]bool{}
 	for _, f := range t.SrcFiles {
 		t.fileMap[f] = false
 	}
+	t.statFiles()
 
 	// algorithms
-	t.StrSearchAlgorithm = strsearch.StrsearchAlgorithmsMap[t.StrSearchFuncName]
+	if algorithm, ok := strsearch.StrsearchAlgorithmsMap[t.StrSearchFuncName]; ok {
+		t.StrSearchAlgorithm = algorithm
+	}
+	t.keywordRes = map[string]*regexp.Regexp{}
+	if t.StrSearchAlgorithm == strsearch.LibRegexp && t.Concordance.Snippets > 0 {
+		for _, p := range t.Patterns {
+			if re, err := regexp.Compile(`^(?:` + p + `)`); err == nil {
+				t.keywordRes[p] = re
+			}
+		}
+	}
 }
 
 // match search the files in Task.SrcFiles, try to get {"word": frequency} for each word in Task.Patterns
 // Task.prepare() calling before this method is required
 // Result put into Task.matches
-func (t *Task) match() {
-	if t.GetProgress() <= -1 {
+// ctx 取消后不再处理新的文件，正在处理的文件也会中途停止(见 openFile、matchFile)，且不记为出错
+func (t *Task) match(ctx context.Context) {
+	if !t.prepared() {
 		panic("Task not prepared, cannot run match()")
 	}
+	if t.keyness() && len(t.Score.Reference) > 0 {
+		t.matchReference(ctx)
+	}
+	if t.useIndex() {
+		t.matchIndex(ctx)
+		return
+	}
+	files := make(chan string)
 	var wg sync.WaitGroup
-	for filePath, _ := range t.fileMap {
+	for i := 0; i < t.workers(); i++ {
 		wg.Add(1)
-		go func(t *Task, file string) {
-			// Read File
-			data, err := ioutil.ReadFile(file)
-			if err != nil {
-				panic(err)
+		go func() {
+			defer wg.Done()
+			for file := range files {
+				err := t.matchOne(ctx, file)
+				if ctx.Err() != nil {
+					continue
+				}
+				if err != nil {
+					t.fail(file, err)
+				}
+				t.notify(EventFile, file, err)
 			}
-			// Find matches
-			for _, pattern := range t.Patterns {
-				found := len(strsearch.By(t.StrSearchAlgorithm).FindAllBytes(data, pattern))
+		}()
+	}
+	for _, file := range t.pendingFiles() {
+		select {
+		case files <- file:
+		case <-ctx.Done():
+		}
+	}
+	close(files)
+	wg.Wait()
+}
 
-				t.mux.Lock()
-				t.matches[pattern] += found
-				t.mux.Unlock()
-			}
-			// tag matched file
-			t.mux.Lock()
-			t.fileMap[file] = true
-			t.mux.Unlock()
+// workers 返回同时处理的文件数
+func (t *Task) workers() int {
+	if t.Workers <= 0 {
+		return runtime.NumCPU()
+	}
+	return t.Workers
+}
 
-			wg.Done()
-		}(t, filePath)
+// pendingFiles 返回所有未处理完成的文件(按路径排序)
+func (t *Task) pendingFiles() []string {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	var files []string
+	for file, finished := range t.fileMap {
+		if !finished {
+			files = append(files, file)
+		}
 	}
-	wg.Wait()
+	sort.Strings(files)
+	return files
 }
 
-// GetProgress return the current status of Task
-// Status:
-//		<= -1		// unprepared
-//		[0, 1)		// matching, the number means the progress
-//		>= 1		// finished
-func (t *Task) GetProgress() float32 {
+// matchOne 统计单个文件，把结果合并到 Task 中，并标记该文件已完成。
+// 出错(包括 panic)时返回错误，不合并该文件的任何结果
+func (t *Task) matchOne(ctx context.Context, file string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	t.setCurrent(file, true)
+	defer t.setCurrent(file, false)
+
+	words := 0
+	if t.needWords() {
+		if words, err = t.countWords(ctx, file); err != nil {
+			return err
+		}
+	}
+	if t.ngramming() {
+		counter, err := t.countNGrams(ctx, file)
+		if err != nil {
+			return err
+		}
+		t.mux.Lock()
+		defer t.mux.Unlock()
+		t.ngrams.Merge(counter)
+		t.finish(file, words)
+		return nil
+	}
+	if t.segmenting() {
+		counts, err := t.countTokens(ctx, file)
+		if err != nil {
+			return err
+		}
+		t.mux.Lock()
+		defer t.mux.Unlock()
+		for word, n := range counts {
+			t.matches[word] += n
+		}
+		t.fileMatches[file] = counts
+		t.finish(file, words)
+		return nil
+	}
+	// Find matches: 多模式串算法(如 AhoCorasick)对每个文件只扫描一遍
+	found, err := t.matchFile(ctx, file)
+	if err != nil {
+		return err
+	}
+	var fuzzy map[string]int
+	if t.MaxEditDistance > 0 {
+		if fuzzy, err = t.matchFileFuzzy(ctx, file, found); err != nil {
+			return err
+		}
+	}
+	var snippets map[string][]Snippet
+	if t.Concordance.Snippets > 0 {
+		if snippets, err = t.collectSnippets(ctx, file, found); err != nil {
+			return err
+		}
+	}
+	var cooccur cooccurCounts
+	if t.cooccurring() {
+		if cooccur, err = t.cooccurFile(ctx, file, found); err != nil {
+			return err
+		}
+	}
+	counts := map[string]int{}
+	for pattern, indices := range found {
+		counts[pattern] = len(indices)
+	}
+
 	t.mux.Lock()
 	defer t.mux.Unlock()
+	for pattern, n := range counts {
+		t.matches[pattern] += n
+	}
+	t.fileMatches[file] = counts
+	for pattern, n := range fuzzy {
+		t.fuzzyMatches[pattern] += n
+	}
+	for pattern, s := range snippets { // 每合并一个文件就截断，占用的内存与匹配总数无关
+		t.snippets[pattern] = sortSnippets(append(t.snippets[pattern], s...), t.Concordance.Snippets)
+	}
+	for pair, n := range cooccur.pairs {
+		t.cooccurPairs[pair] += n
+	}
+	for pattern, n := range cooccur.freq {
+		t.cooccurFreq[pattern] += n
+	}
+	t.cooccurUnits += cooccur.units
+	// tag matched file
+	t.finish(file, words)
+	return nil
+}
 
-	// unprepared
-	if t.fileMap == nil || t.matches == nil {
-		return -1.1
+// finish 标记文件 file 已完成，needWords 时记下其词数 words，调用者需持有 t.mux
+func (t *Task) finish(file string, words int) {
+	if t.needWords() {
+		t.words[file] = words
 	}
+	t.fileMap[file] = true
+}
 
-	totalFiles := 0
-	finishedFiles := 0
-	for _, finished := range t.fileMap {
-		totalFiles++
-		if finished {
-			finishedFiles++
+// fail 记录处理文件 file 时的错误 err，并标记该文件已完成(结果中不含该文件)
+func (t *Task) fail(file string, err error) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	t.errors[file] = err.Error()
+	t.fileMap[file] = true
+}
+
+// useIndex 判断是否可以用 Index 代替扫描文件
+func (t *Task) useIndex() bool {
+	return t.Index != nil && !t.segmenting() && !t.ngramming() && t.MatchOptions.IsZero() && t.MaxEditDistance <= 0 && t.Concordance.Snippets <= 0 &&
+		!t.cooccurring() && t.StrSearchAlgorithm != strsearch.LibRegexp
+}
+
+// matchIndex 在 Index 上查询所有 Patterns 在 SrcFiles 中的频数
+func (t *Task) matchIndex(ctx context.Context) {
+	// 索引中的路径与 SrcFiles 的写法可能不同(如 "./a.txt" 与 "a.txt")，都转为绝对路径后比较
+	indexed := map[string]string{} // 绝对路径 -> 索引中的路径
+	for _, path := range t.Index.Paths() {
+		indexed[absPath(path)] = path
+	}
+	files := map[string][]string{} // 索引中的路径 -> SrcFiles 中的路径
+	for _, file := range t.pendingFiles() {
+		path, ok := indexed[absPath(file)]
+		if !ok {
+			t.fail(file, fmt.Errorf("%v is not in the index", file))
+			continue
+		}
+		files[path] = append(files[path], file)
+	}
+
+	if t.needWords() {
+		for _, file := range t.pendingFiles() {
+			n, err := t.countWords(ctx, file)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				t.fail(file, err)
+				continue
+			}
+			t.mux.Lock()
+			t.words[file] = n
+			t.mux.Unlock()
 		}
 	}
 
-	// matching finished
-	if finishedFiles == totalFiles {
-		return 1.1
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	for pattern := range t.matches {
+		for path, n := range t.Index.CountByFile(pattern) {
+			for _, file := range files[path] { // 建立索引的文件可能比 SrcFiles 多
+				t.matches[pattern] += n
+				if t.fileMatches[file] == nil {
+					t.fileMatches[file] = map[string]int{}
+				}
+				t.fileMatches[file][pattern] = n
+			}
+		}
 	}
+	for file := range t.fileMap {
+		t.fileMap[file] = true
+	}
+}
 
-	// matching, return progress
-	return float32(finishedFiles) / float32(totalFiles)
+// absPath 返回 path 的绝对路径，出错时返回 filepath.Clean(path)
+func absPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	return abs
+}
+
+// matchFile 在单个文件中搜索所有 Patterns。
+// 文件大于 StreamThreshold 时分块读取，否则整个读入内存；两种情况下都按 Parallel 分块并行搜索，
+// ctx 取消后正在进行的搜索也会中途停止，见 strsearch.MultiByContext。
+// 不能分块的算法(LibRegexp，见 strsearch.Chunkable)总是整个读入内存、整体搜索
+func (t *Task) matchFile(ctx context.Context, file string) (map[string][]int, error) {
+	threshold := t.StreamThreshold
+	if threshold <= 0 {
+		threshold = DefaultStreamThreshold
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	algorithm := strsearch.MultiByContext(ctx, t.StrSearchAlgorithm)
+	par := t.Parallel
+	if par.Workers <= 0 {
+		par.Workers = runtime.NumCPU()
+	}
+
+	// 进度按已搜索的字节数计，而不是已读入内存的字节数
+	par.Progress = t.searchProgress(file)
+	f, err := openReader(ctx, file, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	chunkable := strsearch.Chunkable(t.StrSearchAlgorithm)
+	if info.Size() > threshold && chunkable {
+		return algorithm.FindAllReaderContext(ctx, f, t.Patterns, t.MatchOptions, par)
+	}
+
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	if !chunkable {
+		par.ChunkSize = len(data)
+	}
+	return algorithm.FindAllBytesContext(ctx, data, t.Patterns, t.MatchOptions, par)
+}
+
+// matchFileFuzzy 在单个文件中近似搜索所有 Patterns，返回各词的近似匹配次数。
+// 与 exact (matchFile 的结果) 中精确匹配重叠的近似匹配不计入，
+// 所以 GetResult 中 Frequency + FuzzyFrequency 是该词(含错别字)的总出现次数
+func (t *Task) matchFileFuzzy(ctx context.Context, file string, exact map[string][]int) (map[string]int, error) {
+	f, err := t.openFile(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	approx, err := strsearch.ApproxSearchReader(f, t.Patterns, t.MaxEditDistance, t.MatchOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	fuzzy := map[string]int{}
+	for pattern, matches := range approx {
+		indices := exact[pattern]
+		j := 0
+		for _, m := range matches {
+			if m.Distance == 0 {
+				continue
+			}
+			// indices 升序，跳过在 m 之前结束的精确匹配
+			for j < len(indices) && indices[j]+len(pattern) <= m.Start {
+				j++
+			}
+			if j < len(indices) && indices[j] < m.End {
+				continue
+			}
+			fuzzy[pattern]++
+		}
+	}
+	return fuzzy, nil
+}
+
+// GetProgress 返回 Task 已完成的比例 [0, 1]，按已处理的字节数计，只有完成时才为 1。
+// 状态、文件数、速度、剩余时间等详细进度见 GetStatus
+func (t *Task) GetProgress() float32 {
+	return float32(t.GetStatus().Fraction())
 }
 
 // Run do preparing jobs and start matching task.
 // It is Recommended to be called by:
 //		go task.Run()
 func (t *Task) Run() {
+	t.RunContext(context.Background())
+}
+
+// RunContext 同 Run，但 ctx 取消或超时后，所有 worker 及正在进行的搜索、分词都中途停止:
+// 搜索(包括 LibRegexp)每扫描约 64 KiB 或每个模式串检查一次 ctx，分词、近似匹配在下一次读取时停止。
+// RunContext 随后返回 ctx.Err()。被取消的 Task 进度停留在取消时，没有结果，Err() 返回取消的原因。
+// 运行中的进度、文件完成与结束事件发送给 Subscribe 注册的观察者
+func (t *Task) RunContext(ctx context.Context) error {
 	// prepare
-	if t.GetProgress() <= -1 {
+	if !t.prepared() {
 		t.prepare()
 	}
 
-	// go run match
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 	t.mux.Lock()
-	t.exit = make(chan bool)
+	t.cancel = cancel
+	if t.stopped {
+		cancel()
+	}
+	t.running = true
+	if t.startAt.IsZero() {
+		t.startAt = time.Now()
+	}
 	t.mux.Unlock()
-	finished := make(chan bool)
-	go func() {
-		t.match()
-		finished <- true
-	}()
 
-	// block until match finished or Stop called
-	select {
-	case <-finished:
-		t.mux.Lock()
-		t.exit = nil
-		t.mux.Unlock()
-		return
-	case <-t.exit:
-		t.mux.Lock()
-		t.exit = nil
-		t.mux.Unlock()
-		return
+	stop := make(chan struct{})
+	go t.reportProgress(stop)
+	t.match(ctx)
+	close(stop)
+
+	t.mux.Lock()
+	t.cancel = nil
+	t.running = false
+	t.endAt = time.Now()
+	if err := ctx.Err(); err != nil && t.unfinished() {
+		t.err = err
 	}
+	err := t.err
+	t.mux.Unlock()
+
+	t.notify(EventEnd, "", err)
+	return err
 }
 
-// Stop a Running Task
+// Stop 停止正在运行的 Task，见 RunContext。在 Run 之前调用时，Task 一开始运行就会停止
 func (t *Task) Stop() {
 	t.mux.Lock()
 	defer t.mux.Unlock()
 
-	if t.exit != nil {
-		t.exit <- true
+	t.stopped = true
+	if t.cancel != nil {
+		t.cancel()
 	}
 }
 
+// Err 返回 Task 被 Stop 或 RunContext 的 ctx 取消、超时的原因，未被取消时返回 nil
+func (t *Task) Err() error {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	return t.err
+}
+
+// unfinished 判断是否还有未处理完成的文件，调用者需持有 t.mux
+func (t *Task) unfinished() bool {
+	for _, finished := range t.fileMap {
+		if !finished {
+			return true
+		}
+	}
+	return false
+}
+
 // GetResult return the result matches (map[string]int) and ok=true if task is finished, (nil, false) else
 func (t *Task) GetResult(sortAlgorithm int) (result Result, ok bool) {
-	if t.GetProgress() >= 1 {
+	if t.finished() {
 		t.mux.Lock()
 		defer t.mux.Unlock()
 
 		var result Result
-		for k, f := range t.matches {
-			result = append(result, ResultItem{
-				Keyword:   k,
-				Frequency: f,
-			})
+		if t.ngramming() {
+			for _, item := range t.ngrams.Top(t.NGram.topK()) {
+				result = append(result, ResultItem{
+					Keyword:        item.Key,
+					Frequency:      item.Count,
+					FrequencyError: item.Error,
+				})
+			}
+		} else {
+			var files []string
+			if t.scoring() {
+				files = t.files()
+			}
+			for k, f := range t.matches {
+				item := ResultItem{
+					Keyword:        k,
+					Frequency:      f,
+					FuzzyFrequency: t.fuzzyMatches[k],
+					Snippets:       t.snippets[k],
+				}
+				if t.scoring() {
+					item.Score = t.score(k, files)
+					item.ReferenceFrequency = t.refMatches[k]
+				}
+				result = append(result, item)
+			}
 		}
 		if t.SortFuncName != "" {
-			sortalgo.By(sortalgo.SortAlgorithmsMap[t.SortFuncName]).Sort(result)
+			sortAlgorithm = sortalgo.SortAlgorithmsMap[t.SortFuncName]
+		}
+		if t.scoring() {
+			sortalgo.By(sortAlgorithm).Sort(byScore(result))
 		} else {
 			sortalgo.By(sortAlgorithm).Sort(result)
 		}
+		if t.discovering() && t.Discover.TopN > 0 && len(result) > t.Discover.TopN {
+			result = result[:t.Discover.TopN]
+		}
 		return result, true
 	}
 	return nil, false
 }
 
+// FileError 是处理某个文件时的错误
+type FileError struct {
+	File  string `json:"file"`
+	Error string `json:"error"`
+}
+
+// GetErrors 返回处理出错的文件及错误信息(按文件排序)，没有错误时返回 nil。
+// 参照语料 Score.Reference 中的文件以 ReferenceErrorPrefix 为前缀。
+// 有错误时 GetResult 的结果只包含其余文件，是部分结果
+func (t *Task) GetErrors() []FileError {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	return sortErrors(t.errors)
+}
+
+// Failed 判断是否有文件处理出错，见 GetErrors
+func (t *Task) Failed() bool {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	return len(t.errors) > 0
+}
+
+// sortErrors 把 {"文件": 错误信息} 转为按文件排序的 []FileError
+func sortErrors(errors map[string]string) []FileError {
+	var result []FileError
+	for file, e := range errors {
+		result = append(result, FileError{File: file, Error: e})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].File < result[j].File })
+	return result
+}
+
 // Result 是 wordfa.Task 任务的结果，包含各给定关键词在文件中出现的频数
 // Result 实现了 sort.Interface, 可以按频数从大到小排序
 type Result []ResultItem
 
 // ResultItem 是 Result 切片中的数据条目
 type ResultItem struct {
-	Keyword   string `json:"keyword"`
-	Frequency int    `json:"frequency"`
+	Keyword        string `json:"keyword"`
+	Frequency      int    `json:"frequency"`                 // 精确匹配的次数
+	FuzzyFrequency int    `json:"fuzzy_frequency,omitempty"` // 近似匹配的次数，仅 Task.MaxEditDistance > 0 时统计
+	FrequencyError int    `json:"frequency_error,omitempty"` // n-gram 模式中 Frequency 至多比真实频数大这么多，见 NGramOptions
+
+	Score              float64 `json:"score,omitempty"`               // 评分，仅 Task.Score.Method 不为 ScoreFrequency 时计算，见 ScoreOptions
+	ReferenceFrequency int     `json:"reference_frequency,omitempty"` // 在参照语料中的频数，仅计算关键性时统计
+
+	Snippets []Snippet `json:"snippets,omitempty"` // 按文件、位置排序的前 Task.Concordance.Snippets 个 KWIC 片段
 }
 
 func (r Result) Len() int {
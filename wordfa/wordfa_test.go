@@ -7,13 +7,47 @@ package wordfa
 
 import (
 	"CiFa/util"
+	"CiFa/util/index"
+	"CiFa/util/segment"
 	"CiFa/util/sortalgo"
 	"CiFa/util/strsearch"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
 
+// writeFiles 在新建的临时目录 dir 中写入 files: {文件名: 内容}，返回按文件名排序的各文件路径。
+// 调用者需 defer os.RemoveAll(dir)
+func writeFiles(t *testing.T, files map[string]string) (paths []string, dir string) {
+	dir, err := ioutil.TempDir("", "wordfa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		paths = append(paths, filepath.Join(dir, name))
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			os.RemoveAll(dir)
+			t.Fatal(err)
+		}
+	}
+	sort.Strings(paths)
+	return paths, dir
+}
+
 func TestWordfaTask(t *testing.T) {
 	files, _ := util.GetAllFiles("/Users/c/Desktop/glp", "text/plain")
 
@@ -47,3 +81,878 @@ func TestWordfaTask(t *testing.T) {
 	}
 	t.Error("Failed")
 }
+
+func TestWordfaTaskAlgorithms(t *testing.T) {
+	files := []string{"../util/strsearch/testing_text.txt"}
+	patterns := []string{"阿Ｑ", "阿", "他", "他们", "一九一八年", "没有的东西"}
+
+	var want map[string]int
+	for _, algorithm := range []int{strsearch.Kmp, strsearch.AhoCorasick, strsearch.RabinKarp, strsearch.Naive} {
+		for _, threshold := range []int64{0, 1} { // 1: 流式读取
+			for _, par := range []strsearch.ParallelOptions{{Workers: 1}, {Workers: 4, ChunkSize: 1 << 12}} {
+				task := NewTask(files, patterns)
+				task.StrSearchAlgorithm = algorithm
+				task.StreamThreshold = threshold
+				task.Parallel = par
+				task.Run()
+
+				r, ok := task.GetResult(sortalgo.Heap)
+				if !ok {
+					t.Fatalf("algorithm %v: task not finished after Run()", algorithm)
+				}
+				got := map[string]int{}
+				for _, item := range r {
+					got[item.Keyword] = item.Frequency
+				}
+				if want == nil {
+					want = got
+					continue
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("algorithm %v, StreamThreshold %v, Parallel %+v: got %v, want %v", algorithm, threshold, par, got, want)
+				}
+			}
+		}
+	}
+	if want["他们"] == 0 || want["他"] < want["他们"] || want["没有的东西"] != 0 {
+		t.Errorf("unexpected frequencies: %v", want)
+	}
+}
+
+func TestWordfaTaskRegexp(t *testing.T) {
+	text := strings.Repeat("abc", 30) + "s" + strings.Repeat("-", 40) + "e"
+	paths, dir := writeFiles(t, map[string]string{"regexp.txt": text})
+	defer os.RemoveAll(dir)
+	// 分块搜索时 ^、\B 在块的开头会误判，跨块的长匹配会被漏掉
+	want := map[string]int{`^abc`: 1, `\Babc`: 29, `s-+e`: 1}
+
+	for _, threshold := range []int64{0, 1} { // 1: 流式读取
+		for _, par := range []strsearch.ParallelOptions{{Workers: 1, ChunkSize: 12}, {Workers: 4, ChunkSize: 12}} {
+			task := NewTask(paths, []string{`^abc`, `\Babc`, `s-+e`})
+			task.StrSearchAlgorithm = strsearch.LibRegexp
+			task.StreamThreshold = threshold
+			task.Parallel = par
+			task.Run()
+
+			r, ok := task.GetResult(sortalgo.StlSort)
+			if !ok {
+				t.Fatal("task not finished after Run()")
+			}
+			for _, item := range r {
+				if item.Frequency != want[item.Keyword] {
+					t.Errorf("StreamThreshold %v, Parallel %+v: %v got %v, want %v",
+						threshold, par, item.Keyword, item.Frequency, want[item.Keyword])
+				}
+			}
+		}
+	}
+}
+
+func TestWordfaTaskFuzzy(t *testing.T) {
+	paths, dir := writeFiles(t, map[string]string{"ocr.txt": "阿Q正传。阿Ｑ正传，阿贵正传；阿Q正传阿Q正传。brown brwn browm"})
+	defer os.RemoveAll(dir)
+	file := paths[0]
+
+	task := NewTask([]string{file}, []string{"阿Q正传", "brown"})
+	task.MaxEditDistance = 1
+	task.Run()
+
+	r, ok := task.GetResult(sortalgo.StlSort)
+	if !ok {
+		t.Fatal("task not finished after Run()")
+	}
+	want := map[string][2]int{"阿Q正传": {3, 2}, "brown": {1, 2}}
+	for _, item := range r {
+		if got := [2]int{item.Frequency, item.FuzzyFrequency}; got != want[item.Keyword] {
+			t.Errorf("%v: got (exact, fuzzy) = %v, want %v", item.Keyword, got, want[item.Keyword])
+		}
+	}
+}
+
+func TestWordfaTaskMatchOptions(t *testing.T) {
+	paths, dir := writeFiles(t, map[string]string{"options.txt": "Go is fun. GO! go-to, gopher, ＧＯ；goroutine."})
+	defer os.RemoveAll(dir)
+	file := paths[0]
+
+	optsTests := []struct {
+		opts strsearch.MatchOptions
+		want int
+	}{
+		{strsearch.MatchOptions{}, 3},
+		{strsearch.MatchOptions{IgnoreCase: true}, 5},
+		{strsearch.MatchOptions{WholeWord: true}, 1},
+		{strsearch.MatchOptions{IgnoreCase: true, WholeWord: true}, 3},
+		{strsearch.MatchOptions{IgnoreCase: true, WholeWord: true, NormalizeWidth: true}, 4},
+	}
+	for _, tt := range optsTests {
+		for _, threshold := range []int64{0, 1} {
+			task := NewTask([]string{file}, []string{"go"})
+			task.MatchOptions = tt.opts
+			task.StreamThreshold = threshold
+			task.Run()
+
+			r, _ := task.GetResult(sortalgo.StlSort)
+			if len(r) != 1 || r[0].Frequency != tt.want {
+				t.Errorf("MatchOptions %+v (StreamThreshold=%v): got %v, want frequency %v", tt.opts, threshold, r, tt.want)
+			}
+		}
+	}
+}
+
+func TestWordfaTaskIndex(t *testing.T) {
+	src := "../util/strsearch/testing_text.txt"
+	idx, err := index.Build([]string{src})
+	if err != nil {
+		t.Fatal(err)
+	}
+	patterns := []string{"阿Ｑ", "一九一八年", "那么，", "没有的东西"}
+
+	scanned := NewTask([]string{src}, patterns)
+	scanned.StrSearchAlgorithm = strsearch.Kmp
+	scanned.Run()
+	want, _ := scanned.GetResult(sortalgo.StlSort)
+
+	indexed := NewTask(nil, patterns)
+	indexed.Index = idx
+	indexed.Run()
+	got, ok := indexed.GetResult(sortalgo.StlSort)
+	if !ok {
+		t.Fatal("task not finished after Run()")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("with Index: got %v, want %v", got, want)
+	}
+}
+
+func TestWordfaTaskIndexRelativePath(t *testing.T) {
+	src := "../util/strsearch/testing_text.txt"
+	idx, err := index.Build([]string{src})
+	if err != nil {
+		t.Fatal(err)
+	}
+	abs, err := filepath.Abs(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	patterns := []string{"阿Ｑ", "一九一八年"}
+
+	for _, file := range []string{"./" + src, abs} {
+		task := NewTask([]string{file}, patterns)
+		task.Index = idx
+		task.Run()
+		if errs := task.GetErrors(); len(errs) != 0 {
+			t.Errorf("%v: GetErrors() = %v, want none", file, errs)
+		}
+		m, ok := task.GetMatrix(sortalgo.StlSort)
+		if !ok {
+			t.Fatal("task not finished after Run()")
+		}
+		if !reflect.DeepEqual(m.Files, []string{file}) || m.Counts[0][0] == 0 || m.Counts[0][1] == 0 {
+			t.Errorf("%v: GetMatrix() = %+v, want counts of the indexed file", file, m)
+		}
+	}
+}
+
+func TestWordfaTaskIndexSubset(t *testing.T) {
+	paths, dir := writeFiles(t, map[string]string{"a.txt": "cat cat dog", "b.txt": "cat", "c.txt": "cat"})
+	defer os.RemoveAll(dir)
+	a, b, c := paths[0], paths[1], paths[2]
+	idx, err := index.Build([]string{a, b})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	task := NewTask([]string{b, c}, []string{"cat", "dog"})
+	task.Index = idx
+	task.Run()
+
+	r, ok := task.GetResult(sortalgo.StlSort)
+	if !ok {
+		t.Fatal("task not finished after Run()")
+	}
+	want := Result{{Keyword: "cat", Frequency: 1}, {Keyword: "dog", Frequency: 0}}
+	if !reflect.DeepEqual(r, want) {
+		t.Errorf("got %v, want %v: only SrcFiles should be counted", r, want)
+	}
+	if errs := task.GetErrors(); len(errs) != 1 || errs[0].File != c {
+		t.Errorf("GetErrors() = %v, want an error for the file not in the index", errs)
+	}
+	if m, _ := task.GetMatrix(sortalgo.StlSort); !reflect.DeepEqual(m.Files, []string{b, c}) {
+		t.Errorf("matrix files = %v, want %v", m.Files, []string{b, c})
+	}
+}
+
+func TestWordfaTaskConcordance(t *testing.T) {
+	paths, dir := writeFiles(t, map[string]string{"kwic.txt": "第一行没有。\n我们都是阿Q，\n阿Q正传写的是阿Q的故事。\nGo go GO"})
+	defer os.RemoveAll(dir)
+	file := paths[0]
+
+	task := NewTask([]string{file}, []string{"阿Q", "go", "没有的东西"})
+	task.Concordance = ConcordanceOptions{Snippets: 2, Context: 3}
+	task.MatchOptions.IgnoreCase = true
+	task.StreamThreshold = 1
+	task.Run()
+
+	r, ok := task.GetResult(sortalgo.StlSort)
+	if !ok {
+		t.Fatal("task not finished after Run()")
+	}
+	want := map[string][]Snippet{
+		"阿Q": {
+			{File: file, Line: 2, Column: 5, Left: "们都是", Keyword: "阿Q", Right: "， 阿"},
+			{File: file, Line: 3, Column: 1, Left: "Q， ", Keyword: "阿Q", Right: "正传写"},
+		},
+		"go": {
+			{File: file, Line: 4, Column: 1, Left: "事。 ", Keyword: "Go", Right: " go"},
+			{File: file, Line: 4, Column: 4, Left: "Go ", Keyword: "go", Right: " GO"},
+		},
+		"没有的东西": nil,
+	}
+	for _, item := range r {
+		if !reflect.DeepEqual(item.Snippets, want[item.Keyword]) {
+			t.Errorf("%v: got snippets %+v, want %+v", item.Keyword, item.Snippets, want[item.Keyword])
+		}
+	}
+	if r[0].Frequency != 3 || len(r[0].Snippets) != 2 {
+		t.Errorf("got %v, want 3 matches with 2 snippets", r[0])
+	}
+}
+
+func TestWordfaTaskConcordanceRegexp(t *testing.T) {
+	paths, dir := writeFiles(t, map[string]string{
+		"a.txt": "阿Q阿D", "b.txt": "阿Q阿Q阿Q", "c.txt": "阿D",
+	})
+	defer os.RemoveAll(dir)
+
+	task := NewTask(paths, []string{"阿[QD]"})
+	task.StrSearchAlgorithm = strsearch.LibRegexp
+	task.Concordance = ConcordanceOptions{Snippets: 2, Context: 1}
+	task.Workers = 1
+	task.Run()
+
+	r, ok := task.GetResult(sortalgo.StlSort)
+	if !ok {
+		t.Fatal("task not finished after Run()")
+	}
+	want := []Snippet{
+		{File: paths[0], Line: 1, Column: 1, Left: "", Keyword: "阿Q", Right: "阿"},
+		{File: paths[0], Line: 1, Column: 3, Left: "Q", Keyword: "阿D", Right: ""},
+	}
+	if r[0].Frequency != 6 || !reflect.DeepEqual(r[0].Snippets, want) {
+		t.Errorf("got %v matches, snippets %+v; want 6, %+v", r[0].Frequency, r[0].Snippets, want)
+	}
+	// 合并每个文件的片段时就截断，不会保存所有匹配的片段
+	if n := len(task.snippets["阿[QD]"]); n != 2 {
+		t.Errorf("task keeps %v snippets, want 2", n)
+	}
+}
+
+func TestWordfaTaskDiscover(t *testing.T) {
+	files, dir := writeFiles(t, map[string]string{
+		"a.txt": "我们都是自己人。Go is fun, go!",
+		"b.txt": "他们说：我们没有办法。GO，ＧＯ",
+	})
+	defer os.RemoveAll(dir)
+
+	task := NewTask(files, nil)
+	task.MatchOptions = strsearch.MatchOptions{IgnoreCase: true, NormalizeWidth: true}
+	task.Discover = DiscoverOptions{TopN: 3, MinRunes: 2}
+	task.Run()
+
+	r, ok := task.GetResult(sortalgo.StlStable)
+	if !ok {
+		t.Fatal("task not finished after Run()")
+	}
+	got := map[string]int{}
+	for _, item := range r {
+		got[item.Keyword] = item.Frequency
+	}
+	want := map[string]int{"go": 4, "我们": 2}
+	if len(r) != 3 || got["go"] != want["go"] || got["我们"] != want["我们"] {
+		t.Errorf("got %v, want top 3 including %v", r, want)
+	}
+	if _, ok := got["是"]; ok {
+		t.Errorf("got %v, single-rune words should be excluded by MinRunes", r)
+	}
+}
+
+func TestWordfaTaskTokenMatch(t *testing.T) {
+	paths, dir := writeFiles(t, map[string]string{"tokens.txt": "我们不是不可以是这样。是的，阿Q正传。Go gopher GO"})
+	defer os.RemoveAll(dir)
+	file := paths[0]
+	patterns := []string{"是", "不是", "阿Q正传", "go"}
+
+	for _, segmenter := range segment.SegmentersMap {
+		task := NewTask([]string{file}, patterns)
+		task.TokenMatch = true
+		task.Segmenter = segment.By(segmenter, nil)
+		task.MatchOptions.IgnoreCase = true
+		task.Run()
+
+		r, ok := task.GetResult(sortalgo.StlSort)
+		if !ok {
+			t.Fatal("task not finished after Run()")
+		}
+		got := map[string]int{}
+		for _, item := range r {
+			got[item.Keyword] = item.Frequency
+		}
+		want := map[string]int{"是": 2, "不是": 1, "阿Q正传": 1, "go": 2}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("segmenter %v: got %v, want %v", segmenter, got, want)
+		}
+	}
+}
+
+func TestWordfaTaskTokenMatchSentences(t *testing.T) {
+	paths, dir := writeFiles(t, map[string]string{"tokens.txt": "The end. Start again, the end start here"})
+	defer os.RemoveAll(dir)
+
+	for _, segmenter := range segment.SegmentersMap {
+		task := NewTask(paths, []string{"end start", "end. start", "again the"})
+		task.TokenMatch = true
+		task.Segmenter = segment.By(segmenter, nil)
+		task.MatchOptions.IgnoreCase = true
+		task.Run()
+
+		r, ok := task.GetResult(sortalgo.StlSort)
+		if !ok {
+			t.Fatal("task not finished after Run()")
+		}
+		got := map[string]int{}
+		for _, item := range r {
+			got[item.Keyword] = item.Frequency
+		}
+		want := map[string]int{"end start": 1, "end. start": 1, "again the": 0}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("segmenter %v: got %v, want %v: word sequences should not span punctuation", segmenter, got, want)
+		}
+	}
+}
+
+func TestWordfaTaskTokenMatchPunct(t *testing.T) {
+	paths, dir := writeFiles(t, map[string]string{"tokens.txt": "是的，阿Q正传。"})
+	defer os.RemoveAll(dir)
+
+	for _, segmenter := range segment.SegmentersMap {
+		task := NewTask(paths, []string{"。", "，"})
+		task.TokenMatch = true
+		task.Segmenter = segment.By(segmenter, nil)
+		task.Run()
+
+		if task.Failed() {
+			t.Errorf("segmenter %v: GetErrors() = %v", segmenter, task.GetErrors())
+		}
+		r, ok := task.GetResult(sortalgo.StlSort)
+		if !ok {
+			t.Fatal("task not finished after Run()")
+		}
+		for _, item := range r {
+			if item.Frequency != 0 {
+				t.Errorf("segmenter %v: got %v, punctuation-only keywords should match nothing", segmenter, r)
+			}
+		}
+	}
+}
+
+func TestWordfaTaskNGram(t *testing.T) {
+	files, dir := writeFiles(t, map[string]string{
+		"a.txt": "人工智能是人工的智能。人工智能！",
+		"b.txt": "我们研究人工智能，我们研究 AI。We are the AI, we are.",
+	})
+	defer os.RemoveAll(dir)
+
+	dict := segment.NewDictionary()
+	for _, w := range []string{"人工智能", "我们", "研究"} {
+		dict.Add(w, 0)
+	}
+
+	ngramTests := []struct {
+		opts NGramOptions
+		want map[string]int // 前 TopK 个中应有的 n-gram
+	}{
+		{NGramOptions{N: 2, Chars: true, TopK: 3}, map[string]int{"人工": 4, "智能": 4, "工智": 3}},
+		{NGramOptions{N: 4, Chars: true, TopK: 1}, map[string]int{"人工智能": 3}},
+		{NGramOptions{N: 2, TopK: 2}, map[string]int{"we are": 2, "我们 研究": 2}},
+	}
+	for _, tt := range ngramTests {
+		for _, capacity := range []int{0, 8} {
+			task := NewTask(files, []string{"被忽略的关键词"})
+			task.NGram = tt.opts
+			task.NGram.Capacity = capacity
+			task.Segmenter = &segment.ForwardMaxMatch{Dict: dict}
+			task.MatchOptions.IgnoreCase = true
+			task.Run()
+
+			r, ok := task.GetResult(sortalgo.StlSort)
+			if !ok {
+				t.Fatal("task not finished after Run()")
+			}
+			if len(r) != tt.opts.TopK {
+				t.Errorf("%+v: got %v, want %v n-grams", task.NGram, r, tt.opts.TopK)
+			}
+			for _, item := range r {
+				f, ok := tt.want[item.Keyword]
+				if !ok || item.Frequency < f || item.Frequency-item.FrequencyError > f {
+					t.Errorf("%+v: got %+v, want one of %v", task.NGram, item, tt.want)
+				}
+				if capacity == 0 && item.FrequencyError != 0 {
+					t.Errorf("%+v: got %+v, want exact counts", task.NGram, item)
+				}
+			}
+		}
+	}
+}
+
+func TestWordfaTaskMatrix(t *testing.T) {
+	files, dir := writeFiles(t, map[string]string{"a.txt": "他们说他们不是他们。", "b.txt": "我们,他们", "c.txt": "没有"})
+	defer os.RemoveAll(dir)
+	idx, err := index.Build(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &Matrix{
+		Files:    files,
+		Keywords: []string{"他们", "我们", "没有的东西"},
+		Counts:   [][]int{{3, 0, 0}, {1, 1, 0}, {0, 0, 0}},
+	}
+	for _, indexed := range []bool{false, true} {
+		task := NewTask(files, []string{"我们", "没有的东西", "他们"})
+		if indexed {
+			task.Index = idx
+		}
+		task.Run()
+		m, ok := task.GetMatrix(sortalgo.StlStable)
+		if !ok {
+			t.Fatal("task not finished after Run()")
+		}
+		if !reflect.DeepEqual(m, want) {
+			t.Errorf("Index %v: GetMatrix() = %+v, want %+v", indexed, m, want)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := want.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	wantCSV := "file,他们,我们,没有的东西\n" + files[0] + ",3,0,0\n" + files[1] + ",1,1,0\n" + files[2] + ",0,0,0\n"
+	if buf.String() != wantCSV {
+		t.Errorf("WriteCSV() = %q, want %q", buf.String(), wantCSV)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("WriteCSV() output not valid CSV: %v", err)
+	}
+	if len(records) != len(files)+1 {
+		t.Errorf("WriteCSV() wrote %v records, want %v", len(records), len(files)+1)
+	}
+
+	// n-gram 模式没有各词的频数，不能写成 CSV
+	task := NewTask(files, nil)
+	task.NGram = NGramOptions{N: 2, Chars: true, TopK: 2}
+	task.Run()
+	m, ok := task.GetMatrix(sortalgo.StlStable)
+	if !ok {
+		t.Fatal("task not finished after Run()")
+	}
+	buf.Reset()
+	if err := m.WriteCSV(&buf); err != ErrNoCounts || buf.Len() != 0 {
+		t.Errorf("n-gram WriteCSV() = %v, wrote %q, want ErrNoCounts and nothing", err, buf.String())
+	}
+}
+
+func TestWordfaTaskScore(t *testing.T) {
+	files, dir := writeFiles(t, map[string]string{"a.txt": "cat cat cat dog", "b.txt": "dog dog fish fish", "ref.txt": "dog dog dog dog cat"})
+	defer os.RemoveAll(dir)
+	idf := func(df int) float64 { return math.Log(3/float64(1+df)) + 1 }
+
+	scoreTests := []struct {
+		method int
+		want   []ResultItem // 按得分排序
+	}{
+		{ScoreTfIdf, []ResultItem{
+			{Keyword: "cat", Frequency: 3, Score: 3.0 / 4 * idf(1)},
+			{Keyword: "fish", Frequency: 2, Score: 2.0 / 4 * idf(1)},
+			{Keyword: "dog", Frequency: 3, Score: 2.0 / 4 * idf(2)},
+		}},
+		{ScoreLogLikelihood, []ResultItem{
+			{Keyword: "fish", Frequency: 2, Score: 4 * math.Log(13.0/8)},
+			{Keyword: "cat", Frequency: 3, ReferenceFrequency: 1, Score: 2 * (3*math.Log(39.0/32) + math.Log(13.0/20))},
+			{Keyword: "dog", Frequency: 3, ReferenceFrequency: 4, Score: -2 * (3*math.Log(39.0/56) + 4*math.Log(52.0/35))},
+		}},
+		{ScoreChiSquared, []ResultItem{
+			{Keyword: "fish", Frequency: 2, Score: 13.0 * 100 / 880},
+			{Keyword: "cat", Frequency: 3, ReferenceFrequency: 1, Score: 13.0 * 49 / (4 * 9 * 8 * 5)},
+			{Keyword: "dog", Frequency: 3, ReferenceFrequency: 4, Score: -13.0 * 289 / (7 * 6 * 8 * 5)},
+		}},
+	}
+	for _, tt := range scoreTests {
+		for _, algorithm := range []int{sortalgo.Quick, sortalgo.Heap} {
+			task := NewTask(files[:2], []string{"cat", "dog", "fish"})
+			task.Score = ScoreOptions{Method: tt.method, Reference: files[2:]}
+			task.Run()
+
+			r, ok := task.GetResult(algorithm)
+			if !ok {
+				t.Fatal("task not finished after Run()")
+			}
+			if len(r) != len(tt.want) {
+				t.Fatalf("method %v: got %v, want %v", tt.method, r, tt.want)
+			}
+			for i, item := range r {
+				w := tt.want[i]
+				if item.Keyword != w.Keyword || item.Frequency != w.Frequency ||
+					item.ReferenceFrequency != w.ReferenceFrequency || math.Abs(item.Score-w.Score) > 1e-9 {
+					t.Errorf("method %v: result[%v] = %+v, want %+v", tt.method, i, item, w)
+				}
+			}
+		}
+	}
+
+	task := NewTask(files[:2], []string{"cat", "dog"})
+	task.Score.Method = ScoreTfIdf
+	task.Run()
+	m, _ := task.GetMatrix(sortalgo.StlSort)
+	want := [][]float64{{3.0 / 4 * idf(1), 1.0 / 4 * idf(2)}, {0, 2.0 / 4 * idf(2)}}
+	if !reflect.DeepEqual(m.Keywords, []string{"cat", "dog"}) || !reflect.DeepEqual(m.Scores, want) {
+		t.Errorf("GetMatrix() = %+v, want scores %v", m, want)
+	}
+
+	// 参照语料中出错的文件带有前缀，不与 SrcFiles 混淆
+	missing := filepath.Join(dir, "missing.txt")
+	task = NewTask(files[:2], []string{"cat", "dog"})
+	task.Score = ScoreOptions{Method: ScoreLogLikelihood, Reference: []string{files[2], missing}}
+	task.Run()
+	if errs := task.GetErrors(); len(errs) != 1 || errs[0].File != ReferenceErrorPrefix+missing {
+		t.Errorf("GetErrors() = %v, want an error for %v", errs, ReferenceErrorPrefix+missing)
+	}
+}
+
+func TestScoreOptionsValidate(t *testing.T) {
+	for method := range ScoringMethodsMap {
+		m := ScoringMethodsMap[method]
+		keyness := m == ScoreLogLikelihood || m == ScoreChiSquared
+		if err := (ScoreOptions{Method: m}).Validate(); (err == ErrNoReference) != keyness {
+			t.Errorf("%v without reference: Validate() = %v", method, err)
+		}
+		if err := (ScoreOptions{Method: m, Reference: []string{"ref.txt"}}).Validate(); err != nil {
+			t.Errorf("%v with reference: Validate() = %v", method, err)
+		}
+	}
+}
+
+func TestDiffTask(t *testing.T) {
+	files, dir := writeFiles(t, map[string]string{"a.txt": "cat cat cat dog fish", "b1.txt": "cat dog dog dog", "b2.txt": "dog dog fish fish"})
+	defer os.RemoveAll(dir)
+
+	want := DiffResult{ // 语料 A 共 5 词，B 共 8 词
+		{Keyword: "cat", FrequencyA: 3, FrequencyB: 1, Change: -2, RelativeChange: -2.0 / 3, RateA: 6000, RateB: 1250},
+		{Keyword: "dog", FrequencyA: 1, FrequencyB: 5, Change: 4, RelativeChange: 4, RateA: 2000, RateB: 6250},
+		{Keyword: "fish", FrequencyA: 1, FrequencyB: 2, Change: 1, RelativeChange: 1, RateA: 2000, RateB: 2500},
+		{Keyword: "bird"},
+	}
+	for _, algorithm := range []int{sortalgo.Quick, sortalgo.Heap} {
+		task := NewDiffTask(files[:1], files[1:], []string{"cat", "dog", "fish", "bird"})
+		if _, ok := task.GetResult(algorithm); ok {
+			t.Error("GetResult before Run: ok = true")
+		}
+		task.Run()
+		if p := task.GetProgress(); p < 1 {
+			t.Errorf("progress after Run = %v", p)
+		}
+
+		r, ok := task.GetResult(algorithm)
+		if !ok {
+			t.Fatal("task not finished after Run()")
+		}
+		if len(r) != len(want) {
+			t.Fatalf("got %v, want %v", r, want)
+		}
+		for i, item := range r {
+			w := want[i]
+			if item.Keyword != w.Keyword || item.FrequencyA != w.FrequencyA || item.FrequencyB != w.FrequencyB ||
+				item.Change != w.Change || math.Abs(item.RelativeChange-w.RelativeChange) > 1e-9 ||
+				math.Abs(item.RateA-w.RateA) > 1e-9 || math.Abs(item.RateB-w.RateB) > 1e-9 {
+				t.Errorf("result[%v] = %+v, want %+v", i, item, w)
+			}
+		}
+	}
+}
+
+func TestWordfaTaskCooccurrence(t *testing.T) {
+	paths, dir := writeFiles(t, map[string]string{"a.txt": "猫和狗。狗和鱼。猫猫。\n鸟"})
+	defer os.RemoveAll(dir)
+	file := paths[0]
+
+	cooccurTests := []struct {
+		options CooccurrenceOptions
+		units   int
+		freq    []int
+		pairs   []CooccurPairItem // 关键词的顺序是 猫、狗、鱼
+	}{
+		{CooccurrenceOptions{Scope: CooccurSentence}, 4, []int{2, 2, 1}, []CooccurPairItem{
+			{I: 0, J: 1, Count: 1, PMI: 0},
+			{I: 1, J: 2, Count: 1, PMI: 1},
+		}},
+		{CooccurrenceOptions{Scope: CooccurParagraph}, 2, []int{1, 1, 1}, []CooccurPairItem{
+			{I: 0, J: 1, Count: 1, PMI: 1},
+			{I: 0, J: 2, Count: 1, PMI: 1},
+			{I: 1, J: 2, Count: 1, PMI: 1},
+		}},
+		{CooccurrenceOptions{Scope: CooccurWindow, Window: 2}, 13, []int{3, 2, 1}, []CooccurPairItem{
+			{I: 0, J: 1, Count: 1, PMI: math.Log2(13.0 / (3 * 2 * 4))},
+			{I: 0, J: 2, Count: 1, PMI: math.Log2(13.0 / (3 * 1 * 4))},
+			{I: 1, J: 2, Count: 1, PMI: math.Log2(13.0 / (2 * 1 * 4))},
+		}},
+	}
+	for _, tt := range cooccurTests {
+		task := NewTask([]string{file}, []string{"猫", "狗", "鱼"})
+		task.Cooccurrence = tt.options
+		task.Run()
+
+		c, ok := task.GetCooccurrence(sortalgo.Heap)
+		if !ok || c == nil {
+			t.Fatalf("%+v: GetCooccurrence() = (%v, %v)", tt.options, c, ok)
+		}
+		if c.Units != tt.units || !reflect.DeepEqual(c.Keywords, []string{"猫", "狗", "鱼"}) || !reflect.DeepEqual(c.Frequencies, tt.freq) {
+			t.Errorf("%+v: got units %v, keywords %v, frequencies %v, want %v, [猫 狗 鱼], %v",
+				tt.options, c.Units, c.Keywords, c.Frequencies, tt.units, tt.freq)
+		}
+		if len(c.Pairs) != len(tt.pairs) {
+			t.Fatalf("%+v: pairs = %v, want %v", tt.options, c.Pairs, tt.pairs)
+		}
+		for i, p := range c.Pairs {
+			w := tt.pairs[i]
+			if p.I != w.I || p.J != w.J || p.Count != w.Count || math.Abs(p.PMI-w.PMI) > 1e-9 {
+				t.Errorf("%+v: pairs[%v] = %+v, want %+v", tt.options, i, p, w)
+			}
+		}
+
+		for name, write := range map[string]func(io.Writer) error{"GraphML": c.WriteGraphML, "GEXF": c.WriteGEXF} {
+			var buf bytes.Buffer
+			if err := write(&buf); err != nil {
+				t.Fatal(err)
+			}
+			edges := 0
+			for d := xml.NewDecoder(&buf); ; {
+				tok, err := d.Token()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("%v: bad XML: %v", name, err)
+				}
+				if e, ok := tok.(xml.StartElement); ok && e.Name.Local == "edge" {
+					edges++
+				}
+			}
+			if edges != len(tt.pairs) {
+				t.Errorf("%v: %v edges, want %v", name, edges, len(tt.pairs))
+			}
+		}
+	}
+
+	task := NewTask([]string{file}, []string{"猫"})
+	task.Run()
+	if c, ok := task.GetCooccurrence(sortalgo.Heap); !ok || c != nil {
+		t.Errorf("without Cooccurrence: GetCooccurrence() = (%v, %v), want (nil, true)", c, ok)
+	}
+}
+
+func TestWordfaTaskErrors(t *testing.T) {
+	paths, dir := writeFiles(t, map[string]string{"a.txt": "cat cat dog"})
+	defer os.RemoveAll(dir)
+	good := paths[0]
+	missing := filepath.Join(dir, "missing.txt")
+
+	for _, workers := range []int{0, 1, 2} {
+		for _, tokenMatch := range []bool{false, true} {
+			task := NewTask([]string{missing, good}, []string{"cat", "dog"})
+			task.Workers = workers
+			task.TokenMatch = tokenMatch
+			task.Run()
+
+			if p := task.GetProgress(); p < 1 {
+				t.Errorf("workers %v: progress after Run = %v", workers, p)
+			}
+			if !task.Failed() {
+				t.Errorf("workers %v: Failed() = false", workers)
+			}
+			if errs := task.GetErrors(); len(errs) != 1 || errs[0].File != missing || errs[0].Error == "" {
+				t.Errorf("workers %v: GetErrors() = %v", workers, errs)
+			}
+			r, ok := task.GetResult(sortalgo.Heap)
+			want := Result{{Keyword: "cat", Frequency: 2}, {Keyword: "dog", Frequency: 1}}
+			if !ok || !reflect.DeepEqual(r, want) {
+				t.Errorf("workers %v, token match %v: partial result = %v, want %v", workers, tokenMatch, r, want)
+			}
+		}
+	}
+
+	task := NewTask([]string{good}, []string{"cat"})
+	task.Run()
+	if task.Failed() || task.GetErrors() != nil {
+		t.Errorf("no error: Failed() = %v, GetErrors() = %v", task.Failed(), task.GetErrors())
+	}
+}
+
+func TestWordfaTaskRunContext(t *testing.T) {
+	paths, dir := writeFiles(t, map[string]string{"a.txt": strings.Repeat("cat dog ", 1000)})
+	defer os.RemoveAll(dir)
+	file := paths[0]
+
+	task := NewTask([]string{file}, []string{"cat"})
+	if err := task.RunContext(context.Background()); err != nil || task.Err() != nil {
+		t.Errorf("RunContext() = %v, Err() = %v, want nil", err, task.Err())
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	for _, tt := range []struct {
+		ctx  context.Context
+		want error
+	}{{canceled, context.Canceled}, {expired, context.DeadlineExceeded}} {
+		for _, tokenMatch := range []bool{false, true} {
+			task := NewTask([]string{file}, []string{"cat"})
+			task.TokenMatch = tokenMatch
+			if err := task.RunContext(tt.ctx); err != tt.want || task.Err() != tt.want {
+				t.Errorf("token match %v: RunContext() = %v, Err() = %v, want %v", tokenMatch, err, task.Err(), tt.want)
+			}
+			if r, ok := task.GetResult(sortalgo.Heap); ok || r != nil {
+				t.Errorf("token match %v: stopped task GetResult() = (%v, %v)", tokenMatch, r, ok)
+			}
+		}
+	}
+
+	task = NewTask([]string{file}, []string{"cat"})
+	task.Stop()
+	if task.Run(); task.Err() != context.Canceled || task.GetProgress() >= 1 {
+		t.Errorf("Stop before Run: Err() = %v, progress = %v", task.Err(), task.GetProgress())
+	}
+
+	diff := NewDiffTask([]string{file}, []string{file}, []string{"cat"})
+	if err := diff.RunContext(canceled); err != context.Canceled {
+		t.Errorf("DiffTask.RunContext() = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestWordfaTaskStopRegexp(t *testing.T) {
+	// LibRegexp 整个文件作为一块搜索，Stop 也要能中途停止
+	paths, dir := writeFiles(t, map[string]string{"a.txt": strings.Repeat("cat dog ", 1<<20)})
+	defer os.RemoveAll(dir)
+	patterns := []string{`\w+zz`}
+
+	task := NewTask(paths, patterns)
+	task.StrSearchAlgorithm = strsearch.LibRegexp
+	start := time.Now()
+	task.Run()
+	full := time.Since(start)
+
+	task = NewTask(paths, patterns)
+	task.StrSearchAlgorithm = strsearch.LibRegexp
+	time.AfterFunc(full/10, task.Stop)
+	start = time.Now()
+	task.Run()
+	if elapsed := time.Since(start); task.Err() != context.Canceled || elapsed > full/2 {
+		t.Errorf("Stop during search: Err() = %v, took %v, the whole search takes %v", task.Err(), elapsed, full)
+	}
+}
+
+func TestWordfaTaskStatus(t *testing.T) {
+	paths, dir := writeFiles(t, map[string]string{"a.txt": strings.Repeat("cat dog ", 100), "b.txt": "cat"})
+	defer os.RemoveAll(dir)
+	a, b := paths[0], paths[1]
+
+	task := NewTask([]string{a, b}, []string{"cat"})
+	if s := task.GetStatus(); s.State != StatePending || task.GetProgress() != 0 {
+		t.Errorf("before Run: GetStatus() = %+v, GetProgress() = %v", s, task.GetProgress())
+	}
+	task.MaxEditDistance = 1 // 每个文件读两遍，字节数仍只计一遍
+	task.Run()
+	s := task.GetStatus()
+	want := Progress{State: StateDone, Files: 2, FilesDone: 2, Bytes: 803, BytesDone: 803, Elapsed: s.Elapsed, Throughput: s.Throughput}
+	if !reflect.DeepEqual(s, want) || s.Elapsed <= 0 || task.GetProgress() != 1 {
+		t.Errorf("after Run: GetStatus() = %+v, want %+v, GetProgress() = %v", s, want, task.GetProgress())
+	}
+
+	task = NewTask([]string{a, filepath.Join(dir, "missing.txt")}, []string{"cat"})
+	task.Run()
+	if s := task.GetStatus(); s.State != StateFailed || s.FilesDone != 2 || s.BytesDone != 800 || s.Fraction() != 1 {
+		t.Errorf("with errors: GetStatus() = %+v", s)
+	}
+
+	task = NewTask([]string{a, b}, []string{"cat"})
+	task.Stop()
+	task.Run()
+	if s := task.GetStatus(); s.State != StateCancelled || s.FilesDone != 0 || s.ETA != -1 {
+		t.Errorf("stopped: GetStatus() = %+v", s)
+	}
+	if data, _ := json.Marshal(task.GetStatus()); !bytes.Contains(data, []byte(`"state":"cancelled"`)) {
+		t.Errorf("stopped: json = %s", data)
+	}
+
+	if f := (Progress{State: StateRunning, Bytes: 10, BytesDone: 10}).Fraction(); f != 0.99 {
+		t.Errorf("Fraction() of unfinished task = %v, want 0.99", f)
+	}
+	running := Progress{State: StateRunning, Files: 1, Bytes: 100, BytesDone: 50, Elapsed: 2}
+	running.estimate()
+	if running.Throughput != 25 || running.ETA != 2 {
+		t.Errorf("estimate() = throughput %v, ETA %v, want 25, 2", running.Throughput, running.ETA)
+	}
+	if m := running.merge(Progress{State: StateDone, Files: 1, FilesDone: 1, Bytes: 50, BytesDone: 50, Elapsed: 1}); m.State != StateRunning ||
+		m.Files != 2 || m.BytesDone != 100 || m.Throughput != 50 || m.ETA != 1 {
+		t.Errorf("merge() = %+v", m)
+	}
+
+	diff := NewDiffTask([]string{a}, []string{b}, []string{"cat"})
+	diff.Run()
+	if s := diff.GetStatus(); s.State != StateDone || s.Files != 2 || s.BytesDone != 803 || diff.GetProgress() != 1 {
+		t.Errorf("DiffTask.GetStatus() = %+v", s)
+	}
+}
+
+func TestWordfaTaskSubscribe(t *testing.T) {
+	paths, dir := writeFiles(t, map[string]string{"a.txt": "cat cat dog"})
+	defer os.RemoveAll(dir)
+	good := paths[0]
+	missing := filepath.Join(dir, "missing.txt")
+
+	task := NewTask([]string{good, missing}, []string{"cat"})
+	task.Workers = 2
+	var mux sync.Mutex
+	files := map[string]string{}
+	var last Event
+	unsubscribe := task.Subscribe(func(e Event) {
+		mux.Lock()
+		defer mux.Unlock()
+		if e.Type == EventFile {
+			files[e.File] = e.Error
+		}
+		last = e
+	})
+	removed := 0
+	task.Subscribe(func(Event) { removed++ })() // 立即取消注册
+	task.Run()
+	unsubscribe()
+
+	if len(files) != 2 || files[good] != "" || files[missing] == "" {
+		t.Errorf("file events = %v", files)
+	}
+	if last.Type != EventEnd || last.Status.State != StateFailed || last.Status.FilesDone != 2 {
+		t.Errorf("last event = %+v, want end with state failed", last)
+	}
+	if removed != 0 {
+		t.Errorf("unsubscribed observer got %v events", removed)
+	}
+
+	task = NewTask([]string{good}, []string{"cat"})
+	task.Stop()
+	task.Subscribe(func(e Event) { last = e })
+	task.Run()
+	if last.Type != EventEnd || last.Error != context.Canceled.Error() || last.Status.State != StateCancelled {
+		t.Errorf("stopped: last event = %+v", last)
+	}
+}